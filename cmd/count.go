@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"strings"
+
+	"github.com/mpppk/messagen/internal/option"
+	"github.com/mpppk/messagen/messagen"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+)
+
+func newCountCmd(fs afero.Fs) (*cobra.Command, error) {
+	cmd := &cobra.Command{
+		Use:   "count",
+		Short: "Count messages which can be generated",
+		Long: `Count distinct derivations of messages which can be generated from the definitions without enumerating them.
+If the count can not be computed exactly, its upper bound is printed with the reason.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			config, err := option.NewCountCmdConfigFromViper()
+			if err != nil {
+				return err
+			}
+
+			msgConfig, err := messagen.ParseFilesOrUrls(config.FilePaths...)
+			if err != nil {
+				return err
+			}
+
+			postProcess, typePostProcess, err := msgConfig.Transformers()
+			if err != nil {
+				return err
+			}
+
+			generator, err := messagen.New(&messagen.Option{
+				FuncMap:         messagen.TraceryFuncMap(),
+				PostProcess:     postProcess,
+				TypePostProcess: typePostProcess,
+				Locales:         config.Locales,
+			})
+			if err != nil {
+				return err
+			}
+
+			if err := generator.AddDefinition(msgConfig.Definitions...); err != nil {
+				return err
+			}
+
+			result, err := generator.CountContext(cmd.Context(), config.RootType, config.InitialState)
+			if err != nil {
+				return err
+			}
+			if result.IsExact {
+				cmd.Println(result.Count)
+			} else {
+				cmd.Printf("at most %s (upper bound: %s)\n", result.Count, strings.Join(result.Reasons, ", "))
+			}
+			return nil
+		},
+	}
+	return cmd, nil
+}
+
+func init() {
+	cmdGenerators = append(cmdGenerators, newCountCmd)
+}
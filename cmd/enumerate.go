@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"github.com/mpppk/messagen/internal/option"
+	"github.com/mpppk/messagen/messagen"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+)
+
+func newEnumerateCmd(fs afero.Fs) (*cobra.Command, error) {
+	cmd := &cobra.Command{
+		Use:   "enumerate",
+		Short: "Enumerate every message which can be generated",
+		Long: `Enumerate every distinct message which can be generated from the definitions in deterministic order.
+Definitions are picked in order of constraint priority, and templates are picked in ascending order.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			config, err := option.NewEnumerateCmdConfigFromViper()
+			if err != nil {
+				return err
+			}
+
+			msgConfig, err := messagen.ParseFilesOrUrls(config.FilePaths...)
+			if err != nil {
+				return err
+			}
+
+			postProcess, typePostProcess, err := msgConfig.Transformers()
+			if err != nil {
+				return err
+			}
+
+			generator, err := messagen.New(&messagen.Option{
+				MaxDepth:        config.MaxDepth,
+				MaxSteps:        config.MaxSteps,
+				Timeout:         config.Timeout,
+				FuncMap:         messagen.TraceryFuncMap(),
+				PostProcess:     postProcess,
+				TypePostProcess: typePostProcess,
+				Locales:         config.Locales,
+			})
+			if err != nil {
+				return err
+			}
+
+			if err := generator.AddDefinition(msgConfig.Definitions...); err != nil {
+				return err
+			}
+
+			it, err := generator.EnumerateContext(cmd.Context(), config.RootType, config.InitialState)
+			if err != nil {
+				return err
+			}
+			for cnt := 0; config.Limit == 0 || cnt < config.Limit; cnt++ {
+				msg, ok, err := it.Next()
+				if err != nil {
+					return err
+				}
+				if !ok {
+					break
+				}
+				cmd.Println(msg)
+			}
+			return nil
+		},
+	}
+	if err := setEnumerateCmdFlags(cmd, fs); err != nil {
+		return nil, err
+	}
+	return cmd, nil
+}
+
+func setEnumerateCmdFlags(cmd *cobra.Command, fs afero.Fs) error {
+	intFlags := []*option.IntFlag{
+		{
+			Flag: &option.Flag{
+				Name:      "limit",
+				Shorthand: "l",
+				Usage:     "max number of messages. if 0, all messages are enumerated",
+			},
+			Value: 0,
+		},
+	}
+
+	for _, intFlag := range intFlags {
+		if err := option.RegisterIntFlag(cmd, intFlag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func init() {
+	cmdGenerators = append(cmdGenerators, newEnumerateCmd)
+}
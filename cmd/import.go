@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"github.com/mpppk/messagen/internal/option"
+	"github.com/mpppk/messagen/messagen"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"golang.org/x/xerrors"
+)
+
+func newImportCmd(fs afero.Fs) (*cobra.Command, error) {
+	cmd := &cobra.Command{
+		Use:   "import",
+		Short: "Convert definitions of other generators to messagen YAML",
+	}
+
+	traceryCmd := &cobra.Command{
+		Use:   "tracery <grammar.json>",
+		Short: "Convert Tracery grammar to messagen YAML",
+		Long: `Convert Tracery JSON grammar file or URL to messagen YAML.
+Rules are converted to definitions, #sym# to {{.sym}}, modifiers like .capitalize and .s to template functions like traceryCapitalize,
+and actions like [hero:#name#] to definitions which assign the value to the state.
+If the grammar has origin rule, Root definition which refers origin is added.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			config, err := option.NewImportCmdConfigFromViper()
+			if err != nil {
+				return err
+			}
+
+			contents, _, err := messagen.ReadFromFileOrUrl(args[0])
+			if err != nil {
+				return err
+			}
+			msgConfig, err := messagen.ParseTracery(contents)
+			if err != nil {
+				return xerrors.Errorf("failed to convert %s: %w", args[0], err)
+			}
+			yamlContents, err := messagen.MarshalYaml(msgConfig)
+			if err != nil {
+				return err
+			}
+
+			if config.Output == "" {
+				cmd.Print(string(yamlContents))
+				return nil
+			}
+			if err := afero.WriteFile(fs, config.Output, yamlContents, 0644); err != nil {
+				return xerrors.Errorf("failed to write definitions to %s: %w", config.Output, err)
+			}
+			return nil
+		},
+	}
+
+	outputFlag := &option.StringFlag{
+		Flag: &option.Flag{
+			Name:      "output",
+			Shorthand: "o",
+			Usage:     "file path which converted YAML is written to. if empty, it is printed",
+		},
+		Value: "",
+	}
+	if err := option.RegisterStringFlag(traceryCmd, outputFlag); err != nil {
+		return nil, err
+	}
+
+	cmd.AddCommand(traceryCmd)
+	return cmd, nil
+}
+
+func init() {
+	cmdGenerators = append(cmdGenerators, newImportCmd)
+}
@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"strings"
+
+	"github.com/mpppk/messagen/internal/option"
+	"github.com/mpppk/messagen/messagen"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"golang.org/x/xerrors"
+)
+
+func newLintCmd(fs afero.Fs) (*cobra.Command, error) {
+	cmd := &cobra.Command{
+		Use:   "lint",
+		Short: "Check definitions statically",
+		Long: `Check definitions statically and print warnings and errors with the index of the offending definition.
+Keys of the initial state are regarded as defined types.
+If any error is found, lint exits with non-zero status.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			config, err := option.NewLintCmdConfigFromViper()
+			if err != nil {
+				return err
+			}
+
+			msgConfig, err := messagen.ParseFilesOrUrls(config.FilePaths...)
+			if err != nil {
+				return err
+			}
+
+			issues := messagen.LintDefinitionsWithFuncMap(config.RootType, config.InitialState, messagen.TraceryFuncMap(), msgConfig.Definitions...)
+			for _, issue := range issues {
+				cmd.Println(issue)
+			}
+			if issues.HasError() {
+				return xerrors.Errorf("errors are found in %s", strings.Join(config.FilePaths, ","))
+			}
+			return nil
+		},
+	}
+	return cmd, nil
+}
+
+func init() {
+	cmdGenerators = append(cmdGenerators, newLintCmd)
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/spf13/afero"
 
 	"github.com/mitchellh/go-homedir"
+	"github.com/mpppk/messagen/internal/option"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -20,6 +21,10 @@ func NewRootCmd(fs afero.Fs) (*cobra.Command, error) {
 		SilenceUsage: true,
 	}
 
+	if err := setRootCmdFlags(cmd); err != nil {
+		return nil, err
+	}
+
 	if err := setFlags(cmd, fs); err != nil {
 		return nil, err
 	}
@@ -27,6 +32,89 @@ func NewRootCmd(fs afero.Fs) (*cobra.Command, error) {
 	return cmd, nil
 }
 
+// setRootCmdFlags registers flags which are shared by sub commands that read definitions.
+func setRootCmdFlags(cmd *cobra.Command) error {
+	stringFlags := []*option.StringFlag{
+		{
+			Flag: &option.Flag{
+				Name:         "file",
+				Shorthand:    "f",
+				IsPersistent: true,
+				Usage:        "target files or URLs in YAML, JSON or TOML. multiple files are separated by comma and merged in order",
+			},
+			Value: "./messagen.yaml",
+		},
+		{
+			Flag: &option.Flag{
+				Name:         "root",
+				IsPersistent: true,
+				Usage:        "Name of definition root type",
+			},
+			Value: "Root",
+		},
+		{
+			Flag: &option.Flag{
+				Name:         "state",
+				Shorthand:    "s",
+				IsPersistent: true,
+				Usage:        "initial state",
+			},
+			Value: "",
+		},
+		{
+			Flag: &option.Flag{
+				Name:         "locale",
+				IsPersistent: true,
+				Usage:        "comma separated locale fallback chain like ja,en. if empty, definitions of all locales are used",
+			},
+			Value: "",
+		},
+		{
+			Flag: &option.Flag{
+				Name:         "timeout",
+				IsPersistent: true,
+				Usage:        "max wall-clock time of the search of run and enumerate like 500ms or 3s. if empty, no limit",
+			},
+			Value: "",
+		},
+	}
+
+	// limits are shared by run and enumerate, and max-depth is limited by default
+	// so that definitions which refer their own type do not make the search endless
+	intFlags := []*option.IntFlag{
+		{
+			Flag: &option.Flag{
+				Name:         "max-depth",
+				ViperName:    "MaxDepth",
+				IsPersistent: true,
+				Usage:        "max depth of derivation trees of run and enumerate. if 0, no limit",
+			},
+			Value: option.DefaultMaxDepth,
+		},
+		{
+			Flag: &option.Flag{
+				Name:         "max-steps",
+				ViperName:    "MaxSteps",
+				IsPersistent: true,
+				Usage:        "max number of templates which are tried in the search of run and enumerate. if 0, no limit",
+			},
+			Value: 0,
+		},
+	}
+
+	for _, stringFlag := range stringFlags {
+		if err := option.RegisterStringFlag(cmd, stringFlag); err != nil {
+			return err
+		}
+	}
+	for _, intFlag := range intFlags {
+		if err := option.RegisterIntFlag(cmd, intFlag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func setFlags(cmd *cobra.Command, fs afero.Fs) error {
 	var subCmds []*cobra.Command
 	for _, cmdGen := range cmdGenerators {
@@ -6,11 +6,14 @@ import (
 	"math"
 	"math/big"
 	"math/rand"
+	"sort"
+	"strings"
 
 	"github.com/mpppk/messagen/internal/option"
 	"github.com/mpppk/messagen/messagen"
 	"github.com/spf13/afero"
 	"github.com/spf13/cobra"
+	"golang.org/x/xerrors"
 )
 
 func newRunCmd(fs afero.Fs) (*cobra.Command, error) {
@@ -24,18 +27,48 @@ func newRunCmd(fs afero.Fs) (*cobra.Command, error) {
 				return err
 			}
 
-			if seed, err := crand.Int(crand.Reader, big.NewInt(math.MaxInt64)); err != nil {
+			seed := config.Seed
+			if seed == 0 {
+				s, err := newRandomSeed()
+				if err != nil {
+					return err
+				}
+				seed = s
+			}
+			cmd.PrintErrln("seed:", seed)
+
+			msgConfig, err := messagen.ParseFilesOrUrls(config.FilePaths...)
+			if err != nil {
 				return err
-			} else {
-				rand.Seed(seed.Int64())
 			}
 
-			msgConfig, err := messagen.ParseYamlFileOrUrl(config.FilePath)
+			postProcess, typePostProcess, err := msgConfig.Transformers()
 			if err != nil {
 				return err
 			}
 
-			generator, err := messagen.New(nil)
+			var history *messagen.HistoryOption
+			if config.HistoryPath != "" {
+				history = &messagen.HistoryOption{
+					Store:              messagen.NewFileHistoryStoreWithRetention(config.HistoryPath, config.AvoidRecent, config.AvoidDuration),
+					AvoidRecent:        config.AvoidRecent,
+					AvoidDuration:      config.AvoidDuration,
+					AvoidTemplateTypes: config.AvoidTemplateTypes,
+				}
+			}
+
+			generator, err := messagen.New(&messagen.Option{
+				RandSource: rand.NewSource(seed),
+				MaxDepth:   config.MaxDepth,
+				MaxSteps:   config.MaxSteps,
+				Timeout:    config.Timeout,
+				History:    history,
+				FuncMap:    messagen.TraceryFuncMap(),
+
+				PostProcess:     postProcess,
+				TypePostProcess: typePostProcess,
+				Locales:         config.Locales,
+			})
 			if err != nil {
 				return err
 			}
@@ -47,8 +80,22 @@ func newRunCmd(fs afero.Fs) (*cobra.Command, error) {
 			if config.Verbose {
 				printState(config.InitialState)
 			}
+			if config.Trace {
+				msgs, err := generator.GenerateDetailed(config.RootType, config.InitialState, uint(config.Num))
+				if err != nil {
+					return explainError(cmd, err)
+				}
+				for _, msg := range msgs {
+					cmd.Println(msg.Message)
+					cmd.Println()
+					printDerivation(cmd, msg.Derivation, "", "")
+					cmd.Println()
+				}
+				return nil
+			}
+
 			if msgs, err := generator.Generate(config.RootType, config.InitialState, uint(config.Num)); err != nil {
-				return err
+				return explainError(cmd, err)
 			} else {
 				for _, msg := range msgs {
 					cmd.Println(msg)
@@ -64,6 +111,15 @@ func newRunCmd(fs afero.Fs) (*cobra.Command, error) {
 	return cmd, nil
 }
 
+// newRandomSeed returns a non-zero seed from crypto/rand.
+func newRandomSeed() (int64, error) {
+	seed, err := crand.Int(crand.Reader, big.NewInt(math.MaxInt64-1))
+	if err != nil {
+		return 0, err
+	}
+	return seed.Int64() + 1, nil
+}
+
 func printState(state map[string]string) {
 	if len(state) == 0 {
 		return
@@ -76,6 +132,55 @@ func printState(state map[string]string) {
 	fmt.Println()
 }
 
+// explainError prints why candidates were rejected to stderr if err is NoMessageError, then returns err.
+func explainError(cmd *cobra.Command, err error) error {
+	var noMsgErr *messagen.NoMessageError
+	if xerrors.As(err, &noMsgErr) {
+		cmd.PrintErr(noMsgErr.Explain())
+	}
+	return err
+}
+
+// printDerivation prints the derivation tree like below.
+//
+//	Root#0: "{{.Pronoun}} is {{.FirstName}}." -> "She is Emily."
+//	├── Pronoun#1: "She"
+//	└── FirstName#3: "Emily"
+func printDerivation(cmd *cobra.Command, derivation *messagen.Derivation, prefix, childPrefix string) {
+	if derivation == nil {
+		return
+	}
+	name := derivation.Type
+	if derivation.Alias != "" {
+		name = fmt.Sprintf("%s(%s)", derivation.Alias, derivation.Type)
+	}
+	line := fmt.Sprintf("%s%s#%d: %q", prefix, name, derivation.DefinitionID, derivation.Template)
+	if derivation.Template != derivation.Message {
+		line += fmt.Sprintf(" -> %q", derivation.Message)
+	}
+	if len(derivation.AddedState) > 0 {
+		var keys []string
+		for key := range derivation.AddedState {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		var kvList []string
+		for _, key := range keys {
+			kvList = append(kvList, key+"="+derivation.AddedState[key])
+		}
+		line += fmt.Sprintf(" [+%s]", strings.Join(kvList, ", +"))
+	}
+	cmd.Println(line)
+
+	for i, child := range derivation.Children {
+		if i == len(derivation.Children)-1 {
+			printDerivation(cmd, child, childPrefix+"└── ", childPrefix+"    ")
+		} else {
+			printDerivation(cmd, child, childPrefix+"├── ", childPrefix+"│   ")
+		}
+	}
+}
+
 func setRunCmdFlags(cmd *cobra.Command, fs afero.Fs) error {
 	stringFlags := []*option.StringFlag{
 		{
@@ -87,24 +192,16 @@ func setRunCmdFlags(cmd *cobra.Command, fs afero.Fs) error {
 		},
 		{
 			Flag: &option.Flag{
-				Name:      "file",
-				Shorthand: "f",
-				Usage:     "target file",
-			},
-			Value: "./messagen.yaml",
-		},
-		{
-			Flag: &option.Flag{
-				Name:  "root",
-				Usage: "Name of definition root type",
+				Name:  "history",
+				Usage: "JSON file path of the history. generated messages are added to it, and recent messages in it are not generated again",
 			},
-			Value: "Root",
+			Value: "",
 		},
 		{
 			Flag: &option.Flag{
-				Name:      "state",
-				Shorthand: "s",
-				Usage:     "initial state",
+				Name:      "avoid-templates",
+				ViperName: "AvoidTemplates",
+				Usage:     "comma separated definition types whose templates in recent messages are not picked like Greeting,Closing",
 			},
 			Value: "",
 		},
@@ -119,6 +216,29 @@ func setRunCmdFlags(cmd *cobra.Command, fs afero.Fs) error {
 			},
 			Value: 1,
 		},
+		{
+			Flag: &option.Flag{
+				Name:  "seed",
+				Usage: "random seed. the same seed generates the same messages. if 0, seed is picked at random",
+			},
+			Value: 0,
+		},
+		{
+			Flag: &option.Flag{
+				Name:      "avoid-recent",
+				ViperName: "AvoidRecent",
+				Usage:     "number of latest messages in the history which are not generated again",
+			},
+			Value: 0,
+		},
+		{
+			Flag: &option.Flag{
+				Name:      "avoid-days",
+				ViperName: "AvoidDays",
+				Usage:     "messages in the history which are generated within the days are not generated again",
+			},
+			Value: 0,
+		},
 	}
 
 	boolFlags := []*option.BoolFlag{
@@ -130,6 +250,13 @@ func setRunCmdFlags(cmd *cobra.Command, fs afero.Fs) error {
 			},
 			Value: false,
 		},
+		{
+			Flag: &option.Flag{
+				Name:  "trace",
+				Usage: "print derivation tree of each message",
+			},
+			Value: false,
+		},
 	}
 
 	for _, stringFlag := range stringFlags {
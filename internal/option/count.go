@@ -0,0 +1,51 @@
+package option
+
+import (
+	"github.com/spf13/viper"
+	"golang.org/x/xerrors"
+)
+
+type CountCmdConfig struct {
+	FilePaths    []string
+	RootType     string
+	InitialState map[string]string
+	// Locales is the locale fallback chain. If it is empty, definitions of all locales are used.
+	Locales []string
+}
+
+func NewCountCmdConfigFromViper() (*CountCmdConfig, error) {
+	rawConfig, err := newCountCmdRawConfig()
+	if err != nil {
+		return nil, err
+	}
+	return newCountCmdConfigFromRawConfig(rawConfig)
+}
+
+func newCountCmdConfigFromRawConfig(rawConfig *CountCmdRawConfig) (*CountCmdConfig, error) {
+	state, err := parseKVStr(rawConfig.State)
+	if err != nil {
+		return nil, err
+	}
+	return &CountCmdConfig{
+		FilePaths:    splitFilePaths(rawConfig.File),
+		RootType:     rawConfig.Root,
+		InitialState: state,
+		Locales:      splitLocales(rawConfig.Locale),
+	}, nil
+}
+
+func newCountCmdRawConfig() (*CountCmdRawConfig, error) {
+	var conf CountCmdRawConfig
+	if err := viper.Unmarshal(&conf); err != nil {
+		return nil, xerrors.Errorf("failed to unmarshal count command config from viper: %w", err)
+	}
+
+	return &conf, nil
+}
+
+type CountCmdRawConfig struct {
+	File   string
+	Root   string
+	State  string
+	Locale string
+}
@@ -0,0 +1,73 @@
+package option
+
+import (
+	"time"
+
+	"github.com/spf13/viper"
+	"golang.org/x/xerrors"
+)
+
+type EnumerateCmdConfig struct {
+	FilePaths    []string
+	RootType     string
+	Limit        int
+	InitialState map[string]string
+	MaxDepth     int
+	MaxSteps     int
+	Timeout      time.Duration
+	// Locales is the locale fallback chain. If it is empty, definitions of all locales are used.
+	Locales []string
+}
+
+func NewEnumerateCmdConfigFromViper() (*EnumerateCmdConfig, error) {
+	rawConfig, err := newEnumerateCmdRawConfig()
+	if err != nil {
+		return nil, err
+	}
+	return newEnumerateCmdConfigFromRawConfig(rawConfig)
+}
+
+func newEnumerateCmdConfigFromRawConfig(rawConfig *EnumerateCmdRawConfig) (*EnumerateCmdConfig, error) {
+	state, err := parseKVStr(rawConfig.State)
+	if err != nil {
+		return nil, err
+	}
+	if rawConfig.Limit < 0 {
+		return nil, xerrors.Errorf("limit must be zero or positive: %d", rawConfig.Limit)
+	}
+	timeout, err := parseLimits(rawConfig.MaxDepth, rawConfig.MaxSteps, rawConfig.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	return &EnumerateCmdConfig{
+		FilePaths:    splitFilePaths(rawConfig.File),
+		RootType:     rawConfig.Root,
+		Limit:        rawConfig.Limit,
+		InitialState: state,
+		MaxDepth:     rawConfig.MaxDepth,
+		MaxSteps:     rawConfig.MaxSteps,
+		Timeout:      timeout,
+		Locales:      splitLocales(rawConfig.Locale),
+	}, nil
+}
+
+func newEnumerateCmdRawConfig() (*EnumerateCmdRawConfig, error) {
+	var conf EnumerateCmdRawConfig
+	if err := viper.Unmarshal(&conf); err != nil {
+		return nil, xerrors.Errorf("failed to unmarshal enumerate command config from viper: %w", err)
+	}
+
+	return &conf, nil
+}
+
+type EnumerateCmdRawConfig struct {
+	File   string
+	Root   string
+	Limit  int
+	State  string
+	Locale string
+
+	MaxDepth int
+	MaxSteps int
+	Timeout  string
+}
@@ -0,0 +1,38 @@
+package option
+
+import (
+	"github.com/spf13/viper"
+	"golang.org/x/xerrors"
+)
+
+type ImportCmdConfig struct {
+	// Output is the file path which converted definitions are written to. If it is empty, they are printed.
+	Output string
+}
+
+func NewImportCmdConfigFromViper() (*ImportCmdConfig, error) {
+	rawConfig, err := newImportCmdRawConfig()
+	if err != nil {
+		return nil, err
+	}
+	return newImportCmdConfigFromRawConfig(rawConfig), nil
+}
+
+func newImportCmdConfigFromRawConfig(rawConfig *ImportCmdRawConfig) *ImportCmdConfig {
+	return &ImportCmdConfig{
+		Output: rawConfig.Output,
+	}
+}
+
+func newImportCmdRawConfig() (*ImportCmdRawConfig, error) {
+	var conf ImportCmdRawConfig
+	if err := viper.Unmarshal(&conf); err != nil {
+		return nil, xerrors.Errorf("failed to unmarshal import command config from viper: %w", err)
+	}
+
+	return &conf, nil
+}
+
+type ImportCmdRawConfig struct {
+	Output string
+}
@@ -0,0 +1,47 @@
+package option
+
+import (
+	"github.com/spf13/viper"
+	"golang.org/x/xerrors"
+)
+
+type LintCmdConfig struct {
+	FilePaths    []string
+	RootType     string
+	InitialState map[string]string
+}
+
+func NewLintCmdConfigFromViper() (*LintCmdConfig, error) {
+	rawConfig, err := newLintCmdRawConfig()
+	if err != nil {
+		return nil, err
+	}
+	return newLintCmdConfigFromRawConfig(rawConfig)
+}
+
+func newLintCmdConfigFromRawConfig(rawConfig *LintCmdRawConfig) (*LintCmdConfig, error) {
+	state, err := parseKVStr(rawConfig.State)
+	if err != nil {
+		return nil, err
+	}
+	return &LintCmdConfig{
+		FilePaths:    splitFilePaths(rawConfig.File),
+		RootType:     rawConfig.Root,
+		InitialState: state,
+	}, nil
+}
+
+func newLintCmdRawConfig() (*LintCmdRawConfig, error) {
+	var conf LintCmdRawConfig
+	if err := viper.Unmarshal(&conf); err != nil {
+		return nil, xerrors.Errorf("failed to unmarshal lint command config from viper: %w", err)
+	}
+
+	return &conf, nil
+}
+
+type LintCmdRawConfig struct {
+	File  string
+	Root  string
+	State string
+}
@@ -3,17 +3,30 @@ package option
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 	"golang.org/x/xerrors"
 )
 
 type RunCmdConfig struct {
-	FilePath     string
+	FilePaths    []string
 	RootType     string
 	Num          int
 	InitialState map[string]string
 	Verbose      bool
+	Seed         int64
+	Trace        bool
+	MaxDepth     int
+	MaxSteps     int
+	Timeout      time.Duration
+	// HistoryPath is the file path of the history. If it is empty, the history is not used.
+	HistoryPath        string
+	AvoidRecent        int
+	AvoidDuration      time.Duration
+	AvoidTemplateTypes []string
+	// Locales is the locale fallback chain. If it is empty, definitions of all locales are used.
+	Locales []string
 }
 
 func NewRunCmdConfigFromViper() (*RunCmdConfig, error) {
@@ -29,15 +42,81 @@ func newRunCmdConfigFromRawConfig(rawConfig *RunCmdRawConfig) (*RunCmdConfig, er
 	if err != nil {
 		return nil, err
 	}
+	timeout, err := parseLimits(rawConfig.MaxDepth, rawConfig.MaxSteps, rawConfig.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	if rawConfig.AvoidRecent < 0 {
+		return nil, xerrors.Errorf("avoid-recent must be zero or positive: %d", rawConfig.AvoidRecent)
+	}
+	if rawConfig.AvoidDays < 0 {
+		return nil, xerrors.Errorf("avoid-days must be zero or positive: %d", rawConfig.AvoidDays)
+	}
+	if rawConfig.History == "" && (rawConfig.AvoidRecent > 0 || rawConfig.AvoidDays > 0 || rawConfig.AvoidTemplates != "") {
+		return nil, xerrors.Errorf("history must be specified to avoid recent messages")
+	}
+	var avoidTemplateTypes []string
+	if rawConfig.AvoidTemplates != "" {
+		avoidTemplateTypes = strings.Split(rawConfig.AvoidTemplates, ",")
+	}
 	return &RunCmdConfig{
-		FilePath:     rawConfig.File,
+		FilePaths:    splitFilePaths(rawConfig.File),
 		RootType:     rawConfig.Root,
 		Num:          rawConfig.Num,
 		InitialState: state,
 		Verbose:      rawConfig.Verbose,
+		Seed:         rawConfig.Seed,
+		Trace:        rawConfig.Trace,
+		MaxDepth:     rawConfig.MaxDepth,
+		MaxSteps:     rawConfig.MaxSteps,
+		Timeout:      timeout,
+
+		HistoryPath:        rawConfig.History,
+		AvoidRecent:        rawConfig.AvoidRecent,
+		AvoidDuration:      time.Duration(rawConfig.AvoidDays) * 24 * time.Hour,
+		AvoidTemplateTypes: avoidTemplateTypes,
+		Locales:            splitLocales(rawConfig.Locale),
 	}, nil
 }
 
+// splitFilePaths splits comma separated file paths or URLs.
+func splitFilePaths(file string) []string {
+	if file == "" {
+		return nil
+	}
+	return strings.Split(file, ",")
+}
+
+// DefaultMaxDepth is the default max depth of derivation trees.
+// Deep derivations are unusual, and the limit stops definitions which refer their own type before they use up the stack.
+const DefaultMaxDepth = 100
+
+// parseLimits validates limits of the search and returns the parsed timeout.
+func parseLimits(maxDepth, maxSteps int, timeout string) (time.Duration, error) {
+	if maxDepth < 0 {
+		return 0, xerrors.Errorf("max-depth must be zero or positive: %d", maxDepth)
+	}
+	if maxSteps < 0 {
+		return 0, xerrors.Errorf("max-steps must be zero or positive: %d", maxSteps)
+	}
+	if timeout == "" {
+		return 0, nil
+	}
+	t, err := time.ParseDuration(timeout)
+	if err != nil {
+		return 0, xerrors.Errorf("invalid timeout(%s): %w", timeout, err)
+	}
+	return t, nil
+}
+
+// splitLocales splits the comma separated locale fallback chain.
+func splitLocales(locale string) []string {
+	if locale == "" {
+		return nil
+	}
+	return strings.Split(locale, ",")
+}
+
 func parseKVStr(kvListStr string) (map[string]string, error) {
 	m := map[string]string{}
 	if kvListStr == "" {
@@ -65,9 +144,20 @@ func newRunCmdRawConfig() (*RunCmdRawConfig, error) {
 }
 
 type RunCmdRawConfig struct {
-	File    string
-	Root    string
-	Num     int
-	State   string // TODO: viper cannot parse map[string]string correctly. See https://github.com/spf13/viper/issues/608
-	Verbose bool
+	File     string
+	Root     string
+	Num      int
+	State    string // TODO: viper cannot parse map[string]string correctly. See https://github.com/spf13/viper/issues/608
+	Verbose  bool
+	Seed     int64
+	Trace    bool
+	MaxDepth int
+	MaxSteps int
+	Timeout  string
+
+	History        string
+	AvoidRecent    int
+	AvoidDays      int
+	AvoidTemplates string
+	Locale         string
 }
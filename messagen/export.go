@@ -9,5 +9,35 @@ type Templates = internal.Templates
 type TemplatePicker = internal.TemplatePicker
 type DefinitionPicker = internal.DefinitionPicker
 type TemplateValidator = internal.TemplateValidator
+type MessageTransformer = internal.MessageTransformer
+
+// NoMessageError is returned by Generate when no valid message exists. Explain returns why candidates were rejected.
+type NoMessageError = internal.NoMessageError
+type RejectionSummary = internal.RejectionSummary
+type Rejection = internal.Rejection
+
+// LimitExceededError is returned when a search exceeds MaxDepth, MaxSteps or Timeout of Option.
+type LimitExceededError = internal.LimitExceededError
+type LimitKind = internal.LimitKind
+type LintIssue = internal.LintIssue
+type LintIssues = internal.LintIssues
+type LintSeverity = internal.LintSeverity
+
+const (
+	DepthLimit = internal.DepthLimit
+	StepLimit  = internal.StepLimit
+	TimeLimit  = internal.TimeLimit
+
+	LintWarning = internal.LintWarning
+	LintError   = internal.LintError
+)
 
 var RandomTemplatePicker = internal.RandomTemplatePicker
+
+var (
+	TrimTransformer                 = internal.TrimTransformer
+	CollapseSpaceTransformer        = internal.CollapseSpaceTransformer
+	CapitalizeTransformer           = internal.CapitalizeTransformer
+	ArticleTransformer              = internal.ArticleTransformer
+	NormalizePunctuationTransformer = internal.NormalizePunctuationTransformer
+)
@@ -0,0 +1,103 @@
+package messagen
+
+import (
+	"encoding/json"
+	"mime"
+	"path"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"golang.org/x/xerrors"
+	"gopkg.in/yaml.v3"
+)
+
+// Format is the format of definition files.
+type Format string
+
+const (
+	FormatYAML Format = "yaml"
+	FormatJSON Format = "json"
+	FormatTOML Format = "toml"
+)
+
+// Parse parses contents in the format.
+func Parse(contents []byte, format Format) (*Config, error) {
+	switch format {
+	case FormatYAML:
+		return ParseYaml(contents)
+	case FormatJSON:
+		return ParseJSON(contents)
+	case FormatTOML:
+		return ParseTOML(contents)
+	}
+	return nil, xerrors.Errorf("unknown format: %s", format)
+}
+
+// ParseJSON parses contents in JSON. The structure is the same as YAML.
+func ParseJSON(contents []byte) (*Config, error) {
+	var value map[string]interface{}
+	if err := json.Unmarshal(contents, &value); err != nil {
+		return nil, xerrors.Errorf("failed to parse json: %w", err)
+	}
+	config, err := decodeValue(value)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to parse json: %w", err)
+	}
+	return config, nil
+}
+
+// ParseTOML parses contents in TOML. The structure is the same as YAML, so definitions are written as [[Definitions]].
+func ParseTOML(contents []byte) (*Config, error) {
+	var value map[string]interface{}
+	if err := toml.Unmarshal(contents, &value); err != nil {
+		return nil, xerrors.Errorf("failed to parse toml: %w", err)
+	}
+	config, err := decodeValue(value)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to parse toml: %w", err)
+	}
+	return config, nil
+}
+
+// decodeValue decodes the generic value of a config through YAML nodes,
+// so lists in Constraints and templates written as objects are decoded like YAML.
+func decodeValue(value map[string]interface{}) (*Config, error) {
+	var node yaml.Node
+	if err := node.Encode(value); err != nil {
+		return nil, err
+	}
+	config := Config{Definitions: []*Definition{}}
+	if err := node.Decode(&config); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// DetectFormat detects the format by the extension of the file path or URL, or the content type of the response.
+// If the format can not be detected, YAML is returned.
+func DetectFormat(filePathOrUrl, contentType string) Format {
+	p := filePathOrUrl
+	if isUrl(filePathOrUrl) {
+		p = strings.SplitN(strings.SplitN(p, "?", 2)[0], "#", 2)[0]
+	}
+	switch strings.ToLower(path.Ext(p)) {
+	case ".json":
+		return FormatJSON
+	case ".toml":
+		return FormatTOML
+	case ".yaml", ".yml":
+		return FormatYAML
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return FormatYAML
+	}
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		return FormatJSON
+	case mediaType == "application/toml" || mediaType == "text/toml":
+		return FormatTOML
+	}
+	return FormatYAML
+}
@@ -0,0 +1,181 @@
+package messagen
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/mpppk/messagen/messagen/internal"
+	"golang.org/x/xerrors"
+)
+
+// HistoryEntry is a record of a generated message.
+type HistoryEntry struct {
+	Message string `json:"message"`
+	// Templates are the picked templates of each definition type in the derivation of the message.
+	Templates   map[string][]string `json:"templates,omitempty"`
+	GeneratedAt time.Time           `json:"generatedAt"`
+}
+
+// HistoryStore stores generated messages across runs.
+type HistoryStore interface {
+	// Entries returns the stored entries in generated order.
+	Entries() ([]*HistoryEntry, error)
+	// Add stores the entries.
+	Add(entries ...*HistoryEntry) error
+}
+
+// FileHistoryStore is HistoryStore which stores entries to a JSON file.
+// The file is created when entries are added first, and it is replaced atomically, so a crash while writing does not break it.
+// The file grows by each Add unless the retention is set by NewFileHistoryStoreWithRetention.
+type FileHistoryStore struct {
+	filePath string
+	// keepRecent and keepDuration are the retention of entries. Zero values of both mean all entries are kept.
+	keepRecent   int
+	keepDuration time.Duration
+}
+
+func NewFileHistoryStore(filePath string) *FileHistoryStore {
+	return &FileHistoryStore{filePath: filePath}
+}
+
+// NewFileHistoryStoreWithRetention returns FileHistoryStore which removes old entries when entries are added.
+// Entries are kept if they are one of the latest keepRecent entries or generated within keepDuration,
+// so passing AvoidRecent and AvoidDuration of HistoryOption keeps entries which can still be avoided.
+// If both are zero, all entries are kept.
+func NewFileHistoryStoreWithRetention(filePath string, keepRecent int, keepDuration time.Duration) *FileHistoryStore {
+	return &FileHistoryStore{filePath: filePath, keepRecent: keepRecent, keepDuration: keepDuration}
+}
+
+// Entries returns the entries in the file. If the file does not exist, it returns no entries.
+func (f *FileHistoryStore) Entries() ([]*HistoryEntry, error) {
+	contents, err := ioutil.ReadFile(f.filePath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, xerrors.Errorf("failed to read history from %s: %w", f.filePath, err)
+	}
+	var entries []*HistoryEntry
+	if err := json.Unmarshal(contents, &entries); err != nil {
+		return nil, xerrors.Errorf("failed to parse history in %s: %w", f.filePath, err)
+	}
+	return entries, nil
+}
+
+// Add appends the entries to the file, and removes entries which are out of the retention.
+func (f *FileHistoryStore) Add(entries ...*HistoryEntry) error {
+	storedEntries, err := f.Entries()
+	if err != nil {
+		return err
+	}
+	newEntries := append(storedEntries, entries...)
+	if f.keepRecent > 0 || f.keepDuration > 0 {
+		now := time.Now()
+		var keptEntries []*HistoryEntry
+		for i, entry := range newEntries {
+			if isRecentEntry(newEntries, i, f.keepRecent, f.keepDuration, now) {
+				keptEntries = append(keptEntries, entry)
+			}
+		}
+		newEntries = keptEntries
+	}
+	contents, err := json.MarshalIndent(newEntries, "", "  ")
+	if err != nil {
+		return xerrors.Errorf("failed to marshal history: %w", err)
+	}
+	if err := writeFileAtomically(f.filePath, contents, 0644); err != nil {
+		return xerrors.Errorf("failed to write history to %s: %w", f.filePath, err)
+	}
+	return nil
+}
+
+// writeFileAtomically writes the contents to a temporary file in the same directory, then renames it to filePath.
+func writeFileAtomically(filePath string, contents []byte, perm os.FileMode) (err error) {
+	tmpFile, err := ioutil.TempFile(filepath.Dir(filePath), filepath.Base(filePath)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tmpFile.Close()
+			_ = os.Remove(tmpFile.Name())
+		}
+	}()
+	if _, err = tmpFile.Write(contents); err != nil {
+		return err
+	}
+	if err = tmpFile.Sync(); err != nil {
+		return err
+	}
+	if err = tmpFile.Chmod(perm); err != nil {
+		return err
+	}
+	if err = tmpFile.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile.Name(), filePath)
+}
+
+// isRecentEntry reports whether the i-th entry is one of the latest recent entries or generated within duration before now.
+func isRecentEntry(entries []*HistoryEntry, i, recent int, duration time.Duration, now time.Time) bool {
+	isLatest := len(entries)-i <= recent
+	isWithinDuration := duration > 0 && now.Sub(entries[i].GeneratedAt) <= duration
+	return isLatest || isWithinDuration
+}
+
+// HistoryOption configures the history of generated messages.
+// Generated messages are added to Store, and messages in recent entries are never generated again.
+// An entry is recent if it is one of the latest AvoidRecent entries or it is generated within AvoidDuration.
+type HistoryOption struct {
+	Store HistoryStore
+	// AvoidRecent is the number of latest entries which are avoided.
+	AvoidRecent int
+	// AvoidDuration is the duration in which entries are avoided.
+	AvoidDuration time.Duration
+	// AvoidTemplateTypes are the definition types whose templates in recent entries are not picked in addition to messages.
+	AvoidTemplateTypes []string
+}
+
+// newHistory returns the history of recent entries in the store.
+func (h *HistoryOption) newHistory(now time.Time) (*internal.History, error) {
+	entries, err := h.Store.Entries()
+	if err != nil {
+		return nil, xerrors.Errorf("failed to load history: %w", err)
+	}
+	history := internal.NewHistory()
+	for i, entry := range entries {
+		if !isRecentEntry(entries, i, h.AvoidRecent, h.AvoidDuration, now) {
+			continue
+		}
+		history.AddMessage(internal.Message(entry.Message))
+		for _, defType := range h.AvoidTemplateTypes {
+			for _, template := range entry.Templates[defType] {
+				history.AddTemplate(internal.DefinitionType(defType), internal.RawTemplate(template))
+			}
+		}
+	}
+	return history, nil
+}
+
+func newHistoryEntry(msg *internal.DetailedMessage, now time.Time) *HistoryEntry {
+	entry := &HistoryEntry{
+		Message:     string(msg.Message),
+		Templates:   map[string][]string{},
+		GeneratedAt: now,
+	}
+	var walk func(d *internal.Derivation)
+	walk = func(d *internal.Derivation) {
+		if d == nil {
+			return
+		}
+		entry.Templates[string(d.Type)] = append(entry.Templates[string(d.Type)], string(d.Template))
+		for _, child := range d.Children {
+			walk(child)
+		}
+	}
+	walk(msg.Derivation)
+	return entry
+}
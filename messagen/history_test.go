@@ -0,0 +1,115 @@
+package messagen
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/mpppk/messagen/messagen/internal"
+)
+
+func TestMessagen_Generate_WithHistory(t *testing.T) {
+	store := NewFileHistoryStore(filepath.Join(t.TempDir(), "history.json"))
+	generator, err := New(&Option{History: &HistoryOption{Store: store, AvoidRecent: 3}})
+	if err != nil {
+		t.Fatalf("unexpected error occurred in New(): %s", err)
+	}
+	if err := generator.AddDefinition(
+		&Definition{Type: "Root", Templates: []string{"{{.Greeting}} {{.Name}}"}},
+		&Definition{Type: "Greeting", Templates: []string{"hello", "hi"}},
+		&Definition{Type: "Name", Templates: []string{"alice", "bob"}},
+	); err != nil {
+		t.Fatalf("unexpected error occurred in AddDefinition(): %s", err)
+	}
+
+	// each message is generated once in 4 generations because the latest 3 messages are avoided
+	var msgs []string
+	for i := 0; i < 4; i++ {
+		got, err := generator.Generate("Root", nil, 1)
+		if err != nil {
+			t.Fatalf("unexpected error occurred in Generate(): %s", err)
+		}
+		msgs = append(msgs, got...)
+	}
+	sort.Strings(msgs)
+	if want := []string{"hello alice", "hello bob", "hi alice", "hi bob"}; !reflect.DeepEqual(msgs, want) {
+		t.Errorf("Generate() = %v, want %v", msgs, want)
+	}
+
+	entries, err := store.Entries()
+	if err != nil {
+		t.Fatalf("unexpected error occurred in FileHistoryStore.Entries(): %s", err)
+	}
+	if len(entries) != 4 {
+		t.Fatalf("history should have 4 entries: %v", entries)
+	}
+	if got := entries[0].Templates["Root"]; !reflect.DeepEqual(got, []string{"{{.Greeting}} {{.Name}}"}) {
+		t.Errorf("templates of Root in history = %v", got)
+	}
+}
+
+func TestHistoryOption_newHistory(t *testing.T) {
+	now := time.Date(2020, 1, 10, 0, 0, 0, 0, time.UTC)
+	store := NewFileHistoryStore(filepath.Join(t.TempDir(), "history.json"))
+	if err := store.Add(
+		&HistoryEntry{Message: "old", Templates: map[string][]string{"Greeting": {"hello"}}, GeneratedAt: now.Add(-72 * time.Hour)},
+		&HistoryEntry{Message: "yesterday", Templates: map[string][]string{"Greeting": {"hi"}}, GeneratedAt: now.Add(-24 * time.Hour)},
+		&HistoryEntry{Message: "latest", Templates: map[string][]string{"Greeting": {"hey"}}, GeneratedAt: now.Add(-96 * time.Hour)},
+	); err != nil {
+		t.Fatalf("unexpected error occurred in FileHistoryStore.Add(): %s", err)
+	}
+	option := &HistoryOption{Store: store, AvoidRecent: 1, AvoidDuration: 48 * time.Hour, AvoidTemplateTypes: []string{"Greeting"}}
+	history, err := option.newHistory(now)
+	if err != nil {
+		t.Fatalf("unexpected error occurred in newHistory(): %s", err)
+	}
+	for msg, want := range map[string]bool{"old": false, "yesterday": true, "latest": true} {
+		if got := history.HasMessage(internal.Message(msg)); got != want {
+			t.Errorf("history has message %q = %v, want %v", msg, got, want)
+		}
+	}
+	if !history.HasTemplate("Greeting", "hi") || history.HasTemplate("Greeting", "hello") {
+		t.Errorf("history should have only templates of recent entries")
+	}
+}
+
+func TestFileHistoryStore_AddWithRetention(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	store := NewFileHistoryStoreWithRetention(filepath.Join(dir, "history.json"), 2, 48*time.Hour)
+	for _, entry := range []*HistoryEntry{
+		{Message: "old", GeneratedAt: now.Add(-96 * time.Hour)},
+		{Message: "yesterday", GeneratedAt: now.Add(-24 * time.Hour)},
+		{Message: "older but not latest", GeneratedAt: now.Add(-72 * time.Hour)},
+		{Message: "previous", GeneratedAt: now.Add(-96 * time.Hour)},
+		{Message: "latest", GeneratedAt: now.Add(-96 * time.Hour)},
+	} {
+		if err := store.Add(entry); err != nil {
+			t.Fatalf("unexpected error occurred in FileHistoryStore.Add(): %s", err)
+		}
+	}
+
+	entries, err := store.Entries()
+	if err != nil {
+		t.Fatalf("unexpected error occurred in FileHistoryStore.Entries(): %s", err)
+	}
+	var got []string
+	for _, entry := range entries {
+		got = append(got, entry.Message)
+	}
+	if want := []string{"yesterday", "previous", "latest"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("FileHistoryStore.Entries() = %v, want %v", got, want)
+	}
+
+	// temporary files are renamed to the history file
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0].Name() != "history.json" {
+		t.Errorf("directory of history has unexpected files: %v", files)
+	}
+}
@@ -0,0 +1,75 @@
+package internal
+
+import (
+	"sort"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+// RawAssignmentKey is the state key of an assignment.
+// The key with ? like "Formality?" is assigned only if the state does not have the key.
+type RawAssignmentKey string
+
+// RawAssignments are state assignments which are applied when a definition is picked.
+// Values are templates which are executed with the state like "{{.FirstName}} {{.LastName}}".
+type RawAssignments map[RawAssignmentKey]RawTemplate
+
+// Assignment assigns the message of the template to the state key.
+type Assignment struct {
+	Key DefinitionType
+	// OnlyIfAbsent is true if the message is assigned only if the state does not have the key.
+	// Otherwise the state value is overwritten.
+	OnlyIfAbsent bool
+	Template     *Template
+}
+
+// NewAssignments returns assignments sorted by key. Templates can use funcs. If funcs is nil, built-in functions are used.
+func NewAssignments(raw RawAssignments, funcs *FuncSet) ([]*Assignment, error) {
+	var assignments []*Assignment
+	for rawKey, rawTemplate := range raw {
+		key := strings.TrimSuffix(string(rawKey), "?")
+		if key == "" {
+			return nil, xerrors.Errorf("failed to create assignment: key is empty (%s)", rawKey)
+		}
+		template, err := NewTemplateWithFuncs(rawTemplate, nil, funcs)
+		if err != nil {
+			return nil, xerrors.Errorf("failed to create assignment of %s: %w", rawKey, err)
+		}
+		assignments = append(assignments, &Assignment{
+			Key:          DefinitionType(key),
+			OnlyIfAbsent: key != string(rawKey),
+			Template:     template,
+		})
+	}
+	sort.Slice(assignments, func(i, j int) bool { return assignments[i].Key < assignments[j].Key })
+	return assignments, nil
+}
+
+// SetByAssignments applies the assignments to the state.
+// All templates are executed before any value is assigned, so assignments do not see each other.
+func (s *State) SetByAssignments(assignments []*Assignment) error {
+	msgs := make([]Message, len(assignments))
+	skipped := make([]bool, len(assignments))
+	for i, assignment := range assignments {
+		if _, ok := s.Get(assignment.Key); ok && assignment.OnlyIfAbsent {
+			skipped[i] = true
+			continue
+		}
+		if defType, ok := assignment.Template.GetFirstUnsatisfiedDef(s); ok {
+			return xerrors.Errorf("failed to assign %s: %s does not exist in the state", assignment.Key, defType)
+		}
+		msg, err := assignment.Template.Execute(s)
+		if err != nil {
+			return xerrors.Errorf("failed to assign %s: %w", assignment.Key, err)
+		}
+		msgs[i] = msg
+	}
+
+	for i, assignment := range assignments {
+		if !skipped[i] {
+			s.Set(assignment.Key, msgs[i])
+		}
+	}
+	return nil
+}
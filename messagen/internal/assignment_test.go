@@ -0,0 +1,147 @@
+package internal
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestState_SetByAssignments(t *testing.T) {
+	tests := []struct {
+		name      string
+		state     MessageMap
+		raw       RawAssignments
+		wantState MessageMap
+		wantErr   bool
+	}{
+		{
+			name:      "value is assigned",
+			state:     MessageMap{"FirstName": "Alice"},
+			raw:       RawAssignments{"Gender": "Female"},
+			wantState: MessageMap{"FirstName": "Alice", "Gender": "Female"},
+		},
+		{
+			name:      "value overwrites state value",
+			state:     MessageMap{"FirstName": "Alice", "Formality": "Casual"},
+			raw:       RawAssignments{"Formality": "Polite"},
+			wantState: MessageMap{"FirstName": "Alice", "Formality": "Polite"},
+		},
+		{
+			name:      "value with ? is not assigned if state has the key",
+			state:     MessageMap{"FirstName": "Alice", "Formality": "Casual"},
+			raw:       RawAssignments{"Formality?": "Polite", "Origin?": "UK"},
+			wantState: MessageMap{"FirstName": "Alice", "Formality": "Casual", "Origin": "UK"},
+		},
+		{
+			name:      "template is executed with state",
+			state:     MessageMap{"FirstName": "Alice", "LastName": "Smith"},
+			raw:       RawAssignments{"FullName": "{{.FirstName}} {{upper .LastName}}"},
+			wantState: MessageMap{"FirstName": "Alice", "LastName": "Smith", "FullName": "Alice SMITH"},
+		},
+		{
+			name:      "assignments do not see each other",
+			state:     MessageMap{"A": "a"},
+			raw:       RawAssignments{"A": "b", "B": "{{.A}}"},
+			wantState: MessageMap{"A": "b", "B": "a"},
+		},
+		{
+			name:    "template which refers missing value is error",
+			state:   MessageMap{},
+			raw:     RawAssignments{"FullName": "{{.FirstName}}"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assignments, err := NewAssignments(tt.raw, nil)
+			if err != nil {
+				t.Fatalf("unexpected error occurred in NewAssignments(): %s", err)
+			}
+			s := NewState(tt.state)
+			if err := s.SetByAssignments(assignments); (err != nil) != tt.wantErr {
+				t.Fatalf("State.SetByAssignments() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(s.m, tt.wantState) {
+				t.Errorf("State.SetByAssignments() state = %v, want %v", s.m, tt.wantState)
+			}
+		})
+	}
+}
+
+func TestDefinitionRepository_Generate_WithAssignments(t *testing.T) {
+	defs := []*RawDefinition{
+		{Type: "Test", RawTemplates: []RawTemplate{"{{.FirstName}}: {{.Greeting}}"}},
+		{Type: "FirstName", RawTemplates: []RawTemplate{"Alice"}, Sets: RawAssignments{"Gender": "Female", "Formality?": "Polite"}},
+		{Type: "Greeting", RawTemplates: []RawTemplate{"Hi"}, RawConstraints: RawConstraints{"Formality": "Casual"}},
+		{Type: "Greeting", RawTemplates: []RawTemplate{"Good morning, Ms. {{.FirstName}}"}, RawConstraints: RawConstraints{"Gender": "Female", "Formality": "Polite"}},
+	}
+	d := NewDefinitionRepository(nil)
+	if err := d.Add(defs...); err != nil {
+		t.Fatalf("unexpected error occurred in DefinitionRepository.Add(): %s", err)
+	}
+
+	tests := []struct {
+		name  string
+		state MessageMap
+		want  Message
+	}{
+		{name: "assigned values are compared by constraints", want: "Alice: Good morning, Ms. Alice"},
+		{name: "value with ? does not overwrite initial state", state: MessageMap{"Formality": "Casual"}, want: "Alice: Hi"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := d.Generate("Test", NewState(tt.state), 1)
+			if err != nil {
+				t.Fatalf("unexpected error occurred in DefinitionRepository.Generate(): %s", err)
+			}
+			if got[0] != tt.want {
+				t.Errorf("DefinitionRepository.Generate() = %v, want %v", got[0], tt.want)
+			}
+		})
+	}
+
+	count, err := d.Count(context.Background(), "Test", nil)
+	if err != nil {
+		t.Fatalf("unexpected error occurred in DefinitionRepository.Count(): %s", err)
+	}
+	if count.Count.Int64() != 1 {
+		t.Errorf("DefinitionRepository.Count() = %v, want 1", count.Count)
+	}
+}
+
+func TestDefinitionRepository_Generate_AssignmentsReferUnresolvedType(t *testing.T) {
+	defs := []*RawDefinition{
+		{Type: "Test", RawTemplates: []RawTemplate{"{{.Name}}: {{.Label}} ({{.Title}})"}},
+		// Title is referred by Sets before the template of Test refers it
+		{Type: "Name", RawTemplates: []RawTemplate{"Alice"}, Sets: RawAssignments{"Label": "{{.Title}} {{.Name}}"}},
+		{Type: "Title", RawTemplates: []RawTemplate{"Ms."}},
+	}
+	d := NewDefinitionRepository(nil)
+	if err := d.Add(defs...); err != nil {
+		t.Fatalf("unexpected error occurred in DefinitionRepository.Add(): %s", err)
+	}
+
+	want := Message("Alice: Ms. Alice (Ms.)")
+	got, err := d.Generate("Test", nil, 1)
+	if err != nil {
+		t.Fatalf("unexpected error occurred in DefinitionRepository.Generate(): %s", err)
+	}
+	if got[0] != want {
+		t.Errorf("DefinitionRepository.Generate() = %v, want %v", got[0], want)
+	}
+
+	count, err := d.Count(context.Background(), "Test", nil)
+	if err != nil {
+		t.Fatalf("unexpected error occurred in DefinitionRepository.Count(): %s", err)
+	}
+	if count.Count.Int64() != 1 {
+		t.Errorf("DefinitionRepository.Count() = %v, want 1", count.Count)
+	}
+
+	if issues := d.Lint("Test", nil); len(issues) > 0 {
+		t.Errorf("DefinitionRepository.Lint() = %v, want no issues", issues)
+	}
+}
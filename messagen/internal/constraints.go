@@ -1,7 +1,11 @@
 package internal
 
 import (
+	"math"
 	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 
 	"golang.org/x/xerrors"
 )
@@ -20,6 +24,24 @@ func (r RawConstraintValue) Match(msg Message) bool {
 	return string(r) == string(msg)
 }
 
+// Reference returns the state key which the value refers like Speaker of "$Speaker".
+// If the value does not refer a state value, it returns empty string. "$$" is the escaped "$".
+func (r RawConstraintValue) Reference() DefinitionType {
+	s := string(r)
+	if !strings.HasPrefix(s, "$") || strings.HasPrefix(s, "$$") {
+		return ""
+	}
+	return DefinitionType(s[1:])
+}
+
+// unescape replaces the escaped "$$" prefix with "$".
+func (r RawConstraintValue) unescape() RawConstraintValue {
+	if strings.HasPrefix(string(r), "$$") {
+		return r[1:]
+	}
+	return r
+}
+
 func (r RawConstraintValue) Parse(isRegExp bool) (*ConstraintValue, error) {
 	v := &ConstraintValue{
 		Raw: r,
@@ -36,50 +58,210 @@ func (r RawConstraintValue) Parse(isRegExp bool) (*ConstraintValue, error) {
 	return v, nil
 }
 
+// ParseComparison parses the value as a number, or a range like "18..65" if the operator is InRange.
+// Either end of a range can be omitted like "18.." or "..65".
+func (r RawConstraintValue) ParseComparison(op ComparisonOperator) (*ConstraintValue, error) {
+	v := &ConstraintValue{Raw: r, Comparison: op, min: math.Inf(-1), max: math.Inf(1)}
+	if op != InRange {
+		num, err := parseNumber(string(r))
+		if err != nil {
+			return nil, xerrors.Errorf("failed to parse constraint value of %s: %w", op, err)
+		}
+		v.min, v.max = num, num
+		return v, nil
+	}
+
+	ends := strings.Split(string(r), "..")
+	if len(ends) != 2 {
+		return nil, xerrors.Errorf("failed to parse constraint value of %s: range must be like 18..65: %s", op, r)
+	}
+	if strings.TrimSpace(ends[0]) != "" {
+		min, err := parseNumber(ends[0])
+		if err != nil {
+			return nil, xerrors.Errorf("failed to parse min of range: %w", err)
+		}
+		v.min = min
+	}
+	if strings.TrimSpace(ends[1]) != "" {
+		max, err := parseNumber(ends[1])
+		if err != nil {
+			return nil, xerrors.Errorf("failed to parse max of range: %w", err)
+		}
+		v.max = max
+	}
+	if v.min > v.max {
+		return nil, xerrors.Errorf("failed to parse constraint value of %s: min is greater than max: %s", op, r)
+	}
+	return v, nil
+}
+
+func parseNumber(s string) (float64, error) {
+	num, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		return 0, xerrors.Errorf("%q is not a number", s)
+	}
+	return num, nil
+}
+
 type ConstraintValue struct {
 	Raw      RawConstraintValue
 	IsRegExp bool
-	re       *regexp.Regexp
+	// Comparison is the operator to compare values as numbers. If it is NoComparison, values are compared as strings.
+	Comparison ComparisonOperator
+	// Reference is the state key whose value is compared instead of Raw like Speaker of "$Speaker".
+	// It is resolved against the state each time the constraint is checked.
+	Reference DefinitionType
+	re        *regexp.Regexp
+	// min and max are the numbers which are compared with state values. Both are the same number except InRange.
+	min, max float64
 }
 
 func (c *ConstraintValue) Match(msg Message) bool {
+	if c.Reference != "" {
+		// references must be resolved before matching
+		return false
+	}
 	if c.IsRegExp {
 		return c.re.MatchString(string(msg))
 	}
+	if c.Comparison != NoComparison {
+		return c.compare(msg)
+	}
 	return c.Raw.Match(msg)
 }
 
+// compare reports whether the message satisfies the comparison. Messages which are not numbers never satisfy it.
+func (c *ConstraintValue) compare(msg Message) bool {
+	num, err := parseNumber(string(msg))
+	if err != nil {
+		return false
+	}
+	switch c.Comparison {
+	case GreaterThan:
+		return num > c.min
+	case GreaterThanOrEqual:
+		return num >= c.min
+	case LessThan:
+		return num < c.max
+	case LessThanOrEqual:
+		return num <= c.max
+	case InRange:
+		return c.min <= num && num <= c.max
+	}
+	return false
+}
+
+// resolve returns the value whose reference is replaced with the state value.
+// It returns false if the state does not have the referred value or the referred value can not be compared as number.
+func (c *ConstraintValue) resolve(state *State) (*ConstraintValue, bool) {
+	if c.Reference == "" {
+		return c, true
+	}
+	msg, ok := state.Get(c.Reference)
+	if !ok {
+		return nil, false
+	}
+	if c.Comparison != NoComparison {
+		v, err := RawConstraintValue(msg).ParseComparison(c.Comparison)
+		if err != nil {
+			return nil, false
+		}
+		return v, true
+	}
+	return &ConstraintValue{Raw: RawConstraintValue(msg)}, true
+}
+
 func (c *ConstraintValue) ToMessage() (Message, error) {
+	if c.Reference != "" {
+		return "", xerrors.Errorf("failed to convert constraint value to message. value refers %s", c.Reference)
+	}
 	if c.IsRegExp {
 		return "", xerrors.Errorf("failed to convert constraint value to message. value is RegExp")
 	}
+	if c.Comparison != NoComparison {
+		return "", xerrors.Errorf("failed to convert constraint value to message. value is compared by %s", c.Comparison)
+	}
 	return Message(c.Raw), nil
 }
 
 type RawConstraints map[RawConstraintKey]RawConstraintValue
 
+// RawValueSetConstraints are constraints which are satisfied if the state value matches any of the values.
+type RawValueSetConstraints map[RawConstraintKey][]RawConstraintValue
+
 type Constraint struct {
 	key   *ConstraintKey
 	value *ConstraintValue
+	// alternatives are the other values of a value set constraint.
+	// The constraint is satisfied if value or any of alternatives matches.
+	alternatives []*ConstraintValue
 }
 
 func NewConstraint(rawKey RawConstraintKey, rawValue RawConstraintValue) (*Constraint, error) {
 	key, err := rawKey.Parse()
 	if err != nil {
-		return nil, xerrors.Errorf("failed to create Constraint: %w", key)
+		return nil, xerrors.Errorf("failed to create Constraint: %w", err)
 	}
 
-	if ok, _ := key.IsValid(); !ok {
-		return nil, xerrors.Errorf("invalid constraints key is found(%s)", key)
+	if ok, reason := key.IsValid(); !ok {
+		return nil, xerrors.Errorf("invalid constraints key is found(%s): %s", rawKey, reason)
 	}
 
-	value, err := rawValue.Parse(key.HasRegExpValue)
+	value, err := key.parseValue(rawValue)
 	if err != nil {
-		return nil, xerrors.Errorf("failed to create Constraint: %w", key)
+		return nil, xerrors.Errorf("failed to create Constraint(%s): %w", rawKey, err)
 	}
 	return &Constraint{key: key, value: value}, nil
 }
 
+// NewValueSetConstraint returns the constraint which is satisfied if the state value matches any of rawValues.
+func NewValueSetConstraint(rawKey RawConstraintKey, rawValues []RawConstraintValue) (*Constraint, error) {
+	if len(rawValues) == 0 {
+		return nil, xerrors.Errorf("failed to create Constraint(%s): value set is empty", rawKey)
+	}
+	constraint, err := NewConstraint(rawKey, rawValues[0])
+	if err != nil {
+		return nil, err
+	}
+	if constraint.key.WillAddValue {
+		return nil, xerrors.Errorf("failed to create Constraint(%s): + can not be used with value set", rawKey)
+	}
+	for _, rawValue := range rawValues[1:] {
+		value, err := constraint.key.parseValue(rawValue)
+		if err != nil {
+			return nil, xerrors.Errorf("failed to create Constraint(%s): %w", rawKey, err)
+		}
+		constraint.alternatives = append(constraint.alternatives, value)
+	}
+	return constraint, nil
+}
+
+func (c *ConstraintKey) parseValue(rawValue RawConstraintValue) (*ConstraintValue, error) {
+	if c.HasRegExpValue {
+		return rawValue.Parse(true)
+	}
+	if ref := rawValue.Reference(); ref != "" {
+		return &ConstraintValue{Raw: rawValue, Comparison: c.Comparison, Reference: ref}, nil
+	}
+	rawValue = rawValue.unescape()
+	if c.Comparison != NoComparison {
+		return rawValue.ParseComparison(c.Comparison)
+	}
+	return rawValue.Parse(false)
+}
+
+// rawValue returns the raw constraint value. Values of a value set constraint are returned like [Spring, Summer].
+func (c *Constraint) rawValue() RawConstraintValue {
+	if len(c.alternatives) == 0 {
+		return c.value.Raw
+	}
+	values := []string{string(c.value.Raw)}
+	for _, alternative := range c.alternatives {
+		values = append(values, string(alternative.Raw))
+	}
+	return RawConstraintValue("[" + strings.Join(values, ", ") + "]")
+}
+
 func (c *Constraint) IsSatisfied(state *State) bool {
 	msg, ok := state.Get(c.key.DefinitionType)
 
@@ -89,24 +271,65 @@ func (c *Constraint) IsSatisfied(state *State) bool {
 	}
 
 	// ! operator check
-	if c.key.MustNotExist && ok {
-		return false
+	// A constraint which refers other state values is negated, so the state value must differ from all of them.
+	if c.key.MustNotExist {
+		if !c.hasReference() {
+			return false
+		}
+		matched, resolved := c.match(msg, state)
+		return resolved && !matched
 	}
 
-	if ok := c.value.Match(msg); !ok {
-		return false
+	matched, _ := c.match(msg, state)
+	return matched
+}
+
+// match reports whether any value of the constraint matches the message.
+// resolved is false if some references can not be resolved by the state.
+func (c *Constraint) match(msg Message, state *State) (matched, resolved bool) {
+	resolved = true
+	for _, value := range append([]*ConstraintValue{c.value}, c.alternatives...) {
+		v, ok := value.resolve(state)
+		if !ok {
+			resolved = false
+			continue
+		}
+		if v.Match(msg) {
+			return true, resolved
+		}
 	}
+	return false, resolved
+}
 
-	return true
+// references returns the state keys which the values of the constraint refer.
+func (c *Constraint) references() (refs []DefinitionType) {
+	for _, value := range append([]*ConstraintValue{c.value}, c.alternatives...) {
+		if value.Reference != "" {
+			refs = append(refs, value.Reference)
+		}
+	}
+	return
+}
+
+func (c *Constraint) hasReference() bool {
+	return len(c.references()) > 0
 }
 
 type Constraints struct {
-	raw    RawConstraints
-	values []*Constraint
-	defMap map[DefinitionType]RawConstraintKey
+	raw       RawConstraints
+	valueSets RawValueSetConstraints
+	values    []*Constraint
+	defMap    map[DefinitionType]RawConstraintKey
 }
 
 func NewConstraints(raw RawConstraints) (*Constraints, error) {
+	return NewConstraintsWithValueSets(raw, nil)
+}
+
+// NewConstraintsWithValueSets returns constraints which consist of raw and valueSets.
+// Constraints are sorted by key, and constraints which refer keys set by other constraints with + follow them,
+// so values are set to the state in the same order regardless of the map order.
+func NewConstraintsWithValueSets(raw RawConstraints, valueSets RawValueSetConstraints) (*Constraints, error) {
 	rawConstraints := raw
 	if rawConstraints == nil {
 		rawConstraints = RawConstraints{}
@@ -120,9 +343,62 @@ func NewConstraints(raw RawConstraints) (*Constraints, error) {
 			return nil, err
 		}
 	}
+	for key, values := range valueSets {
+		if err := c.SetValueSet(key, values); err != nil {
+			return nil, err
+		}
+	}
+	c.values = sortConstraints(c.values)
 	return c, nil
 }
 
+// sortConstraints sorts constraints by key, then moves constraints after constraints with + which set the keys they refer.
+// Constraints which refer each other keep the order by key.
+func sortConstraints(constraints []*Constraint) []*Constraint {
+	sort.SliceStable(constraints, func(i, j int) bool { return constraints[i].key.Raw < constraints[j].key.Raw })
+
+	isPendingKey := func(defType DefinitionType, self *Constraint, placed map[*Constraint]bool) bool {
+		for _, c := range constraints {
+			if c != self && !placed[c] && c.key.WillAddValue && c.key.DefinitionType == defType {
+				return true
+			}
+		}
+		return false
+	}
+
+	sorted := make([]*Constraint, 0, len(constraints))
+	placed := map[*Constraint]bool{}
+	for len(sorted) < len(constraints) {
+		progressed := false
+		for _, c := range constraints {
+			if placed[c] {
+				continue
+			}
+			ready := true
+			for _, ref := range c.references() {
+				if isPendingKey(ref, c, placed) {
+					ready = false
+				}
+			}
+			if ready {
+				sorted = append(sorted, c)
+				placed[c] = true
+				progressed = true
+			}
+		}
+		if !progressed {
+			// cyclic references are applied in order of keys
+			for _, c := range constraints {
+				if !placed[c] {
+					sorted = append(sorted, c)
+				}
+			}
+			break
+		}
+	}
+	return sorted
+}
+
 func (c *Constraints) Set(rawKey RawConstraintKey, value RawConstraintValue) error {
 	c.raw[rawKey] = value
 	constraint, err := NewConstraint(rawKey, value)
@@ -134,6 +410,20 @@ func (c *Constraints) Set(rawKey RawConstraintKey, value RawConstraintValue) err
 	return nil
 }
 
+// SetValueSet sets the constraint which is satisfied if the state value matches any of values.
+func (c *Constraints) SetValueSet(rawKey RawConstraintKey, values []RawConstraintValue) error {
+	if c.valueSets == nil {
+		c.valueSets = RawValueSetConstraints{}
+	}
+	c.valueSets[rawKey] = values
+	constraint, err := NewValueSetConstraint(rawKey, values)
+	if err != nil {
+		return err
+	}
+	c.values = append(c.values, constraint)
+	return nil
+}
+
 func (c *Constraints) Get(key RawConstraintKey) (RawConstraintValue, bool) {
 	v, ok := c.raw[key]
 	return v, ok
@@ -154,16 +444,18 @@ func (c *Constraints) ListUnsatisfied(state *State) (*Constraints, error) {
 		return nil, xerrors.Errorf("failed to create new constraints: %w", err)
 	}
 
-	for rkey, rvalue := range c.raw {
-		constraint, err := NewConstraint(rkey, rvalue)
-		if err != nil {
-			return nil, xerrors.Errorf("error occurred in ListUnsatisfied: %w", err)
+	for _, constraint := range c.values {
+		if constraint.IsSatisfied(state) {
+			continue
 		}
-
-		if !constraint.IsSatisfied(state) {
-			if err := unsatisfiedConstraints.Set(rkey, rvalue); err != nil {
-				return unsatisfiedConstraints, xerrors.Errorf("failed to set constraint key: %w", err)
-			}
+		rkey := constraint.key.Raw
+		if values, ok := c.valueSets[rkey]; ok {
+			err = unsatisfiedConstraints.SetValueSet(rkey, values)
+		} else {
+			err = unsatisfiedConstraints.Set(rkey, c.raw[rkey])
+		}
+		if err != nil {
+			return unsatisfiedConstraints, xerrors.Errorf("failed to set constraint key: %w", err)
 		}
 	}
 	return unsatisfiedConstraints, nil
@@ -174,9 +466,10 @@ func (c *Constraints) AreSatisfied(state *State) (bool, error) {
 	if err != nil {
 		return false, xerrors.Errorf("failed to check constraints: %w", err)
 	}
-	return len(constraints.raw) == 0, nil
+	return len(constraints.values) == 0, nil
 }
 
+// GetPriority returns the sum of priorities of the constraints which are satisfied by the state.
 func (c *Constraints) GetPriority(state *State) int {
 	priority := 0
 	for _, constraint := range c.values {
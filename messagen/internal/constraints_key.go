@@ -8,7 +8,43 @@ import (
 	"golang.org/x/xerrors"
 )
 
-var specialConstraintKeyRunes = "!?/+"
+var specialConstraintKeyRunes = "!?/+<>=~"
+
+// ComparisonOperator is the operator to compare a state value with a constraint value as numbers.
+type ComparisonOperator int
+
+const (
+	// NoComparison means that values are compared as strings or by a regexp.
+	NoComparison ComparisonOperator = iota
+	// GreaterThan is specified by > like "Level>".
+	GreaterThan
+	// GreaterThanOrEqual is specified by >= like "Level>=".
+	GreaterThanOrEqual
+	// LessThan is specified by < like "Price<".
+	LessThan
+	// LessThanOrEqual is specified by <= like "Price<=".
+	LessThanOrEqual
+	// InRange is specified by ~ like "Age~". The constraint value is an inclusive range like "18..65".
+	InRange
+)
+
+func (c ComparisonOperator) String() string {
+	switch c {
+	case NoComparison:
+		return ""
+	case GreaterThan:
+		return ">"
+	case GreaterThanOrEqual:
+		return ">="
+	case LessThan:
+		return "<"
+	case LessThanOrEqual:
+		return "<="
+	case InRange:
+		return "~"
+	}
+	return fmt.Sprintf("unknown comparison operator(%d)", int(c))
+}
 
 func reverseRunes(runes []rune) []rune {
 	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
@@ -44,18 +80,32 @@ func (r RawConstraintKey) Parse() (*ConstraintKey, error) {
 	}
 	constraintKey.Priority = priority
 
-	index := len(remainKey)
-	for i := len(remainKey) - 1; i >= 0; i-- {
-		ru := RawConstraintKeyRune([]rune(remainKey)[i])
+	runes := []rune(remainKey)
+	index := -1
+	for i := len(runes) - 1; i >= 0; i-- {
+		ru := RawConstraintKeyRune(runes[i])
 		if !ru.IsSpecial() {
 			index = i
 			break
 		}
+		if ru == '=' {
+			if i == 0 || (runes[i-1] != '<' && runes[i-1] != '>') {
+				return nil, xerrors.Errorf("failed to parse constraint key: = must follow < or > (%s)", r)
+			}
+			i--
+			if err := constraintKey.updateComparison(RawConstraintKeyRune(runes[i]), true); err != nil {
+				return nil, xerrors.Errorf("failed to parse constraint key: %w", err)
+			}
+			continue
+		}
 		if err := constraintKey.update(ru); err != nil {
 			return nil, xerrors.Errorf("failed to parse constraint key: %w", err)
 		}
 	}
-	constraintKey.DefinitionType = DefinitionType([]rune(remainKey)[:index+1])
+	if index < 0 {
+		return nil, xerrors.Errorf("failed to parse constraint key: definition type is empty (%s)", r)
+	}
+	constraintKey.DefinitionType = DefinitionType(runes[:index+1])
 	if ok, reason := constraintKey.IsValid(); !ok {
 		return nil, xerrors.Errorf("failed to parse constraint key: %s", reason)
 	}
@@ -87,7 +137,9 @@ type ConstraintKey struct {
 	IsAllowedToNotExist bool
 	MustNotExist        bool
 	WillAddValue        bool
-	Priority            int
+	// Comparison is the operator to compare the state value with the constraint value as numbers.
+	Comparison ComparisonOperator
+	Priority   int
 }
 
 func (l *ConstraintKey) update(rlr RawConstraintKeyRune) error {
@@ -101,13 +153,47 @@ func (l *ConstraintKey) update(rlr RawConstraintKeyRune) error {
 	case '+':
 		l.WillAddValue = true
 		l.IsAllowedToNotExist = true
+	case '<', '>', '~':
+		return l.updateComparison(rlr, false)
 	default:
 		return xerrors.Errorf("unknown special constraint rune: %s", rlr)
 	}
 	return nil
 }
 
+func (l *ConstraintKey) updateComparison(rlr RawConstraintKeyRune, orEqual bool) error {
+	if l.Comparison != NoComparison {
+		return xerrors.Errorf("multiple comparison operators are specified: %s and %s", l.Comparison, string(rlr))
+	}
+	switch {
+	case rlr == '>' && orEqual:
+		l.Comparison = GreaterThanOrEqual
+	case rlr == '>':
+		l.Comparison = GreaterThan
+	case rlr == '<' && orEqual:
+		l.Comparison = LessThanOrEqual
+	case rlr == '<':
+		l.Comparison = LessThan
+	case rlr == '~':
+		l.Comparison = InRange
+	default:
+		return xerrors.Errorf("unknown comparison operator: %s", string(rlr))
+	}
+	return nil
+}
+
 func (l *ConstraintKey) IsValid() (bool, string) {
+	if l.Comparison != NoComparison {
+		if l.HasRegExpValue {
+			return false, fmt.Sprintf("%s and / are exclusive", l.Comparison)
+		}
+		if l.WillAddValue {
+			return false, fmt.Sprintf("%s and + are exclusive", l.Comparison)
+		}
+		if l.MustNotExist {
+			return false, fmt.Sprintf("%s and ! are exclusive", l.Comparison)
+		}
+	}
 	if l.HasRegExpValue && l.WillAddValue {
 		return false, "/ and + are exclusive"
 	}
@@ -200,6 +200,74 @@ func TestRawConstraintKey_Parse(t *testing.T) {
 			want:    nil,
 			wantErr: true,
 		},
+		{
+			name: "can parse >=",
+			r:    "Level>=",
+			want: &ConstraintKey{
+				Raw:            "Level>=",
+				DefinitionType: "Level",
+				Comparison:     GreaterThanOrEqual,
+			},
+			wantErr: false,
+		},
+		{
+			name: "can parse < with ?",
+			r:    "Price<?",
+			want: &ConstraintKey{
+				Raw:                 "Price<?",
+				DefinitionType:      "Price",
+				Comparison:          LessThan,
+				IsAllowedToNotExist: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "can parse ~ with priority",
+			r:    "Age~:2",
+			want: &ConstraintKey{
+				Raw:            "Age~:2",
+				DefinitionType: "Age",
+				Comparison:     InRange,
+				Priority:       2,
+			},
+			wantErr: false,
+		},
+		{
+			name:    "= must follow < or >",
+			r:       "Level=",
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "=> is invalid",
+			r:       "Level=>",
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "multiple comparison operators are invalid",
+			r:       "Level<>",
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "comparison and / are exclusive",
+			r:       "Level>/",
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "comparison and + are exclusive",
+			r:       "Level>+",
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "definition type must not be empty",
+			r:       ">=",
+			want:    nil,
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -209,3 +209,83 @@ func TestConstraints_GetByDefinitionType(t *testing.T) {
 		})
 	}
 }
+
+func TestConstraint_IsSatisfied_Comparison(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     RawConstraintKey
+		value   RawConstraintValue
+		state   MessageMap
+		want    bool
+		wantErr bool
+	}{
+		{name: "> is satisfied by greater value", key: "Level>", value: "10", state: MessageMap{"Level": "11"}, want: true},
+		{name: "> is not satisfied by equal value", key: "Level>", value: "10", state: MessageMap{"Level": "10"}, want: false},
+		{name: ">= is satisfied by equal value", key: "Level>=", value: "10", state: MessageMap{"Level": "10"}, want: true},
+		{name: "values are compared as numbers", key: "Level>=", value: "10", state: MessageMap{"Level": "9"}, want: false},
+		{name: "< is satisfied by less decimal value", key: "Price<", value: "1000", state: MessageMap{"Price": "999.5"}, want: true},
+		{name: "<= is not satisfied by greater value", key: "Price<=", value: "1000", state: MessageMap{"Price": "1001"}, want: false},
+		{name: "~ is satisfied by value in range", key: "Age~", value: "18..65", state: MessageMap{"Age": "65"}, want: true},
+		{name: "~ is not satisfied by value out of range", key: "Age~", value: "18..65", state: MessageMap{"Age": "17"}, want: false},
+		{name: "~ accepts open range", key: "Age~", value: "18..", state: MessageMap{"Age": "100"}, want: true},
+		{name: "value which is not number does not satisfy comparison", key: "Level>", value: "10", state: MessageMap{"Level": "high"}, want: false},
+		{name: "comparison is not satisfied if value does not exist", key: "Level>", value: "10", state: MessageMap{}, want: false},
+		{name: "comparison with ? is satisfied if value does not exist", key: "Level>?", value: "10", state: MessageMap{}, want: true},
+		{name: "constraint value must be number", key: "Level>", value: "ten", wantErr: true},
+		{name: "range must have ..", key: "Age~", value: "18-65", wantErr: true},
+		{name: "min of range must not be greater than max", key: "Age~", value: "65..18", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			constraint, err := NewConstraint(tt.key, tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewConstraint() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := constraint.IsSatisfied(NewState(tt.state)); got != tt.want {
+				t.Errorf("IsSatisfied() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConstraint_IsSatisfied_Reference(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     RawConstraintKey
+		values  []RawConstraintValue
+		state   MessageMap
+		want    bool
+		wantErr bool
+	}{
+		{name: "reference is satisfied by the same value", key: "Owner", values: []RawConstraintValue{"$Speaker"}, state: MessageMap{"Owner": "alice", "Speaker": "alice"}, want: true},
+		{name: "reference is not satisfied by other value", key: "Owner", values: []RawConstraintValue{"$Speaker"}, state: MessageMap{"Owner": "bob", "Speaker": "alice"}, want: false},
+		{name: "reference is not satisfied if referred value does not exist", key: "Owner", values: []RawConstraintValue{"$Speaker"}, state: MessageMap{"Owner": "alice"}, want: false},
+		{name: "reference with ? is satisfied if value does not exist", key: "Owner?", values: []RawConstraintValue{"$Speaker"}, state: MessageMap{"Speaker": "alice"}, want: true},
+		{name: "negated reference is satisfied by other value", key: "Rival!", values: []RawConstraintValue{"$Hero"}, state: MessageMap{"Rival": "bob", "Hero": "alice"}, want: true},
+		{name: "negated reference is not satisfied by the same value", key: "Rival!", values: []RawConstraintValue{"$Hero"}, state: MessageMap{"Rival": "alice", "Hero": "alice"}, want: false},
+		{name: "negated reference is not satisfied if referred value does not exist", key: "Rival!", values: []RawConstraintValue{"$Hero"}, state: MessageMap{"Rival": "bob"}, want: false},
+		{name: "negated value set must differ from all values", key: "Rival!", values: []RawConstraintValue{"$Hero", "$Sidekick"}, state: MessageMap{"Rival": "carol", "Hero": "alice", "Sidekick": "carol"}, want: false},
+		{name: "value set can mix references and literals", key: "Owner", values: []RawConstraintValue{"$Speaker", "nobody"}, state: MessageMap{"Owner": "nobody", "Speaker": "alice"}, want: true},
+		{name: "reference is compared as number with comparison operator", key: "Level>=", values: []RawConstraintValue{"$RequiredLevel"}, state: MessageMap{"Level": "10", "RequiredLevel": "9"}, want: true},
+		{name: "referred value which is not number does not satisfy comparison", key: "Level>=", values: []RawConstraintValue{"$RequiredLevel"}, state: MessageMap{"Level": "10", "RequiredLevel": "high"}, want: false},
+		{name: "escaped $ is literal", key: "Price", values: []RawConstraintValue{"$$100"}, state: MessageMap{"Price": "$100"}, want: true},
+		{name: "regexp value is not reference", key: "Price/", values: []RawConstraintValue{"$Price"}, state: MessageMap{"Price": "$Price"}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			constraint, err := NewValueSetConstraint(tt.key, tt.values)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewValueSetConstraint() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := constraint.IsSatisfied(NewState(tt.state)); got != tt.want {
+				t.Errorf("IsSatisfied() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
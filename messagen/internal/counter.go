@@ -0,0 +1,294 @@
+package internal
+
+import (
+	"context"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+// CountResult represents the number of derivations of messages.
+type CountResult struct {
+	// Count is the number of distinct derivations.
+	// If IsExact is false, Count is an upper bound.
+	Count *big.Int
+	// IsExact reports whether Count is exact.
+	IsExact bool
+	// Reasons describe why Count is not exact.
+	Reasons []string
+}
+
+// weightedState is a state which can be reached by Count derivations.
+type weightedState struct {
+	state *State
+	count *big.Int
+}
+
+// stateDistribution is a set of states which are identified by counter.key.
+type stateDistribution map[string]*weightedState
+
+func (s stateDistribution) add(key string, state *State, count *big.Int) {
+	if ws, ok := s[key]; ok {
+		ws.count.Add(ws.count, count)
+		return
+	}
+	s[key] = &weightedState{state: state, count: new(big.Int).Set(count)}
+}
+
+// counter counts derivations by dynamic programming over definition types and states.
+// State values which are never compared by constraints are dropped from states,
+// so states which lead to the same future are merged and counted only once.
+type counter struct {
+	ctx        context.Context
+	repo       *DefinitionRepository
+	relevant   map[DefinitionType]bool
+	memo       map[string]stateDistribution
+	inProgress map[string]bool
+}
+
+// Count returns the number of distinct derivations of the definition type.
+// Derivations are counted according to constraints and alias duplicate rules.
+// Template validators are not considered, so if any validator is registered, the count is an upper bound.
+// User-defined pickers are assumed to only reorder definitions and templates.
+// Definition providers are called with abstracted states, so if any provider is registered, the count may not be exact.
+func (d *DefinitionRepository) Count(ctx context.Context, defType DefinitionType, initialState *State) (*CountResult, error) {
+	c := &counter{
+		ctx:        ctx,
+		repo:       d.deterministic(),
+		relevant:   d.listValueRelevantTypes(),
+		memo:       map[string]stateDistribution{},
+		inProgress: map[string]bool{},
+	}
+
+	state := NewState(nil)
+	if initialState != nil {
+		state = initialState.Copy(nil)
+	}
+	state.resolvable = d.isResolvable
+	c.abstract(state)
+
+	dist, err := c.countDefinitionType(defType, "", nil, state)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to count messages: %w", err)
+	}
+
+	result := &CountResult{Count: big.NewInt(0), IsExact: true}
+	for _, ws := range dist {
+		result.Count.Add(result.Count, ws.count)
+	}
+	if len(d.templateValidators) > 0 {
+		result.IsExact = false
+		result.Reasons = append(result.Reasons, "template validators are not considered")
+	}
+	if len(d.providers) > 0 {
+		result.IsExact = false
+		result.Reasons = append(result.Reasons, "definition providers are called with states which lack values never compared by constraints")
+	}
+	return result, nil
+}
+
+// listValueRelevantTypes returns state keys whose values may affect which definitions are picked.
+// Keys compared by constraints or conditions of templates are relevant,
+// and keys referred from templates of relevant definition types or assignments of relevant keys are also relevant
+// because the values are generated from them.
+func (d *DefinitionRepository) listValueRelevantTypes() map[DefinitionType]bool {
+	aliasReferTypes := map[DefinitionType][]DefinitionType{}
+	assignmentReferTypes := map[DefinitionType][]DefinitionType{}
+	var queue []DefinitionType
+	for _, defs := range d.m {
+		for _, def := range defs {
+			for aliasName, alias := range def.Aliases {
+				aliasReferTypes[DefinitionType(aliasName)] = append(aliasReferTypes[DefinitionType(aliasName)], alias.ReferType)
+			}
+			for _, template := range def.Templates {
+				queue = append(queue, template.conditionDepends()...)
+			}
+			for _, assignment := range def.allAssignments() {
+				assignmentReferTypes[assignment.Key] = append(assignmentReferTypes[assignment.Key], *assignment.Template.Depends...)
+			}
+			for _, constraint := range def.allConstraintsWithTemplates() {
+				if !constraint.key.MustNotExist || constraint.hasReference() {
+					queue = append(queue, constraint.key.DefinitionType)
+				}
+				queue = append(queue, constraint.references()...)
+			}
+		}
+	}
+
+	relevant := map[DefinitionType]bool{}
+	for len(queue) > 0 {
+		defType := queue[0]
+		queue = queue[1:]
+		if relevant[defType] {
+			continue
+		}
+		relevant[defType] = true
+		queue = append(queue, assignmentReferTypes[defType]...)
+
+		referTypes := append([]DefinitionType{defType}, aliasReferTypes[defType]...)
+		for _, referType := range referTypes {
+			for _, def := range d.List(referType) {
+				for _, template := range def.Templates {
+					queue = append(queue, *template.Depends...)
+				}
+			}
+		}
+	}
+	return relevant
+}
+
+// abstract drops values which are never compared from the state.
+func (c *counter) abstract(state *State) {
+	for key := range state.m {
+		if !c.relevant[DefinitionType(key)] {
+			state.m[key] = ""
+		}
+	}
+}
+
+// key returns string which identifies the state for counting.
+func (c *counter) key(state *State) string {
+	var keys []string
+	for key := range state.m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	b := &strings.Builder{}
+	for _, key := range keys {
+		b.WriteString(key)
+		b.WriteByte('=')
+		b.WriteString(string(state.m[key]))
+		b.WriteByte(0)
+	}
+
+	var ids []int
+	for id := range state.pickedTemplates {
+		ids = append(ids, int(id))
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		var raws []string
+		for _, template := range *state.pickedTemplates[DefinitionID(id)] {
+			raws = append(raws, string(template.Raw))
+		}
+		sort.Strings(raws)
+		b.WriteString(strconv.Itoa(id))
+		b.WriteByte(':')
+		b.WriteString(strings.Join(raws, "\x01"))
+		b.WriteByte(0)
+	}
+	return b.String()
+}
+
+// countDefinitionType returns states after the definition type is resolved, with the number of derivations which reach them.
+func (c *counter) countDefinitionType(defType DefinitionType, aliasName AliasName, alias *Alias, state *State) (stateDistribution, error) {
+	if err := c.ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	allowDuplicate := alias != nil && alias.AllowDuplicate
+	memoKey := strings.Join([]string{string(defType), string(aliasName), boolToKey(allowDuplicate), c.key(state)}, "\x02")
+	if dist, ok := c.memo[memoKey]; ok {
+		return dist, nil
+	}
+	if c.inProgress[memoKey] {
+		return nil, xerrors.Errorf("definition type %s refers itself recursively", defType)
+	}
+	c.inProgress[memoKey] = true
+	defer delete(c.inProgress, memoKey)
+
+	defs, err := c.repo.pickDefinitions(c.ctx, defType, state)
+	if err != nil {
+		return nil, err
+	}
+
+	dist := stateDistribution{}
+	for _, def := range defs {
+		defWithAlias := &DefinitionWithAlias{Definition: def, aliasName: aliasName, alias: alias}
+		templates, err := c.repo.applyTemplatePickers(defWithAlias, state)
+		if err != nil {
+			return nil, err
+		}
+		for _, template := range templates {
+			if err := c.countTemplate(defWithAlias, template, state, dist); err != nil {
+				return nil, err
+			}
+		}
+	}
+	c.memo[memoKey] = dist
+	return dist, nil
+}
+
+// countTemplate adds states after the template is resolved to dist.
+func (c *counter) countTemplate(def *DefinitionWithAlias, template *Template, state *State, dist stateDistribution) error {
+	pending := stateDistribution{}
+	pending.add(c.key(state), state.Copy(def.Order), big.NewInt(1))
+	for len(pending) > 0 {
+		next := stateDistribution{}
+		for _, ws := range pending {
+			defType, ok := def.getFirstUnsatisfiedDef(template, ws.state)
+			if !ok {
+				if err := c.complete(def, template, ws, dist); err != nil {
+					return err
+				}
+				continue
+			}
+
+			var aliasName AliasName
+			alias, isAlias := def.Aliases[AliasName(defType)]
+			if isAlias {
+				aliasName = AliasName(defType)
+				defType = alias.ReferType
+			}
+			subDist, err := c.countDefinitionType(defType, aliasName, alias, ws.state)
+			if err != nil {
+				return err
+			}
+			for key, sub := range subDist {
+				next.add(key, sub.state, new(big.Int).Mul(ws.count, sub.count))
+			}
+		}
+		pending = next
+	}
+	return nil
+}
+
+// complete sets the message of the resolved template to the state, and adds it to dist.
+func (c *counter) complete(def *DefinitionWithAlias, template *Template, ws *weightedState, dist stateDistribution) error {
+	key := def.Type
+	if def.aliasName != "" {
+		key = DefinitionType(def.aliasName)
+	}
+
+	msg := Message("")
+	if c.relevant[key] {
+		if template.IsPlainText() {
+			msg = Message(template.Raw)
+		} else {
+			m, err := template.Execute(ws.state)
+			if err != nil {
+				return err
+			}
+			msg = m
+		}
+	}
+
+	newState := ws.state.Copy(def.Order)
+	if err := newState.Update(def, template, msg); err != nil {
+		return err
+	}
+	c.abstract(newState)
+	dist.add(c.key(newState), newState, ws.count)
+	return nil
+}
+
+func boolToKey(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
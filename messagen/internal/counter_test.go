@@ -0,0 +1,163 @@
+package internal
+
+import (
+	"context"
+	"math/big"
+	"testing"
+)
+
+func countDerivations(t *testing.T, d *DefinitionRepository, defType DefinitionType, initialState *State) int64 {
+	t.Helper()
+	it, err := newResolver(context.Background(), d.deterministic()).newMessageIterator(defType, initialState)
+	if err != nil {
+		t.Fatalf("unexpected error occurred in newMessageIterator(): %s", err)
+	}
+	var cnt int64
+	for {
+		_, ok, err := it.next()
+		if err != nil {
+			t.Fatalf("unexpected error occurred in messageIterator.next(): %s", err)
+		}
+		if !ok {
+			return cnt
+		}
+		cnt++
+	}
+}
+
+func TestDefinitionRepository_Count(t *testing.T) {
+	tests := []struct {
+		name         string
+		opt          *DefinitionRepositoryOption
+		defs         []*RawDefinition
+		initialState *State
+		want         int64
+		wantExact    bool
+		wantErr      bool
+	}{
+		{
+			name: "template attributes",
+			defs: []*RawDefinition{
+				{Type: "Test", RawTemplates: []RawTemplate{"{{.Food}} is {{.Taste}}"}},
+				{
+					Type:         "Food",
+					RawTemplates: []RawTemplate{"ramen", "ice cream"},
+					TemplateAttributes: []*RawTemplateAttributes{
+						{Sets: RawAssignments{"Temperature": "Hot"}},
+						{Sets: RawAssignments{"Temperature": "Cold"}},
+					},
+				},
+				{
+					Type:         "Taste",
+					RawTemplates: []RawTemplate{"warming", "refreshing", "delicious"},
+					TemplateAttributes: []*RawTemplateAttributes{
+						{Constraints: RawConstraints{"Temperature": "Hot"}},
+						{Constraints: RawConstraints{"Temperature": "Cold"}},
+					},
+				},
+			},
+			want:      4,
+			wantExact: true,
+		},
+		{
+			name: "constraints",
+			defs: []*RawDefinition{
+				{Type: "Test", RawTemplates: []RawTemplate{"{{.Pronoun}} {{.FirstName}} {{.LastName}}"}},
+				{Type: "Pronoun", RawTemplates: []RawTemplate{"He", "She"}},
+				{Type: "FirstName", RawTemplates: []RawTemplate{"Liam", "James"}, RawConstraints: RawConstraints{"Pronoun": "He"}},
+				{Type: "FirstName", RawTemplates: []RawTemplate{"Emily"}, RawConstraints: RawConstraints{"Pronoun": "She"}},
+				{Type: "LastName", RawTemplates: []RawTemplate{"Smith", "Brown"}},
+			},
+			want:      6,
+			wantExact: true,
+		},
+		{
+			name: "initial state",
+			defs: []*RawDefinition{
+				{Type: "Test", RawTemplates: []RawTemplate{"{{.Pronoun}} {{.FirstName}}"}},
+				{Type: "Pronoun", RawTemplates: []RawTemplate{"He", "She"}},
+				{Type: "FirstName", RawTemplates: []RawTemplate{"Liam", "James"}, RawConstraints: RawConstraints{"Pronoun": "He"}},
+				{Type: "FirstName", RawTemplates: []RawTemplate{"Emily"}, RawConstraints: RawConstraints{"Pronoun": "She"}},
+			},
+			initialState: NewState(MessageMap{"Pronoun": "She"}),
+			want:         1,
+			wantExact:    true,
+		},
+		{
+			name: "+ operator and alias which does not allow duplicate",
+			defs: []*RawDefinition{
+				{
+					Type:         "Test",
+					RawTemplates: []RawTemplate{"{{.A}}{{.AnotherA}}{{.B}}"},
+					Aliases:      Aliases{"AnotherA": &Alias{ReferType: "A", AllowDuplicate: false}},
+				},
+				{Type: "A", RawTemplates: []RawTemplate{"a1", "a2", "a3"}, RawConstraints: RawConstraints{"K+": "V1"}},
+				{Type: "A", RawTemplates: []RawTemplate{"a4"}, RawConstraints: RawConstraints{"K+": "V2"}},
+				{Type: "B", RawTemplates: []RawTemplate{"b1"}, RawConstraints: RawConstraints{"K": "V1"}},
+				{Type: "B", RawTemplates: []RawTemplate{"b2", "b3"}, RawConstraints: RawConstraints{"K": "V2"}},
+			},
+			want:      6,
+			wantExact: true,
+		},
+		{
+			name: "regexp constraint to generated value",
+			defs: []*RawDefinition{
+				{Type: "Test", RawTemplates: []RawTemplate{"{{.Name}} {{.Title}}"}},
+				{Type: "Name", RawTemplates: []RawTemplate{"{{.First}}-{{.Last}}"}},
+				{Type: "First", RawTemplates: []RawTemplate{"x", "y"}},
+				{Type: "Last", RawTemplates: []RawTemplate{"1", "2"}},
+				{Type: "Title", RawTemplates: []RawTemplate{"T"}, RawConstraints: RawConstraints{"Name/": "^x"}},
+				{Type: "Title", RawTemplates: []RawTemplate{"U", "V"}, RawConstraints: RawConstraints{"Name/": "2$"}},
+			},
+			want:      6,
+			wantExact: true,
+		},
+		{
+			name: "validators make count upper bound",
+			opt: &DefinitionRepositoryOption{
+				TemplateValidators: []TemplateValidator{MaxStrLenValidator(1)},
+			},
+			defs: []*RawDefinition{
+				{Type: "Test", RawTemplates: []RawTemplate{"{{.A}}{{.B}}"}},
+				{Type: "A", RawTemplates: []RawTemplate{"a1", "a2"}},
+				{Type: "B", RawTemplates: []RawTemplate{"b1", "b2"}},
+			},
+			want:      4,
+			wantExact: false,
+		},
+		{
+			name: "recursive definition",
+			defs: []*RawDefinition{
+				{Type: "Test", RawTemplates: []RawTemplate{"{{.A}}"}},
+				{Type: "A", RawTemplates: []RawTemplate{"{{.A}}"}},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewDefinitionRepository(tt.opt)
+			if err := d.Add(tt.defs...); err != nil {
+				t.Fatalf("unexpected error occurred in DefinitionRepository.Add(): %s", err)
+			}
+			got, err := d.Count(context.Background(), "Test", tt.initialState)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DefinitionRepository.Count() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if got.Count.Cmp(big.NewInt(tt.want)) != 0 {
+				t.Errorf("DefinitionRepository.Count() = %v, want %v", got.Count, tt.want)
+			}
+			// exact count must be the same as the number of derivations which are found by the resolver.
+			if derivations := countDerivations(t, d, "Test", tt.initialState); tt.wantExact && got.Count.Cmp(big.NewInt(derivations)) != 0 {
+				t.Errorf("DefinitionRepository.Count() = %v, but resolver finds %v derivations", got.Count, derivations)
+			}
+			if got.IsExact != tt.wantExact {
+				t.Errorf("DefinitionRepository.Count().IsExact = %v, want %v", got.IsExact, tt.wantExact)
+			}
+		})
+	}
+}
@@ -1,6 +1,9 @@
 package internal
 
 import (
+	"sort"
+	"sync"
+
 	"golang.org/x/xerrors"
 )
 
@@ -27,20 +30,126 @@ type RawDefinition struct {
 	AllowDuplicate bool
 	Order          []DefinitionType
 	Weight         DefinitionWeight
+	// Generator generates templates instead of RawTemplates.
+	Generator *Generator
+	// RawValueSetConstraints are constraints which are satisfied if the state value matches any of the values.
+	// They are ANDed with RawConstraints.
+	RawValueSetConstraints RawValueSetConstraints
+	// RawConstraintGroups are named constraint sets.
+	// If any group exists, the definition can be picked only if at least one of the groups is satisfied.
+	RawConstraintGroups map[string]*RawConstraintGroup
+	// Sets are state assignments which are applied when the definition is picked.
+	Sets RawAssignments
+	// TemplateAttributes are optional attributes of RawTemplates with the same index. nil means default attributes.
+	TemplateAttributes []*RawTemplateAttributes
+	// Locale is the locale of the definition. Definitions without locale are used in all locales.
+	Locale Locale
+}
+
+// RawConstraintGroup is a set of constraints which are satisfied together.
+type RawConstraintGroup struct {
+	Constraints RawConstraints
+	ValueSets   RawValueSetConstraints
+}
+
+// ConstraintGroup is a named set of constraints which are satisfied together.
+type ConstraintGroup struct {
+	Name        string
+	Constraints *Constraints
 }
 
 type Definition struct {
 	*RawDefinition
 	Constraints *Constraints
+	// ConstraintGroups are sorted by name.
+	ConstraintGroups []*ConstraintGroup
+	// Assignments are sorted by key.
+	Assignments []*Assignment
 	ID          DefinitionID
 	Templates   Templates
+	// generated builds templates of values of Generator on demand instead of Templates.
+	generated *generatedTemplates
+}
+
+// generatedTemplates builds templates of values of a generator on demand.
+// All templates are built only when they are listed like in Enumerate and Count.
+type generatedTemplates struct {
+	generator valueGenerator
+	n         int
+	order     []DefinitionType
+	funcs     *FuncSet
+
+	once      sync.Once
+	templates Templates
+	err       error
+}
+
+func newGeneratedTemplates(generator *Generator, order []DefinitionType, funcs *FuncSet) (*generatedTemplates, error) {
+	vg, n, err := generator.sizedValueGenerator()
+	if err != nil {
+		return nil, err
+	}
+	return &generatedTemplates{generator: vg, n: n, order: order, funcs: funcs}, nil
+}
+
+// at returns the template of the i-th value.
+func (g *generatedTemplates) at(i int) (*Template, error) {
+	return NewTemplateWithFuncs(escapeTemplate(g.generator.valueAt(i)), g.order, g.funcs)
+}
+
+// all returns templates of all values. They are built on the first call and shared after that.
+func (g *generatedTemplates) all() (Templates, error) {
+	g.once.Do(func() {
+		for i := 0; i < g.n; i++ {
+			template, err := g.at(i)
+			if err != nil {
+				g.err = xerrors.Errorf("failed to create generated templates: %w", err)
+				return
+			}
+			g.templates = append(g.templates, template)
+		}
+	})
+	return g.templates, g.err
 }
 
 func NewDefinition(rawDefinition *RawDefinition) (*Definition, error) {
-	templates, err := NewTemplates(rawDefinition.RawTemplates, rawDefinition.Order)
+	return NewDefinitionWithFuncs(rawDefinition, nil)
+}
+
+// NewDefinitionWithFuncs returns a definition whose templates can use funcs. If funcs is nil, built-in functions are used.
+func NewDefinitionWithFuncs(rawDefinition *RawDefinition, funcs *FuncSet) (*Definition, error) {
+	templates, err := NewTemplatesWithFuncs(rawDefinition.RawTemplates, rawDefinition.Order, funcs)
 	if err != nil {
 		return nil, xerrors.Errorf("failed to create Definition: %w", err)
 	}
+	var generated *generatedTemplates
+	if rawDefinition.Generator != nil {
+		if len(templates) > 0 {
+			return nil, xerrors.Errorf("failed to create Definition: definition of %s has both templates and generator", rawDefinition.Type)
+		}
+		generated, err = newGeneratedTemplates(rawDefinition.Generator, rawDefinition.Order, funcs)
+		if err != nil {
+			return nil, xerrors.Errorf("failed to create Definition: %w", err)
+		}
+		// attributes are set to each template, so templates with attributes are built now
+		if len(rawDefinition.TemplateAttributes) > 0 {
+			if templates, err = generated.all(); err != nil {
+				return nil, xerrors.Errorf("failed to create Definition: %w", err)
+			}
+			generated = nil
+		}
+	}
+	if len(rawDefinition.TemplateAttributes) > len(templates) {
+		return nil, xerrors.Errorf("failed to create Definition: definition of %s has more template attributes than templates", rawDefinition.Type)
+	}
+	for i, attrs := range rawDefinition.TemplateAttributes {
+		if attrs == nil {
+			continue
+		}
+		if err := templates[i].setAttributes(attrs, funcs); err != nil {
+			return nil, xerrors.Errorf("failed to create Definition: %w", err)
+		}
+	}
 
 	if rawDefinition.Weight == 0 {
 		rawDefinition.Weight = 1
@@ -48,23 +157,123 @@ func NewDefinition(rawDefinition *RawDefinition) (*Definition, error) {
 	def := &Definition{
 		RawDefinition: rawDefinition,
 		Templates:     templates,
+		generated:     generated,
 	}
 
-	constraints, err := NewConstraints(rawDefinition.RawConstraints)
+	constraints, err := NewConstraintsWithValueSets(rawDefinition.RawConstraints, rawDefinition.RawValueSetConstraints)
 	if err != nil {
 		return nil, xerrors.Errorf("failed to set empty constraints to definition: %w", err)
 	}
 	def.Constraints = constraints
 
+	for name, rawGroup := range rawDefinition.RawConstraintGroups {
+		groupConstraints, err := NewConstraintsWithValueSets(rawGroup.Constraints, rawGroup.ValueSets)
+		if err != nil {
+			return nil, xerrors.Errorf("failed to set constraint group %s to definition: %w", name, err)
+		}
+		def.ConstraintGroups = append(def.ConstraintGroups, &ConstraintGroup{Name: name, Constraints: groupConstraints})
+	}
+	sort.Slice(def.ConstraintGroups, func(i, j int) bool { return def.ConstraintGroups[i].Name < def.ConstraintGroups[j].Name })
+
+	assignments, err := NewAssignments(rawDefinition.Sets, funcs)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to set assignments to definition: %w", err)
+	}
+	def.Assignments = assignments
+
 	return def, nil
 }
 
 func (d *Definition) CanBePicked(state *State) (bool, error) {
 	if ok, err := d.Constraints.AreSatisfied(state); err != nil {
 		return false, xerrors.Errorf("failed to check definition can be picked: %w", err)
-	} else {
-		return ok, nil
+	} else if !ok {
+		return false, nil
+	}
+	return len(d.ConstraintGroups) == 0 || d.SatisfiedConstraintGroup(state) != nil, nil
+}
+
+// SatisfiedConstraintGroup returns the first constraint group in name order which is satisfied by the state.
+// If no group is satisfied, it returns nil.
+func (d *Definition) SatisfiedConstraintGroup(state *State) *ConstraintGroup {
+	for _, group := range d.ConstraintGroups {
+		if ok, _ := group.Constraints.AreSatisfied(state); ok {
+			return group
+		}
+	}
+	return nil
+}
+
+// GetPriority returns the sum of priorities of the satisfied constraints of the definition and its first satisfied constraint group.
+func (d *Definition) GetPriority(state *State) int {
+	priority := d.Constraints.GetPriority(state)
+	if group := d.SatisfiedConstraintGroup(state); group != nil {
+		priority += group.Constraints.GetPriority(state)
+	}
+	return priority
+}
+
+// allConstraints returns constraints of the definition and all of its constraint groups.
+func (d *Definition) allConstraints() []*Constraint {
+	constraints := d.Constraints.values
+	for _, group := range d.ConstraintGroups {
+		constraints = append(constraints[:len(constraints):len(constraints)], group.Constraints.values...)
+	}
+	return constraints
+}
+
+// allTemplates returns Templates, or templates of all values if the definition has a generator.
+func (d *Definition) allTemplates() (Templates, error) {
+	if d.generated != nil {
+		return d.generated.all()
+	}
+	return d.Templates, nil
+}
+
+// allConstraintsWithTemplates returns constraints of the definition, its constraint groups and its templates.
+func (d *Definition) allConstraintsWithTemplates() []*Constraint {
+	constraints := d.allConstraints()
+	for _, template := range d.Templates {
+		if template.Constraints != nil {
+			constraints = append(constraints[:len(constraints):len(constraints)], template.Constraints.values...)
+		}
+	}
+	return constraints
+}
+
+// allAssignments returns assignments of the definition and its templates.
+func (d *Definition) allAssignments() []*Assignment {
+	assignments := d.Assignments
+	for _, template := range d.Templates {
+		assignments = append(assignments[:len(assignments):len(assignments)], template.Assignments...)
+	}
+	return assignments
+}
+
+// getFirstUnsatisfiedDef returns the first definition type which the template refers but is not in the state.
+// Types which assignments of the definition and the template refer follow them, so they are resolved before the assignments are applied.
+// The type of the definition is excluded because it is set before assignments are applied,
+// and assignments which are skipped because the state already has the key are ignored.
+func (d *Definition) getFirstUnsatisfiedDef(template *Template, state *State) (DefinitionType, bool) {
+	if defType, ok := template.GetFirstUnsatisfiedDef(state); ok {
+		return defType, true
+	}
+	for _, assignments := range [][]*Assignment{d.Assignments, template.Assignments} {
+		for _, assignment := range assignments {
+			if _, ok := state.Get(assignment.Key); ok && assignment.OnlyIfAbsent {
+				continue
+			}
+			for _, defType := range assignment.Template.dependsWithState(state) {
+				if defType == d.Type {
+					continue
+				}
+				if _, ok := state.Get(defType); !ok {
+					return defType, true
+				}
+			}
+		}
 	}
+	return "", false
 }
 
 func (d *Definition) IsAlias(defType DefinitionType) bool {
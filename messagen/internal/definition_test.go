@@ -77,6 +77,85 @@ func TestDefinition_CanBePicked(t *testing.T) {
 			want: false,
 			//want1: "",
 		},
+		{
+			name: "value set is satisfied by any of the values",
+			definition: newDefinitionOrPanic(
+				&RawDefinition{
+					Type:                   "Root",
+					RawTemplates:           []RawTemplate{""},
+					RawValueSetConstraints: RawValueSetConstraints{"Season": {"Spring", "Summer"}},
+				},
+			),
+			args: args{
+				state: NewState(MessageMap{"Season": "Summer"}),
+			},
+			want: true,
+		},
+		{
+			name: "value set is not satisfied by other value",
+			definition: newDefinitionOrPanic(
+				&RawDefinition{
+					Type:                   "Root",
+					RawTemplates:           []RawTemplate{""},
+					RawValueSetConstraints: RawValueSetConstraints{"Season": {"Spring", "Summer"}},
+				},
+			),
+			args: args{
+				state: NewState(MessageMap{"Season": "Winter"}),
+			},
+			want: false,
+		},
+		{
+			name: "definition can be picked if one of constraint groups is satisfied",
+			definition: newDefinitionOrPanic(
+				&RawDefinition{
+					Type:         "Root",
+					RawTemplates: []RawTemplate{""},
+					RawConstraintGroups: map[string]*RawConstraintGroup{
+						"Weekend": {ValueSets: RawValueSetConstraints{"Day": {"Sat", "Sun"}}},
+						"Holiday": {Constraints: RawConstraints{"Holiday": "true"}},
+					},
+				},
+			),
+			args: args{
+				state: NewState(MessageMap{"Day": "Mon", "Holiday": "true"}),
+			},
+			want: true,
+		},
+		{
+			name: "definition can not be picked if no constraint group is satisfied",
+			definition: newDefinitionOrPanic(
+				&RawDefinition{
+					Type:         "Root",
+					RawTemplates: []RawTemplate{""},
+					RawConstraintGroups: map[string]*RawConstraintGroup{
+						"Weekend": {ValueSets: RawValueSetConstraints{"Day": {"Sat", "Sun"}}},
+						"Holiday": {Constraints: RawConstraints{"Holiday": "true"}},
+					},
+				},
+			),
+			args: args{
+				state: NewState(MessageMap{"Day": "Mon", "Holiday": "false"}),
+			},
+			want: false,
+		},
+		{
+			name: "constraint groups are ANDed with constraints",
+			definition: newDefinitionOrPanic(
+				&RawDefinition{
+					Type:           "Root",
+					RawTemplates:   []RawTemplate{""},
+					RawConstraints: RawConstraints{"Weather": "Sunny"},
+					RawConstraintGroups: map[string]*RawConstraintGroup{
+						"Holiday": {Constraints: RawConstraints{"Holiday": "true"}},
+					},
+				},
+			),
+			args: args{
+				state: NewState(MessageMap{"Weather": "Rainy", "Holiday": "true"}),
+			},
+			want: false,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -0,0 +1,43 @@
+package internal
+
+// Derivation represents how a message of a definition type is derived.
+type Derivation struct {
+	Type         DefinitionType
+	AliasName    AliasName
+	DefinitionID DefinitionID
+	Template     RawTemplate
+	Message      Message
+	// AddedState is state values which are added by constraints of the definition.
+	AddedState MessageMap
+	// Children are derivations of definition types which are resolved while the template is resolved.
+	Children []*Derivation
+}
+
+// DetailedMessage is a generated message with its final state and derivation.
+type DetailedMessage struct {
+	Message    Message
+	State      MessageMap
+	Derivation *Derivation
+}
+
+// traceFrame holds derivations which are resolved while a template is resolved.
+// Frames are never modified after creation, so states can share them after Copy.
+type traceFrame struct {
+	parent   *traceFrame
+	children []*Derivation
+}
+
+// push returns a new frame for resolving a template.
+func (t *traceFrame) push() *traceFrame {
+	return &traceFrame{parent: t}
+}
+
+// withChild returns a new frame which has the derivation as the last child.
+func (t *traceFrame) withChild(derivation *Derivation) *traceFrame {
+	if t == nil {
+		return &traceFrame{children: []*Derivation{derivation}}
+	}
+	children := make([]*Derivation, len(t.children), len(t.children)+1)
+	copy(children, t.children)
+	return &traceFrame{parent: t.parent, children: append(children, derivation)}
+}
@@ -0,0 +1,200 @@
+package internal
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// maxRejectionSummaries is the max number of summaries which NoMessageError holds for each category.
+const maxRejectionSummaries = 5
+
+type RejectionReason int
+
+const (
+	// DefinitionNotFound means that no definition of the type exists.
+	DefinitionNotFound RejectionReason = iota
+	// ConstraintUnsatisfied means that a definition is rejected because the state does not satisfy its constraint.
+	ConstraintUnsatisfied
+	// TemplatesExhausted means that all templates of a definition are already picked and duplicates are not allowed.
+	TemplatesExhausted
+	// ValidatorRejected means that a template is rejected by template validators.
+	ValidatorRejected
+	// InHistory means that a message or a template is rejected because it is in the history.
+	InHistory
+)
+
+// Rejection describes why a candidate is rejected while message generation.
+type Rejection struct {
+	Reason RejectionReason
+	// Path is the definition types which are referred from the root to the rejected type.
+	// Alias names are used for the types which are referred by alias.
+	Path           []DefinitionType
+	DefinitionType DefinitionType
+	AliasName      AliasName
+	DefinitionID   DefinitionID
+	Template       RawTemplate
+	// ConstraintGroup is the name of the constraint group which has the unsatisfied constraint.
+	// It is empty if the constraint is not in a group.
+	ConstraintGroup string
+	// Message is the message which is rejected because it is in the history.
+	Message Message
+	// ConstraintKey and ConstraintValue are the unsatisfied constraint.
+	ConstraintKey   RawConstraintKey
+	ConstraintValue RawConstraintValue
+	// StateValue is the state value which is compared with the constraint. HasStateValue is false if the value does not exist.
+	StateValue    Message
+	HasStateValue bool
+}
+
+func (r *Rejection) String() string {
+	switch r.Reason {
+	case DefinitionNotFound:
+		return fmt.Sprintf("definition type %q is not defined", r.DefinitionType)
+	case ConstraintUnsatisfied:
+		stateValue := "state value does not exist"
+		if r.HasStateValue {
+			stateValue = fmt.Sprintf("state value is %q", string(r.StateValue))
+		}
+		if r.Template != "" {
+			return fmt.Sprintf("template %q of definition #%d of %s is rejected by constraint %q: %q (%s)",
+				r.Template, r.DefinitionID, r.typeName(), r.ConstraintKey, r.ConstraintValue, stateValue)
+		}
+		if r.ConstraintGroup != "" {
+			return fmt.Sprintf("definition #%d of %s is rejected by constraint %q: %q of group %q (%s)",
+				r.DefinitionID, r.typeName(), r.ConstraintKey, r.ConstraintValue, r.ConstraintGroup, stateValue)
+		}
+		return fmt.Sprintf("definition #%d of %s is rejected by constraint %q: %q (%s)",
+			r.DefinitionID, r.typeName(), r.ConstraintKey, r.ConstraintValue, stateValue)
+	case TemplatesExhausted:
+		return fmt.Sprintf("all templates of definition #%d of %s are already picked and duplicates are not allowed", r.DefinitionID, r.typeName())
+	case InHistory:
+		if r.Template != "" {
+			return fmt.Sprintf("template %q of definition #%d of %s is in the history", r.Template, r.DefinitionID, r.typeName())
+		}
+		return fmt.Sprintf("message %q of %s is in the history", r.Message, r.typeName())
+	case ValidatorRejected:
+		return fmt.Sprintf("template %q of definition #%d of %s is rejected by template validators", r.Template, r.DefinitionID, r.typeName())
+	}
+	return fmt.Sprintf("unknown rejection reason: %d", r.Reason)
+}
+
+func (r *Rejection) typeName() string {
+	if r.AliasName == "" {
+		return fmt.Sprintf("%q", r.DefinitionType)
+	}
+	return fmt.Sprintf("%q (alias %q)", r.DefinitionType, r.AliasName)
+}
+
+func (r *Rejection) key() string {
+	return fmt.Sprintf("%d\x00%s\x00%s\x00%d\x00%s\x00%s\x00%s\x00%s\x00%t\x00%s",
+		r.Reason, r.DefinitionType, r.AliasName, r.DefinitionID, r.Template, r.Message, r.ConstraintGroup, r.ConstraintKey, r.HasStateValue, r.StateValue)
+}
+
+// RejectionSummary is a rejection which occurred Count times.
+// Path of the rejection is the deepest one of them.
+type RejectionSummary struct {
+	*Rejection
+	Count int
+}
+
+func (r *RejectionSummary) String() string {
+	return fmt.Sprintf("%s: %s (count: %d)", formatPath(r.Path), r.Rejection, r.Count)
+}
+
+func formatPath(path []DefinitionType) string {
+	var names []string
+	for _, defType := range path {
+		names = append(names, string(defType))
+	}
+	return strings.Join(names, " > ")
+}
+
+// rejectionRecorder aggregates rejections while message generation.
+type rejectionRecorder struct {
+	summaries map[string]*RejectionSummary
+}
+
+func newRejectionRecorder() *rejectionRecorder {
+	return &rejectionRecorder{summaries: map[string]*RejectionSummary{}}
+}
+
+func (r *rejectionRecorder) record(rejection *Rejection) {
+	key := rejection.key()
+	summary, ok := r.summaries[key]
+	if !ok {
+		r.summaries[key] = &RejectionSummary{Rejection: rejection, Count: 1}
+		return
+	}
+	summary.Count++
+	if len(rejection.Path) > len(summary.Path) {
+		summary.Path = rejection.Path
+	}
+}
+
+// newNoMessageError returns NoMessageError which summarizes the deepest and the most common rejections.
+func (r *rejectionRecorder) newNoMessageError(defType DefinitionType) *NoMessageError {
+	var summaries []*RejectionSummary
+	for _, summary := range r.summaries {
+		summaries = append(summaries, summary)
+	}
+	// sort by key first to make the order deterministic
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].key() < summaries[j].key() })
+
+	deepest := make([]*RejectionSummary, len(summaries))
+	copy(deepest, summaries)
+	sort.SliceStable(deepest, func(i, j int) bool { return len(deepest[i].Path) > len(deepest[j].Path) })
+
+	mostCommon := make([]*RejectionSummary, len(summaries))
+	copy(mostCommon, summaries)
+	sort.SliceStable(mostCommon, func(i, j int) bool { return mostCommon[i].Count > mostCommon[j].Count })
+
+	return &NoMessageError{
+		DefinitionType: defType,
+		Deepest:        limitSummaries(deepest),
+		MostCommon:     limitSummaries(mostCommon),
+	}
+}
+
+func limitSummaries(summaries []*RejectionSummary) []*RejectionSummary {
+	if len(summaries) > maxRejectionSummaries {
+		return summaries[:maxRejectionSummaries]
+	}
+	return summaries
+}
+
+// NoMessageError is returned when no valid message can be generated.
+// It summarizes why candidates were rejected while the search.
+type NoMessageError struct {
+	DefinitionType DefinitionType
+	// Deepest is the rejections which occurred at the deepest paths.
+	Deepest []*RejectionSummary
+	// MostCommon is the rejections which occurred most frequently.
+	MostCommon []*RejectionSummary
+}
+
+func (e *NoMessageError) Error() string {
+	if len(e.MostCommon) == 0 {
+		return "valid message does not exist"
+	}
+	return fmt.Sprintf("valid message does not exist: %s", e.MostCommon[0].Rejection)
+}
+
+// Explain returns the human readable explanation of the rejections.
+func (e *NoMessageError) Explain() string {
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "valid message of %q does not exist\n", e.DefinitionType)
+	if len(e.MostCommon) == 0 {
+		b.WriteString("no candidate was rejected\n")
+		return b.String()
+	}
+	b.WriteString("deepest rejections:\n")
+	for _, summary := range e.Deepest {
+		fmt.Fprintf(b, "  %s\n", summary)
+	}
+	b.WriteString("most common rejections:\n")
+	for _, summary := range e.MostCommon {
+		fmt.Fprintf(b, "  %s\n", summary)
+	}
+	return b.String()
+}
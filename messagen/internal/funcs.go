@@ -0,0 +1,185 @@
+package internal
+
+import (
+	"strings"
+	"sync"
+	"text/template"
+	"unicode"
+	"unicode/utf8"
+)
+
+// BuiltinFuncs returns functions which are available in all templates.
+// printf is provided by text/template.
+func BuiltinFuncs() template.FuncMap {
+	return template.FuncMap{
+		"upper":      strings.ToUpper,
+		"lower":      strings.ToLower,
+		"title":      title,
+		"trim":       strings.TrimSpace,
+		"replace":    replace,
+		"default":    defaultValue,
+		"join":       join,
+		"runeLength": utf8.RuneCountInString,
+	}
+}
+
+// TraceryFuncs returns functions which templates converted from Tracery grammars call for modifiers.
+// They are not built-in because they handle only English words.
+func TraceryFuncs() template.FuncMap {
+	return template.FuncMap{
+		"traceryCapitalize":  capitalize,
+		"traceryPlural":      plural,
+		"traceryWithArticle": withArticle,
+		"traceryPast":        past,
+	}
+}
+
+// FuncSet is a set of functions which are available in templates.
+// Parsed templates are cached because they are never modified after parse.
+type FuncSet struct {
+	funcs template.FuncMap
+	cache sync.Map
+}
+
+// NewFuncSet returns a FuncSet which has built-in functions and funcs. funcs override built-in functions.
+func NewFuncSet(funcs template.FuncMap) *FuncSet {
+	if len(funcs) == 0 {
+		return builtinFuncSet
+	}
+	funcMap := BuiltinFuncs()
+	for name, f := range funcs {
+		funcMap[name] = f
+	}
+	return &FuncSet{funcs: funcMap}
+}
+
+// builtinFuncSet is shared by templates which use only built-in functions.
+var builtinFuncSet = &FuncSet{funcs: BuiltinFuncs()}
+
+// parse returns the parsed template of rawTemplate.
+func (f *FuncSet) parse(rawTemplate RawTemplate) (*template.Template, error) {
+	if tmpl, ok := f.cache.Load(rawTemplate); ok {
+		return tmpl.(*template.Template), nil
+	}
+	tmpl, err := template.New(string(rawTemplate)).Funcs(f.funcs).Parse(string(rawTemplate))
+	if err != nil {
+		return nil, err
+	}
+	actual, _ := f.cache.LoadOrStore(rawTemplate, tmpl)
+	return actual.(*template.Template), nil
+}
+
+// title converts the first letter of each word to upper case.
+func title(s string) string {
+	prev := ' '
+	return strings.Map(func(r rune) rune {
+		isStart := unicode.IsSpace(prev)
+		prev = r
+		if isStart {
+			return unicode.ToTitle(r)
+		}
+		return r
+	}, s)
+}
+
+// replace replaces all old in s with new. s is the last argument so that it can be used in pipelines like {{.Name | replace "a" "b"}}.
+func replace(old, new, s string) string {
+	return strings.ReplaceAll(s, old, new)
+}
+
+// defaultValue returns value if it is not empty, otherwise returns def.
+func defaultValue(def, value string) string {
+	if value == "" {
+		return def
+	}
+	return value
+}
+
+func join(sep string, elems ...string) string {
+	return strings.Join(elems, sep)
+}
+
+// capitalize converts the first letter to upper case.
+func capitalize(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	if size == 0 {
+		return s
+	}
+	return string(unicode.ToUpper(r)) + s[size:]
+}
+
+func isVowel(b byte) bool {
+	return strings.IndexByte("aeiouAEIOU", b) >= 0
+}
+
+// plural converts the English noun to plural form like "box" to "boxes" and "city" to "cities".
+func plural(s string) string {
+	if s == "" {
+		return s
+	}
+	switch s[len(s)-1] {
+	case 's', 'h', 'x':
+		return s + "es"
+	case 'y':
+		if len(s) > 1 && !isVowel(s[len(s)-2]) {
+			return s[:len(s)-1] + "ies"
+		}
+	}
+	return s + "s"
+}
+
+// consonantSoundPrefixes are prefixes of English words which start with a vowel letter but a consonant sound like "unicorn".
+var consonantSoundPrefixes = []string{"uni", "use", "usu", "uti", "ure", "eu", "ewe", "one", "once"}
+
+// vowelSoundPrefixes are prefixes of English words which start with a silent h like "hour".
+var vowelSoundPrefixes = []string{"hour", "honest", "honor", "honour", "heir"}
+
+// startsWithVowelSound reports whether the English word starts with a vowel sound, so that "an" is used before it.
+func startsWithVowelSound(word string) bool {
+	if word == "" {
+		return false
+	}
+	lower := strings.ToLower(word)
+	for _, prefix := range vowelSoundPrefixes {
+		if strings.HasPrefix(lower, prefix) {
+			return true
+		}
+	}
+	for _, prefix := range consonantSoundPrefixes {
+		if strings.HasPrefix(lower, prefix) {
+			return false
+		}
+	}
+	return isVowel(word[0])
+}
+
+// withArticle prepends "a" or "an" to the English word like "an apple", "a unicorn" and "an hour".
+func withArticle(s string) string {
+	if s == "" {
+		return s
+	}
+	if startsWithVowelSound(s) {
+		return "an " + s
+	}
+	return "a " + s
+}
+
+// past converts the first word which is an English verb to past tense like "walk" to "walked" and "cry" to "cried".
+func past(s string) string {
+	word, rest := s, ""
+	if i := strings.IndexByte(s, ' '); i >= 0 {
+		word, rest = s[:i], s[i:]
+	}
+	if word == "" {
+		return s
+	}
+	switch word[len(word)-1] {
+	case 'e':
+		return word + "d" + rest
+	case 'y':
+		if len(word) > 1 && !isVowel(word[len(word)-2]) {
+			return word[:len(word)-1] + "ied" + rest
+		}
+	}
+	return word + "ed" + rest
+}
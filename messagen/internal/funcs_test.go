@@ -0,0 +1,35 @@
+package internal
+
+import "testing"
+
+func TestEnglishFuncs(t *testing.T) {
+	tests := []struct {
+		name string
+		f    func(string) string
+		s    string
+		want string
+	}{
+		{name: "capitalize", f: capitalize, s: "élan vital", want: "Élan vital"},
+		{name: "capitalize empty", f: capitalize, s: "", want: ""},
+		{name: "plural", f: plural, s: "cat", want: "cats"},
+		{name: "plural of s, h and x", f: plural, s: "box", want: "boxes"},
+		{name: "plural of consonant and y", f: plural, s: "city", want: "cities"},
+		{name: "plural of vowel and y", f: plural, s: "day", want: "days"},
+		{name: "withArticle", f: withArticle, s: "cat", want: "a cat"},
+		{name: "withArticle of vowel", f: withArticle, s: "owl", want: "an owl"},
+		{name: "withArticle of unicorn", f: withArticle, s: "unicorn", want: "a unicorn"},
+		{name: "withArticle of silent h", f: withArticle, s: "hour", want: "an hour"},
+		{name: "withArticle of eu", f: withArticle, s: "European", want: "a European"},
+		{name: "past", f: past, s: "walk", want: "walked"},
+		{name: "past of e", f: past, s: "dance", want: "danced"},
+		{name: "past of consonant and y", f: past, s: "cry out", want: "cried out"},
+		{name: "past of vowel and y", f: past, s: "play", want: "played"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.f(tt.s); got != tt.want {
+				t.Errorf("%q is converted to %q, want %q", tt.s, got, tt.want)
+			}
+		})
+	}
+}
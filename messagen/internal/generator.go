@@ -0,0 +1,257 @@
+package internal
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+// maxGeneratedValues is the max number of values which a generator can generate.
+const maxGeneratedValues = 100000
+
+// DefaultDateLayout is the layout of dates which is used if the layout is not specified.
+const DefaultDateLayout = "2006-01-02"
+
+// Generator generates templates of a definition instead of listing them. Exactly one of the fields must be set.
+type Generator struct {
+	Int    *IntGenerator
+	Digits *DigitsGenerator
+	Date   *DateGenerator
+	Chars  *CharsGenerator
+}
+
+// IntGenerator generates integers from Min to Max. Step is 1 if it is not positive.
+type IntGenerator struct {
+	Min  int
+	Max  int
+	Step int
+}
+
+// DigitsGenerator generates zero-padded digits of Length like "000", "001", ..., "999".
+type DigitsGenerator struct {
+	Length int
+}
+
+// DateGenerator generates dates from From to To day by day.
+// From and To are parsed with DefaultDateLayout, and dates are formatted with Layout.
+// If Layout is empty, DefaultDateLayout is used.
+type DateGenerator struct {
+	From   string
+	To     string
+	Layout string
+}
+
+// CharsGenerator generates strings of Length characters which are chosen from Class.
+// Class is a character class like "A-Z0-9". Length is 1 if it is not positive.
+type CharsGenerator struct {
+	Class  string
+	Length int
+}
+
+// valueGenerator generates values by index, so that a value can be picked without generating all values.
+type valueGenerator interface {
+	// size returns the number of values. It returns an error if the parameters are invalid or too many values are generated.
+	size() (int, error)
+	// valueAt returns the i-th value. It must be called after size succeeds.
+	valueAt(i int) string
+}
+
+// valueGenerator returns the generator of the field which is set.
+func (g *Generator) valueGenerator() (valueGenerator, error) {
+	var generators []valueGenerator
+	if g.Int != nil {
+		generators = append(generators, g.Int)
+	}
+	if g.Digits != nil {
+		generators = append(generators, g.Digits)
+	}
+	if g.Date != nil {
+		generators = append(generators, g.Date)
+	}
+	if g.Chars != nil {
+		generators = append(generators, g.Chars)
+	}
+	if len(generators) != 1 {
+		return nil, xerrors.Errorf("generator must have exactly one of Int, Digits, Date and Chars, but has %d", len(generators))
+	}
+	return generators[0], nil
+}
+
+// Templates returns the raw templates of all values which the generator generates.
+func (g *Generator) Templates() ([]RawTemplate, error) {
+	vg, n, err := g.sizedValueGenerator()
+	if err != nil {
+		return nil, err
+	}
+	rawTemplates := make([]RawTemplate, 0, n)
+	for i := 0; i < n; i++ {
+		rawTemplates = append(rawTemplates, escapeTemplate(vg.valueAt(i)))
+	}
+	return rawTemplates, nil
+}
+
+// sizedValueGenerator returns the value generator and the number of its values.
+func (g *Generator) sizedValueGenerator() (valueGenerator, int, error) {
+	vg, err := g.valueGenerator()
+	if err != nil {
+		return nil, 0, err
+	}
+	n, err := vg.size()
+	if err != nil {
+		return nil, 0, xerrors.Errorf("failed to generate values: %w", err)
+	}
+	return vg, n, nil
+}
+
+// escapeTemplate returns the raw template which generates the value as it is.
+func escapeTemplate(value string) RawTemplate {
+	return RawTemplate(strings.ReplaceAll(value, "{{", `{{"{{"}}`))
+}
+
+func checkGeneratedValuesNum(n int) error {
+	if n > maxGeneratedValues {
+		return xerrors.Errorf("too many values are generated: %d (max: %d)", n, maxGeneratedValues)
+	}
+	return nil
+}
+
+func (g *IntGenerator) step() int {
+	if g.Step <= 0 {
+		return 1
+	}
+	return g.Step
+}
+
+func (g *IntGenerator) size() (int, error) {
+	if g.Min > g.Max {
+		return 0, xerrors.Errorf("Min(%d) of Int generator is greater than Max(%d)", g.Min, g.Max)
+	}
+	// the range is computed in uint64 because Max-Min can overflow int
+	steps := (uint64(g.Max) - uint64(g.Min)) / uint64(g.step())
+	if steps >= maxGeneratedValues {
+		return 0, xerrors.Errorf("too many values are generated by Int generator from %d to %d (max: %d)", g.Min, g.Max, maxGeneratedValues)
+	}
+	return int(steps) + 1, nil
+}
+
+func (g *IntGenerator) valueAt(i int) string {
+	// Min+i*Step is between Min and Max, so the result is correct even if i*Step overflows int
+	return strconv.Itoa(g.Min + i*g.step())
+}
+
+func (g *DigitsGenerator) size() (int, error) {
+	if g.Length <= 0 {
+		return 0, xerrors.Errorf("Length(%d) of Digits generator must be positive", g.Length)
+	}
+	n := 1
+	for i := 0; i < g.Length; i++ {
+		n *= 10
+		if err := checkGeneratedValuesNum(n); err != nil {
+			return 0, err
+		}
+	}
+	return n, nil
+}
+
+func (g *DigitsGenerator) valueAt(i int) string {
+	return fmt.Sprintf("%0*d", g.Length, i)
+}
+
+func (g *DateGenerator) from() (time.Time, error) {
+	return time.Parse(DefaultDateLayout, g.From)
+}
+
+func (g *DateGenerator) size() (int, error) {
+	from, err := g.from()
+	if err != nil {
+		return 0, xerrors.Errorf("failed to parse From of Date generator: %w", err)
+	}
+	to, err := time.Parse(DefaultDateLayout, g.To)
+	if err != nil {
+		return 0, xerrors.Errorf("failed to parse To of Date generator: %w", err)
+	}
+	if from.After(to) {
+		return 0, xerrors.Errorf("From(%s) of Date generator is after To(%s)", g.From, g.To)
+	}
+	n := int(to.Sub(from).Hours()/24) + 1
+	if err := checkGeneratedValuesNum(n); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+func (g *DateGenerator) valueAt(i int) string {
+	from, _ := g.from()
+	layout := g.Layout
+	if layout == "" {
+		layout = DefaultDateLayout
+	}
+	return from.AddDate(0, 0, i).Format(layout)
+}
+
+func (g *CharsGenerator) length() int {
+	if g.Length <= 0 {
+		return 1
+	}
+	return g.Length
+}
+
+func (g *CharsGenerator) size() (int, error) {
+	chars, err := parseCharClass(g.Class)
+	if err != nil {
+		return 0, xerrors.Errorf("failed to parse Class of Chars generator: %w", err)
+	}
+	n := 1
+	for i := 0; i < g.length(); i++ {
+		n *= len(chars)
+		if err := checkGeneratedValuesNum(n); err != nil {
+			return 0, err
+		}
+	}
+	return n, nil
+}
+
+// valueAt returns the i-th string in the order that the last character changes first.
+func (g *CharsGenerator) valueAt(i int) string {
+	chars, _ := parseCharClass(g.Class)
+	value := make([]rune, g.length())
+	for j := len(value) - 1; j >= 0; j-- {
+		value[j] = chars[i%len(chars)]
+		i /= len(chars)
+	}
+	return string(value)
+}
+
+// parseCharClass returns characters of the character class like "A-Z0-9_".
+// Duplicated characters are removed.
+func parseCharClass(class string) ([]rune, error) {
+	runes := []rune(class)
+	if len(runes) == 0 {
+		return nil, xerrors.New("character class is empty")
+	}
+	var chars []rune
+	added := map[rune]bool{}
+	add := func(c rune) {
+		if !added[c] {
+			added[c] = true
+			chars = append(chars, c)
+		}
+	}
+	for i := 0; i < len(runes); i++ {
+		if i+2 < len(runes) && runes[i+1] == '-' {
+			if runes[i] > runes[i+2] {
+				return nil, xerrors.Errorf("invalid range in character class: %s", string(runes[i:i+3]))
+			}
+			for c := runes[i]; c <= runes[i+2]; c++ {
+				add(c)
+			}
+			i += 2
+			continue
+		}
+		add(runes[i])
+	}
+	return chars, nil
+}
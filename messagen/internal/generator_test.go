@@ -0,0 +1,240 @@
+package internal
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestGenerator_Templates(t *testing.T) {
+	tests := []struct {
+		name      string
+		generator *Generator
+		want      []RawTemplate
+		wantLen   int
+		wantErr   bool
+	}{
+		{
+			name:      "Int generates integers from Min to Max",
+			generator: &Generator{Int: &IntGenerator{Min: -1, Max: 2}},
+			want:      []RawTemplate{"-1", "0", "1", "2"},
+		},
+		{
+			name:      "Int generates integers by Step",
+			generator: &Generator{Int: &IntGenerator{Min: 0, Max: 10, Step: 5}},
+			want:      []RawTemplate{"0", "5", "10"},
+		},
+		{
+			name:      "Int generates integers near the max int",
+			generator: &Generator{Int: &IntGenerator{Min: math.MaxInt64 - 1, Max: math.MaxInt64}},
+			want:      []RawTemplate{"9223372036854775806", "9223372036854775807"},
+		},
+		{
+			name:      "Int generates integers near the min int",
+			generator: &Generator{Int: &IntGenerator{Min: math.MinInt64, Max: math.MinInt64 + 1}},
+			want:      []RawTemplate{"-9223372036854775808", "-9223372036854775807"},
+		},
+		{
+			name:      "Int does not step over the max int",
+			generator: &Generator{Int: &IntGenerator{Min: math.MaxInt64 - 10, Max: math.MaxInt64, Step: 7}},
+			want:      []RawTemplate{"9223372036854775797", "9223372036854775804"},
+		},
+		{
+			name:      "Int generates Min and Max with the max step",
+			generator: &Generator{Int: &IntGenerator{Min: math.MinInt64, Max: math.MaxInt64, Step: math.MaxInt64}},
+			want:      []RawTemplate{"-9223372036854775808", "-1", "9223372036854775806"},
+		},
+		{
+			name:      "Int returns error if the full int range is generated",
+			generator: &Generator{Int: &IntGenerator{Min: math.MinInt64, Max: math.MaxInt64}},
+			wantErr:   true,
+		},
+		{
+			name:      "Int returns error if too many values are generated",
+			generator: &Generator{Int: &IntGenerator{Min: 0, Max: 100000}},
+			wantErr:   true,
+		},
+		{
+			name:      "Int returns error if Min is greater than Max",
+			generator: &Generator{Int: &IntGenerator{Min: 1, Max: 0}},
+			wantErr:   true,
+		},
+		{
+			name:      "Digits generates zero-padded digits",
+			generator: &Generator{Digits: &DigitsGenerator{Length: 3}},
+			wantLen:   1000,
+		},
+		{
+			name:      "Digits returns error if too many values are generated",
+			generator: &Generator{Digits: &DigitsGenerator{Length: 6}},
+			wantErr:   true,
+		},
+		{
+			name:      "Date generates dates day by day",
+			generator: &Generator{Date: &DateGenerator{From: "2020-02-28", To: "2020-03-01"}},
+			want:      []RawTemplate{"2020-02-28", "2020-02-29", "2020-03-01"},
+		},
+		{
+			name:      "Date formats dates with Layout",
+			generator: &Generator{Date: &DateGenerator{From: "2020-12-31", To: "2021-01-01", Layout: "Jan 2"}},
+			want:      []RawTemplate{"Dec 31", "Jan 1"},
+		},
+		{
+			name:      "Date returns error if From is invalid",
+			generator: &Generator{Date: &DateGenerator{From: "2020/01/01", To: "2020-01-02"}},
+			wantErr:   true,
+		},
+		{
+			name:      "Chars generates characters in class",
+			generator: &Generator{Chars: &CharsGenerator{Class: "a-cX"}},
+			want:      []RawTemplate{"a", "b", "c", "X"},
+		},
+		{
+			name:      "Chars generates combinations of characters",
+			generator: &Generator{Chars: &CharsGenerator{Class: "ab", Length: 2}},
+			want:      []RawTemplate{"aa", "ab", "ba", "bb"},
+		},
+		{
+			name:      "Chars escapes template delimiters",
+			generator: &Generator{Chars: &CharsGenerator{Class: "{", Length: 2}},
+			want:      []RawTemplate{`{{"{{"}}`},
+		},
+		{
+			name:      "returns error if no generator is set",
+			generator: &Generator{},
+			wantErr:   true,
+		},
+		{
+			name: "returns error if multiple generators are set",
+			generator: &Generator{
+				Int:    &IntGenerator{Min: 0, Max: 1},
+				Digits: &DigitsGenerator{Length: 1},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.generator.Templates()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Generator.Templates() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if tt.want != nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Generator.Templates() = %v, want %v", got, tt.want)
+			}
+			if tt.wantLen != 0 && len(got) != tt.wantLen {
+				t.Errorf("Generator.Templates() returns %d templates, want %d", len(got), tt.wantLen)
+			}
+		})
+	}
+}
+
+func TestDefinitionRepository_Generate_WithGenerator(t *testing.T) {
+	defs := []*RawDefinition{
+		{
+			Type:         "Test",
+			RawTemplates: []RawTemplate{"No.{{.Num}}{{.Check}}"},
+		},
+		{
+			Type:           "Check",
+			RawTemplates:   []RawTemplate{""},
+			RawConstraints: RawConstraints{"Num/": "^0[0-9]7$"},
+		},
+		{
+			Type:      "Num",
+			Generator: &Generator{Digits: &DigitsGenerator{Length: 3}},
+		},
+	}
+	d := NewDefinitionRepository(nil)
+	if err := d.Add(defs...); err != nil {
+		t.Fatalf("unexpected error occurred in DefinitionRepository.Add(): %s", err)
+	}
+	got, err := d.Generate("Test", nil, 1)
+	if err != nil {
+		t.Fatalf("unexpected error occurred in DefinitionRepository.Generate(): %s", err)
+	}
+	if len(got) != 1 || len(got[0]) != len("No.007") || got[0][:4] != "No.0" || got[0][5] != '7' {
+		t.Errorf("DefinitionRepository.Generate() = %v, want No.0N7", got)
+	}
+
+	if err := d.Add(&RawDefinition{
+		Type:         "Invalid",
+		RawTemplates: []RawTemplate{"a"},
+		Generator:    &Generator{Int: &IntGenerator{Min: 0, Max: 1}},
+	}); err == nil {
+		t.Errorf("DefinitionRepository.Add() should return error if definition has both templates and generator")
+	}
+}
+
+func TestDefinitionRepository_Generate_WithLargeGenerator(t *testing.T) {
+	defs := []*RawDefinition{
+		{
+			Type:         "Test",
+			RawTemplates: []RawTemplate{"{{.Code}}-{{.Other}}"},
+			Aliases:      Aliases{"Other": &Alias{ReferType: "Code"}},
+		},
+		{
+			Type:      "Code",
+			Generator: &Generator{Digits: &DigitsGenerator{Length: 5}},
+		},
+	}
+	d := NewDefinitionRepository(&DefinitionRepositoryOption{
+		TemplatePickers:             []TemplatePicker{RandomTemplatePicker},
+		RandSource:                  rand.NewSource(1),
+		PickGeneratedValuesAtRandom: true,
+	})
+	if err := d.Add(defs...); err != nil {
+		t.Fatalf("unexpected error occurred in DefinitionRepository.Add(): %s", err)
+	}
+
+	start := time.Now()
+	got, err := d.Generate("Test", nil, 100)
+	if err != nil {
+		t.Fatalf("unexpected error occurred in DefinitionRepository.Generate(): %s", err)
+	}
+	// building all 100000 templates for each resolution takes much longer
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("DefinitionRepository.Generate() takes %s, want less than 1s", elapsed)
+	}
+
+	msgRegExp := regexp.MustCompile(`^(\d{5})-(\d{5})$`)
+	for _, msg := range got {
+		matches := msgRegExp.FindStringSubmatch(string(msg))
+		if matches == nil {
+			t.Fatalf("DefinitionRepository.Generate() = %v, want messages like 01234-56789", msg)
+		}
+		// alias which does not allow duplicate does not pick the same value
+		if matches[1] == matches[2] {
+			t.Errorf("DefinitionRepository.Generate() = %v, want different values", msg)
+		}
+	}
+}
+
+func TestGeneratedValuePicker(t *testing.T) {
+	const n = 100
+	p := newGeneratedValuePicker(n)
+	r := rand.New(rand.NewSource(1))
+	picked := map[int]bool{}
+	for {
+		i, ok := p.pop(r)
+		if !ok {
+			break
+		}
+		if i < 0 || i >= n || picked[i] {
+			t.Fatalf("generatedValuePicker.pop() = %d, which is out of range or already picked", i)
+		}
+		picked[i] = true
+	}
+	if len(picked) != n {
+		t.Errorf("generatedValuePicker picks %d indexes, want %d", len(picked), n)
+	}
+	if len(p.swapped) != 0 {
+		t.Errorf("generatedValuePicker has %d swapped indexes after all indexes are picked, want 0", len(p.swapped))
+	}
+}
@@ -0,0 +1,53 @@
+package internal
+
+// History is messages and templates which were generated recently and should not be generated again.
+type History struct {
+	messages  map[Message]bool
+	templates map[DefinitionType]map[RawTemplate]bool
+}
+
+func NewHistory() *History {
+	return &History{
+		messages:  map[Message]bool{},
+		templates: map[DefinitionType]map[RawTemplate]bool{},
+	}
+}
+
+// AddMessage adds the message which should be avoided.
+func (h *History) AddMessage(msg Message) {
+	h.messages[msg] = true
+}
+
+// AddTemplate adds the template of the definition type which should be avoided.
+func (h *History) AddTemplate(defType DefinitionType, template RawTemplate) {
+	templates, ok := h.templates[defType]
+	if !ok {
+		templates = map[RawTemplate]bool{}
+		h.templates[defType] = templates
+	}
+	templates[template] = true
+}
+
+// HasMessage reports whether the message should be avoided.
+func (h *History) HasMessage(msg Message) bool {
+	return h.messages[msg]
+}
+
+// HasTemplate reports whether the template of the definition type should be avoided.
+func (h *History) HasTemplate(defType DefinitionType, template RawTemplate) bool {
+	return h.templates[defType][template]
+}
+
+// NotInHistoryTemplatePicker picks templates which are not in the history of the state.
+func NotInHistoryTemplatePicker(def *DefinitionWithAlias, state *State) (Templates, error) {
+	if state.history == nil {
+		return def.Templates, nil
+	}
+	var newTemplates Templates
+	for _, template := range def.Templates {
+		if !state.history.HasTemplate(def.Type, template.Raw) {
+			newTemplates = append(newTemplates, template)
+		}
+	}
+	return newTemplates, nil
+}
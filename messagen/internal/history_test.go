@@ -0,0 +1,47 @@
+package internal
+
+import (
+	"testing"
+
+	"golang.org/x/xerrors"
+)
+
+func TestDefinitionRepository_Generate_WithHistory(t *testing.T) {
+	defs := []*RawDefinition{
+		{Type: "Test", RawTemplates: []RawTemplate{"{{.Greeting}} {{.Name}}"}},
+		{Type: "Greeting", RawTemplates: []RawTemplate{"hello", "hi"}},
+		{Type: "Name", RawTemplates: []RawTemplate{"alice", "bob"}},
+	}
+	d := NewDefinitionRepository(&DefinitionRepositoryOption{TemplatePickers: []TemplatePicker{RandomTemplatePicker}})
+	if err := d.Add(defs...); err != nil {
+		t.Fatalf("unexpected error occurred in DefinitionRepository.Add(): %s", err)
+	}
+
+	history := NewHistory()
+	history.AddMessage("hello alice")
+	history.AddMessage("hi bob")
+	history.AddTemplate("Name", "alice")
+	for i := 0; i < 10; i++ {
+		state := NewState(nil)
+		state.SetHistory(history)
+		got, err := d.Generate("Test", state, 1)
+		if err != nil {
+			t.Fatalf("unexpected error occurred in DefinitionRepository.Generate(): %s", err)
+		}
+		if want := Message("hello bob"); got[0] != want {
+			t.Errorf("DefinitionRepository.Generate() = %v, want %v", got[0], want)
+		}
+	}
+
+	history.AddMessage("hello bob")
+	state := NewState(nil)
+	state.SetHistory(history)
+	_, err := d.Generate("Test", state, 1)
+	var noMsgErr *NoMessageError
+	if !xerrors.As(err, &noMsgErr) {
+		t.Fatalf("DefinitionRepository.Generate() error = %v, want NoMessageError", err)
+	}
+	if got := noMsgErr.MostCommon[0].Rejection.Reason; got != InHistory {
+		t.Errorf("NoMessageError.MostCommon[0].Reason = %v, want %v", got, InHistory)
+	}
+}
@@ -0,0 +1,94 @@
+package internal
+
+import (
+	"fmt"
+	"time"
+)
+
+type LimitKind int
+
+const (
+	// DepthLimit is the limit of the derivation depth.
+	DepthLimit LimitKind = iota
+	// StepLimit is the limit of the number of search steps.
+	StepLimit
+	// TimeLimit is the limit of the wall-clock time of the search.
+	TimeLimit
+)
+
+func (l LimitKind) String() string {
+	switch l {
+	case DepthLimit:
+		return "max derivation depth"
+	case StepLimit:
+		return "max search steps"
+	case TimeLimit:
+		return "search timeout"
+	}
+	return fmt.Sprintf("unknown limit(%d)", int(l))
+}
+
+// Limits restricts the search of messages. Zero value means no limit.
+type Limits struct {
+	// MaxDepth is the max depth of derivation trees. The root definition type is depth 1.
+	MaxDepth int
+	// MaxSteps is the max number of templates which are tried while the search.
+	MaxSteps int
+	// Timeout is the max wall-clock time of the search.
+	Timeout time.Duration
+}
+
+// LimitExceededError is returned when the search exceeds one of Limits.
+type LimitExceededError struct {
+	Limit LimitKind
+	// DefinitionType is the definition type which was being resolved when the limit was exceeded.
+	DefinitionType DefinitionType
+	// Path is the definition types which are referred from the root to DefinitionType.
+	Path []DefinitionType
+}
+
+// maxErrorPathLen is the max number of definition types in the path which LimitExceededError prints.
+const maxErrorPathLen = 10
+
+func (e *LimitExceededError) Error() string {
+	path := formatPath(e.Path)
+	if len(e.Path) > maxErrorPathLen {
+		path = "... > " + formatPath(e.Path[len(e.Path)-maxErrorPathLen:])
+	}
+	return fmt.Sprintf("%s is exceeded while resolving %q (%s)", e.Limit, e.DefinitionType, path)
+}
+
+// budget tracks consumption of Limits while a search.
+type budget struct {
+	limits   Limits
+	steps    int
+	deadline time.Time
+}
+
+func newBudget(limits Limits) *budget {
+	b := &budget{limits: limits}
+	if limits.Timeout > 0 {
+		b.deadline = time.Now().Add(limits.Timeout)
+	}
+	return b
+}
+
+// checkDepth returns LimitExceededError if the path is deeper than MaxDepth.
+func (b *budget) checkDepth(defType DefinitionType, path []DefinitionType) error {
+	if b.limits.MaxDepth > 0 && len(path) > b.limits.MaxDepth {
+		return &LimitExceededError{Limit: DepthLimit, DefinitionType: defType, Path: path}
+	}
+	return nil
+}
+
+// step consumes one search step, and returns LimitExceededError if MaxSteps or Timeout is exceeded.
+func (b *budget) step(defType DefinitionType, path []DefinitionType) error {
+	b.steps++
+	if b.limits.MaxSteps > 0 && b.steps > b.limits.MaxSteps {
+		return &LimitExceededError{Limit: StepLimit, DefinitionType: defType, Path: path}
+	}
+	if !b.deadline.IsZero() && time.Now().After(b.deadline) {
+		return &LimitExceededError{Limit: TimeLimit, DefinitionType: defType, Path: path}
+	}
+	return nil
+}
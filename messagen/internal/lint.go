@@ -0,0 +1,497 @@
+package internal
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type LintSeverity int
+
+const (
+	// LintWarning means that the definitions may not work as expected.
+	LintWarning LintSeverity = iota
+	// LintError means that the definitions fail or never finish at generation time.
+	LintError
+)
+
+func (l LintSeverity) String() string {
+	switch l {
+	case LintWarning:
+		return "warning"
+	case LintError:
+		return "error"
+	}
+	return fmt.Sprintf("unknown severity(%d)", int(l))
+}
+
+// LintIssue is a problem of definitions which is found by Lint.
+type LintIssue struct {
+	Severity LintSeverity
+	// DefinitionIndex is the index of the offending definition. If the issue is not related to a definition, it is -1.
+	DefinitionIndex int
+	DefinitionType  DefinitionType
+	Message         string
+}
+
+func (l *LintIssue) String() string {
+	if l.DefinitionIndex < 0 {
+		return fmt.Sprintf("%s: %s", l.Severity, l.Message)
+	}
+	return fmt.Sprintf("%s: definitions[%d] (%s): %s", l.Severity, l.DefinitionIndex, l.DefinitionType, l.Message)
+}
+
+type LintIssues []*LintIssue
+
+// HasError reports whether the issues include any error.
+func (l LintIssues) HasError() bool {
+	for _, issue := range l {
+		if issue.Severity == LintError {
+			return true
+		}
+	}
+	return false
+}
+
+// linter analyzes the type dependency graph of definitions.
+type linter struct {
+	rawDefs []*RawDefinition
+	// defs holds parsed definitions. If a definition can not be parsed, it is nil.
+	defs       []*Definition
+	root       DefinitionType
+	stateKeys  map[DefinitionType]bool
+	types      map[DefinitionType][]int
+	addedTypes map[DefinitionType]bool
+	aliasNames map[DefinitionType]bool
+	// providedTypes are types which have definition providers.
+	providedTypes map[DefinitionType]bool
+	funcs         *FuncSet
+	issues        LintIssues
+}
+
+// Lint statically analyzes definitions and reports issues with the index of the offending definition.
+// Keys of the initial state and providedTypes which have definition providers are regarded as defined types.
+// Templates can use funcs. If funcs is nil, built-in functions are used.
+func Lint(rawDefs []*RawDefinition, root DefinitionType, initialState *State, funcs *FuncSet, providedTypes ...DefinitionType) LintIssues {
+	l := &linter{
+		rawDefs:       rawDefs,
+		root:          root,
+		stateKeys:     map[DefinitionType]bool{},
+		types:         map[DefinitionType][]int{},
+		addedTypes:    map[DefinitionType]bool{},
+		aliasNames:    map[DefinitionType]bool{},
+		funcs:         funcs,
+		providedTypes: map[DefinitionType]bool{},
+	}
+	if initialState != nil {
+		for key := range initialState.m {
+			l.stateKeys[DefinitionType(key)] = true
+		}
+	}
+	for _, defType := range providedTypes {
+		l.providedTypes[defType] = true
+	}
+
+	l.parse()
+	l.checkRoot()
+	l.checkTemplates()
+	l.checkConstraints()
+	l.checkAssignments()
+	l.checkAliases()
+	l.checkOrders()
+	l.checkReachability()
+	l.checkCycles()
+
+	sort.SliceStable(l.issues, func(i, j int) bool {
+		if l.issues[i].DefinitionIndex != l.issues[j].DefinitionIndex {
+			return l.issues[i].DefinitionIndex < l.issues[j].DefinitionIndex
+		}
+		return l.issues[i].Message < l.issues[j].Message
+	})
+	return l.issues
+}
+
+func (l *linter) report(severity LintSeverity, index int, format string, a ...interface{}) {
+	issue := &LintIssue{Severity: severity, DefinitionIndex: index, Message: fmt.Sprintf(format, a...)}
+	if index >= 0 {
+		issue.DefinitionType = l.rawDefs[index].Type
+	}
+	l.issues = append(l.issues, issue)
+}
+
+// parse parses templates and constraints of each definition, and collects types which can be set to the state.
+func (l *linter) parse() {
+	for i, rawDef := range l.rawDefs {
+		l.types[rawDef.Type] = append(l.types[rawDef.Type], i)
+		for aliasName := range rawDef.Aliases {
+			l.aliasNames[DefinitionType(aliasName)] = true
+		}
+
+		ok := true
+		for _, rawTemplate := range rawDef.RawTemplates {
+			if _, err := NewTemplateWithFuncs(rawTemplate, rawDef.Order, l.funcs); err != nil {
+				l.report(LintError, i, "template %q can not be parsed: %s", rawTemplate, err)
+				ok = false
+			}
+		}
+		for rawKey, rawValue := range rawDef.RawConstraints {
+			ok = l.parseConstraint(i, rawKey, rawValue) && ok
+		}
+		for rawKey, rawValues := range rawDef.RawValueSetConstraints {
+			ok = l.parseConstraint(i, rawKey, rawValues...) && ok
+		}
+		ok = l.parseAssignments(i, rawDef.Sets) && ok
+		for _, attrs := range rawDef.TemplateAttributes {
+			if attrs == nil {
+				continue
+			}
+			for rawKey, rawValue := range attrs.Constraints {
+				ok = l.parseConstraint(i, rawKey, rawValue) && ok
+			}
+			for rawKey, rawValues := range attrs.ValueSets {
+				ok = l.parseConstraint(i, rawKey, rawValues...) && ok
+			}
+			ok = l.parseAssignments(i, attrs.Sets) && ok
+		}
+		for _, group := range rawDef.RawConstraintGroups {
+			for rawKey, rawValue := range group.Constraints {
+				ok = l.parseConstraint(i, rawKey, rawValue) && ok
+			}
+			for rawKey, rawValues := range group.ValueSets {
+				ok = l.parseConstraint(i, rawKey, rawValues...) && ok
+			}
+		}
+
+		var def *Definition
+		if ok {
+			d, err := NewDefinitionWithFuncs(rawDef, l.funcs)
+			if err != nil {
+				l.report(LintError, i, "definition is invalid: %s", err)
+			} else {
+				def = d
+			}
+		}
+		l.defs = append(l.defs, def)
+	}
+}
+
+// parseAssignments checks templates of the assignments, and reports whether they are valid.
+func (l *linter) parseAssignments(i int, assignments RawAssignments) bool {
+	ok := true
+	for rawKey, rawTemplate := range assignments {
+		l.addedTypes[DefinitionType(strings.TrimSuffix(string(rawKey), "?"))] = true
+		if _, err := NewTemplateWithFuncs(rawTemplate, nil, l.funcs); err != nil {
+			l.report(LintError, i, "assignment %q can not be parsed: %s", rawKey, err)
+			ok = false
+		}
+	}
+	return ok
+}
+
+// parseConstraint checks the constraint key and values, and reports whether they are valid.
+func (l *linter) parseConstraint(i int, rawKey RawConstraintKey, rawValues ...RawConstraintValue) bool {
+	key, err := rawKey.Parse()
+	if err != nil {
+		l.report(LintError, i, "constraint key %q is invalid: %s", rawKey, err)
+		return false
+	}
+	if key.WillAddValue {
+		l.addedTypes[key.DefinitionType] = true
+	}
+	ok := true
+	for _, rawValue := range rawValues {
+		if !key.HasRegExpValue && rawValue.Reference() != "" {
+			continue
+		}
+		if key.HasRegExpValue {
+			if _, err := rawValue.Compile(); err != nil {
+				l.report(LintError, i, "regexp of constraint %q can not be compiled: %s", rawKey, err)
+				ok = false
+			}
+		}
+		if key.Comparison != NoComparison {
+			if _, err := rawValue.ParseComparison(key.Comparison); err != nil {
+				l.report(LintError, i, "value of constraint %q can not be compared as number: %s", rawKey, err)
+				ok = false
+			}
+		}
+	}
+	return ok
+}
+
+// isSettable reports whether a value of the type can be set to the state.
+func (l *linter) isSettable(defType DefinitionType) bool {
+	_, isDefined := l.types[defType]
+	return isDefined || l.aliasNames[defType] || l.addedTypes[defType] || l.stateKeys[defType] || l.providedTypes[defType]
+}
+
+func (l *linter) checkRoot() {
+	if _, ok := l.types[l.root]; !ok && !l.stateKeys[l.root] && !l.providedTypes[l.root] {
+		l.report(LintError, -1, "root type %q has no definitions", l.root)
+	}
+}
+
+func (l *linter) checkTemplates() {
+	for i, def := range l.defs {
+		if def == nil {
+			continue
+		}
+		if len(def.Templates) == 0 && def.generated == nil {
+			l.report(LintWarning, i, "definition has no templates, so it is never picked")
+		}
+		reported := map[DefinitionType]bool{}
+		for _, template := range def.Templates {
+			// types which are never set are false in conditions
+			conditionDepends := template.conditionDepends()
+			for _, depend := range *template.Depends {
+				if def.IsAlias(depend) || reported[depend] || conditionDepends.has(depend) {
+					continue
+				}
+				if l.isSettable(depend) {
+					continue
+				}
+				reported[depend] = true
+				l.report(LintError, i, "template %q refers undefined type %q", template.Raw, depend)
+			}
+		}
+	}
+}
+
+func (l *linter) checkConstraints() {
+	for i, def := range l.defs {
+		if def == nil {
+			continue
+		}
+		for _, constraint := range def.allConstraintsWithTemplates() {
+			if !constraint.key.WillAddValue && !l.isSettable(constraint.key.DefinitionType) {
+				l.report(LintWarning, i, "constraint %q refers type %q which is never set", constraint.key.Raw, constraint.key.DefinitionType)
+			}
+			for _, ref := range constraint.references() {
+				if !l.isSettable(ref) {
+					l.report(LintWarning, i, "value of constraint %q refers type %q which is never set", constraint.key.Raw, ref)
+				}
+			}
+		}
+	}
+}
+
+func (l *linter) checkAssignments() {
+	for i, def := range l.defs {
+		if def == nil {
+			continue
+		}
+		for _, assignment := range def.allAssignments() {
+			for _, depend := range *assignment.Template.Depends {
+				if depend == def.Type || assignment.Template.conditionDepends().has(depend) {
+					continue
+				}
+				if !l.isSettable(depend) {
+					l.report(LintError, i, "assignment of %q refers undefined type %q", assignment.Key, depend)
+				}
+			}
+		}
+	}
+}
+
+func (l *linter) checkAliases() {
+	for i, rawDef := range l.rawDefs {
+		for aliasName, alias := range rawDef.Aliases {
+			if _, ok := l.types[alias.ReferType]; !ok {
+				l.report(LintError, i, "alias %q refers type %q which has no definitions", aliasName, alias.ReferType)
+			}
+		}
+	}
+}
+
+func (l *linter) checkOrders() {
+	for i, def := range l.defs {
+		if def == nil {
+			continue
+		}
+		for _, order := range def.Order {
+			found := false
+			for _, template := range def.Templates {
+				for _, depend := range *template.Depends {
+					if depend == order {
+						found = true
+					}
+				}
+			}
+			if !found {
+				l.report(LintWarning, i, "order entry %q is not referred from any template", order)
+			}
+		}
+	}
+}
+
+// referTypes returns definition types which are referred from templates and assignments of the definition.
+// Alias names are replaced with the referred types.
+func (l *linter) referTypes(def *Definition) (types []DefinitionType) {
+	for _, template := range def.Templates {
+		types = append(types, l.templateReferTypes(def, template)...)
+	}
+	return
+}
+
+// templateReferTypes returns definition types which are referred when the template of the definition is picked.
+func (l *linter) templateReferTypes(def *Definition, template *Template) (types []DefinitionType) {
+	depends := append([]DefinitionType{}, *template.Depends...)
+	assignments := append(def.Assignments[:len(def.Assignments):len(def.Assignments)], template.Assignments...)
+	for _, assignment := range assignments {
+		for _, depend := range *assignment.Template.Depends {
+			// the type of the definition is set before assignments are applied
+			if depend != def.Type {
+				depends = append(depends, depend)
+			}
+		}
+	}
+	for _, depend := range depends {
+		if alias, ok := def.Aliases[AliasName(depend)]; ok {
+			depend = alias.ReferType
+		}
+		if _, ok := l.types[depend]; ok {
+			types = append(types, depend)
+		}
+	}
+	return
+}
+
+func (l *linter) checkReachability() {
+	if _, ok := l.types[l.root]; !ok {
+		return
+	}
+	reachable := map[DefinitionType]bool{l.root: true}
+	queue := []DefinitionType{l.root}
+	for len(queue) > 0 {
+		defType := queue[0]
+		queue = queue[1:]
+		for _, i := range l.types[defType] {
+			if l.defs[i] == nil {
+				continue
+			}
+			for _, referType := range l.referTypes(l.defs[i]) {
+				if !reachable[referType] {
+					reachable[referType] = true
+					queue = append(queue, referType)
+				}
+			}
+		}
+	}
+
+	for i, rawDef := range l.rawDefs {
+		if !reachable[rawDef.Type] {
+			l.report(LintWarning, i, "definition is unreachable from root type %q", l.root)
+		}
+	}
+}
+
+// hasExit reports whether any template of the types refers none of the types, so that the recursion can stop.
+func (l *linter) hasExit(types []DefinitionType) bool {
+	inTypes := map[DefinitionType]bool{}
+	for _, defType := range types {
+		inTypes[defType] = true
+	}
+	for _, defType := range types {
+		for _, i := range l.types[defType] {
+			if l.defs[i] == nil {
+				continue
+			}
+			for _, template := range l.defs[i].Templates {
+				isExit := true
+				for _, referType := range l.templateReferTypes(l.defs[i], template) {
+					if inTypes[referType] {
+						isExit = false
+					}
+				}
+				if isExit {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// checkCycles reports strongly connected components of the type dependency graph by Tarjan's algorithm.
+// Components which have a template to stop the recursion are reported as warnings.
+func (l *linter) checkCycles() {
+	var types []DefinitionType
+	for defType := range l.types {
+		types = append(types, defType)
+	}
+	sort.Slice(types, func(i, j int) bool { return l.types[types[i]][0] < l.types[types[j]][0] })
+
+	edges := map[DefinitionType][]DefinitionType{}
+	for _, defType := range types {
+		for _, i := range l.types[defType] {
+			if l.defs[i] != nil {
+				edges[defType] = append(edges[defType], l.referTypes(l.defs[i])...)
+			}
+		}
+	}
+
+	index := 0
+	indexes := map[DefinitionType]int{}
+	lowLinks := map[DefinitionType]int{}
+	onStack := map[DefinitionType]bool{}
+	var stack []DefinitionType
+
+	var visit func(defType DefinitionType)
+	visit = func(defType DefinitionType) {
+		indexes[defType] = index
+		lowLinks[defType] = index
+		index++
+		stack = append(stack, defType)
+		onStack[defType] = true
+
+		selfLoop := false
+		for _, next := range edges[defType] {
+			if next == defType {
+				selfLoop = true
+			}
+			if _, visited := indexes[next]; !visited {
+				visit(next)
+				if lowLinks[next] < lowLinks[defType] {
+					lowLinks[defType] = lowLinks[next]
+				}
+			} else if onStack[next] && indexes[next] < lowLinks[defType] {
+				lowLinks[defType] = indexes[next]
+			}
+		}
+
+		if lowLinks[defType] != indexes[defType] {
+			return
+		}
+		var component []DefinitionType
+		for {
+			last := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[last] = false
+			component = append(component, last)
+			if last == defType {
+				break
+			}
+		}
+		if len(component) == 1 && !selfLoop {
+			return
+		}
+
+		sort.Slice(component, func(i, j int) bool { return l.types[component[i]][0] < l.types[component[j]][0] })
+		var names []string
+		for _, t := range component {
+			names = append(names, string(t))
+		}
+		// recursion which can stop may be intended like lists, but it can exceed limits
+		severity := LintError
+		if l.hasExit(component) {
+			severity = LintWarning
+		}
+		l.report(severity, l.types[component[0]][0], "definition types refer each other recursively: %s", strings.Join(names, ", "))
+	}
+
+	for _, defType := range types {
+		if _, visited := indexes[defType]; !visited {
+			visit(defType)
+		}
+	}
+}
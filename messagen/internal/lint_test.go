@@ -0,0 +1,110 @@
+package internal
+
+import (
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	tests := []struct {
+		name         string
+		defs         []*RawDefinition
+		initialState *State
+		want         []*LintIssue
+	}{
+		{
+			name: "valid definitions should not have issues",
+			defs: []*RawDefinition{
+				{
+					Type:         "Root",
+					RawTemplates: []RawTemplate{"{{.A}}{{.AnotherA}}{{.K}}"},
+					Aliases:      Aliases{"AnotherA": &Alias{ReferType: "A"}},
+					Order:        []DefinitionType{"AnotherA"},
+				},
+				{Type: "A", RawTemplates: []RawTemplate{"a1", "a2"}, RawConstraints: RawConstraints{"K+": "V", "Init": "x"}},
+			},
+			initialState: NewState(MessageMap{"Init": "x"}),
+			want:         nil,
+		},
+		{
+			name: "undefined type, alias and order",
+			defs: []*RawDefinition{
+				{
+					Type:         "Root",
+					RawTemplates: []RawTemplate{"{{.Undefined}}{{.Other}}"},
+					Aliases:      Aliases{"Other": &Alias{ReferType: "Missing"}},
+					Order:        []DefinitionType{"NoRef"},
+				},
+			},
+			want: []*LintIssue{
+				{Severity: LintError, DefinitionIndex: 0, DefinitionType: "Root", Message: `alias "Other" refers type "Missing" which has no definitions`},
+				{Severity: LintWarning, DefinitionIndex: 0, DefinitionType: "Root", Message: `order entry "NoRef" is not referred from any template`},
+				{Severity: LintError, DefinitionIndex: 0, DefinitionType: "Root", Message: `template "{{.Undefined}}{{.Other}}" refers undefined type "Undefined"`},
+			},
+		},
+		{
+			name: "constraint which is never satisfied and invalid regexp",
+			defs: []*RawDefinition{
+				{Type: "Root", RawTemplates: []RawTemplate{"{{.A}}"}},
+				{Type: "A", RawTemplates: []RawTemplate{"a"}, RawConstraints: RawConstraints{"Never": "x"}},
+				{Type: "A", RawTemplates: []RawTemplate{"b"}, RawConstraints: RawConstraints{"Root/": "["}},
+			},
+			want: []*LintIssue{
+				{Severity: LintWarning, DefinitionIndex: 1, DefinitionType: "A", Message: `constraint "Never" refers type "Never" which is never set`},
+				{Severity: LintError, DefinitionIndex: 2, DefinitionType: "A", Message: "regexp of constraint \"Root/\" can not be compiled: failed to compile RawConstraintValue. invalid regexp([): error parsing regexp: missing closing ]: `[`"},
+			},
+		},
+		{
+			name: "unreachable and recursive definitions",
+			defs: []*RawDefinition{
+				{Type: "Root", RawTemplates: []RawTemplate{"{{.A}}"}},
+				{Type: "A", RawTemplates: []RawTemplate{"a{{.A}}"}},
+				{Type: "B", RawTemplates: []RawTemplate{"b"}},
+			},
+			want: []*LintIssue{
+				{Severity: LintError, DefinitionIndex: 1, DefinitionType: "A", Message: "definition types refer each other recursively: A"},
+				{Severity: LintWarning, DefinitionIndex: 2, DefinitionType: "B", Message: `definition is unreachable from root type "Root"`},
+			},
+		},
+		{
+			name: "assignment which refers undefined type",
+			defs: []*RawDefinition{
+				{Type: "Root", RawTemplates: []RawTemplate{"{{.A}}"}},
+				{Type: "A", RawTemplates: []RawTemplate{"a"}, Sets: RawAssignments{"Label": "{{.A}} {{.Undefined}}"}},
+			},
+			want: []*LintIssue{
+				{Severity: LintError, DefinitionIndex: 1, DefinitionType: "A", Message: `assignment of "Label" refers undefined type "Undefined"`},
+			},
+		},
+		{
+			name: "recursive definitions which have exit",
+			defs: []*RawDefinition{
+				{Type: "Root", RawTemplates: []RawTemplate{"{{.List}}"}},
+				{Type: "List", RawTemplates: []RawTemplate{"{{.Item}}", "{{.Item}}, {{.List}}"}},
+				{Type: "Item", RawTemplates: []RawTemplate{"a"}},
+			},
+			want: []*LintIssue{
+				{Severity: LintWarning, DefinitionIndex: 1, DefinitionType: "List", Message: "definition types refer each other recursively: List"},
+			},
+		},
+		{
+			name: "root type is not defined",
+			defs: []*RawDefinition{},
+			want: []*LintIssue{
+				{Severity: LintError, DefinitionIndex: -1, Message: `root type "Root" has no definitions`},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Lint(tt.defs, "Root", tt.initialState, nil)
+			if len(got) != len(tt.want) {
+				t.Fatalf("Lint() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if *got[i] != *tt.want[i] {
+					t.Errorf("Lint()[%d] = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package internal
+
+// Locale is the locale of a definition like "ja" or "en".
+type Locale string
+
+// localizeDefinitions returns definitions without locale, and definitions of the first locale in locales which has any definition.
+// If locales is empty, all definitions are returned.
+func localizeDefinitions(defs Definitions, locales []Locale) Definitions {
+	if len(locales) == 0 {
+		return defs
+	}
+	pickedLocale, ok := pickLocale(defs, locales)
+	var newDefinitions Definitions
+	for _, def := range defs {
+		if def.Locale == "" || (ok && def.Locale == pickedLocale) {
+			newDefinitions = append(newDefinitions, def)
+		}
+	}
+	return newDefinitions
+}
+
+// pickLocale returns the first locale in locales which any of definitions has.
+func pickLocale(defs Definitions, locales []Locale) (Locale, bool) {
+	for _, locale := range locales {
+		for _, def := range defs {
+			if def.Locale == locale {
+				return locale, true
+			}
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,73 @@
+package internal
+
+import (
+	"context"
+	"testing"
+)
+
+func TestDefinitionRepository_Generate_WithLocales(t *testing.T) {
+	defs := []*RawDefinition{
+		{Type: "Test", RawTemplates: []RawTemplate{"{{.Greeting}}, {{.Name}} #{{.Num}}"}, Locale: "en"},
+		{Type: "Test", RawTemplates: []RawTemplate{"{{.Name}}さん、{{.Greeting}} #{{.Num}}"}, Locale: "ja"},
+		{Type: "Greeting", RawTemplates: []RawTemplate{"Good morning"}, RawConstraints: RawConstraints{"Time": "Morning"}, Locale: "en"},
+		{Type: "Greeting", RawTemplates: []RawTemplate{"Good night"}, RawConstraints: RawConstraints{"Time": "Night"}, Locale: "en"},
+		{Type: "Greeting", RawTemplates: []RawTemplate{"おはよう"}, RawConstraints: RawConstraints{"Time": "Morning"}, Locale: "ja"},
+		{Type: "Name", RawTemplates: []RawTemplate{"Alice"}, Locale: "en"},
+		{Type: "Num", RawTemplates: []RawTemplate{"1"}},
+	}
+	d := NewDefinitionRepository(nil)
+	if err := d.Add(defs...); err != nil {
+		t.Fatalf("unexpected error occurred in DefinitionRepository.Add(): %s", err)
+	}
+
+	tests := []struct {
+		name    string
+		locales []Locale
+		state   MessageMap
+		want    Message
+		wantErr bool
+	}{
+		{name: "definitions of the locale are picked", locales: []Locale{"en"}, state: MessageMap{"Time": "Morning"}, want: "Good morning, Alice #1"},
+		{name: "falls back to the next locale if the type has no definition of the locale", locales: []Locale{"ja", "en"}, state: MessageMap{"Time": "Morning"}, want: "Aliceさん、おはよう #1"},
+		{name: "does not fall back if definitions of the locale are not satisfied", locales: []Locale{"ja", "en"}, state: MessageMap{"Time": "Night"}, wantErr: true},
+		{name: "no definition in the locales", locales: []Locale{"fr"}, state: MessageMap{"Time": "Morning"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := NewState(tt.state)
+			state.SetLocales(tt.locales)
+			got, err := d.Generate("Test", state, 1)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DefinitionRepository.Generate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got[0] != tt.want {
+				t.Errorf("DefinitionRepository.Generate() = %v, want %v", got[0], tt.want)
+			}
+		})
+	}
+
+	countTests := []struct {
+		name    string
+		locales []Locale
+		want    int64
+	}{
+		{name: "definitions of all locales are counted if no locale is given", want: 4},
+		{name: "definitions of the locale are counted", locales: []Locale{"en"}, want: 1},
+	}
+	for _, tt := range countTests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := NewState(MessageMap{"Time": "Morning"})
+			state.SetLocales(tt.locales)
+			count, err := d.Count(context.Background(), "Test", state)
+			if err != nil {
+				t.Fatalf("unexpected error occurred in DefinitionRepository.Count(): %s", err)
+			}
+			if count.Count.Int64() != tt.want {
+				t.Errorf("DefinitionRepository.Count() = %v, want %v", count.Count, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package internal
+
+import (
+	"strings"
+	"text/template/parse"
+
+	"golang.org/x/xerrors"
+)
+
+// NamespaceSeparator separates the namespace and the name of a definition type like "emoji.Good".
+// Templates refer namespaced types like {{.emoji.Good}}.
+const NamespaceSeparator = "."
+
+// RenameTemplateRefs returns the template whose references to definition types are replaced with the types which rename returns.
+// Functions in the template are not checked, so templates which use functions of FuncMap can be renamed.
+// If no reference is replaced, the template is returned as it is.
+func RenameTemplateRefs(rawTemplate RawTemplate, rename func(defType DefinitionType) (DefinitionType, error)) (RawTemplate, error) {
+	tree := parse.New(string(rawTemplate))
+	tree.Mode = parse.SkipFuncCheck
+	if _, err := tree.Parse(string(rawTemplate), "", "", map[string]*parse.Tree{}); err != nil {
+		return "", xerrors.Errorf("failed to parse template %q: %w", rawTemplate, err)
+	}
+
+	renamed := false
+	var renameErr error
+	visitDefRefs(tree.Root, true, func(ident []string) []string {
+		defType := DefinitionType(strings.Join(ident, NamespaceSeparator))
+		newDefType, err := rename(defType)
+		if err != nil {
+			if renameErr == nil {
+				renameErr = err
+			}
+			return ident
+		}
+		if newDefType == defType {
+			return ident
+		}
+		renamed = true
+		return strings.Split(string(newDefType), NamespaceSeparator)
+	})
+	if renameErr != nil {
+		return "", renameErr
+	}
+	if !renamed {
+		return rawTemplate, nil
+	}
+	return RawTemplate(tree.Root.String()), nil
+}
+
+// RenameConstraintKey returns the constraint key whose definition type is replaced with the type which rename returns.
+// Operators and the priority of the key are kept like "emoji.Good+" of "Good+".
+func RenameConstraintKey(rawKey RawConstraintKey, rename func(defType DefinitionType) (DefinitionType, error)) (RawConstraintKey, error) {
+	key, err := rawKey.Parse()
+	if err != nil {
+		return "", err
+	}
+	newDefType, err := rename(key.DefinitionType)
+	if err != nil {
+		return "", err
+	}
+	return RawConstraintKey(string(newDefType) + strings.TrimPrefix(string(rawKey), string(key.DefinitionType))), nil
+}
+
+// RenameConstraintValue returns the constraint value whose reference like "$Hero" is replaced with the type which rename returns.
+// Values which do not refer state values are returned as they are.
+func RenameConstraintValue(rawValue RawConstraintValue, rename func(defType DefinitionType) (DefinitionType, error)) (RawConstraintValue, error) {
+	ref := rawValue.Reference()
+	if ref == "" {
+		return rawValue, nil
+	}
+	newDefType, err := rename(ref)
+	if err != nil {
+		return "", err
+	}
+	return RawConstraintValue("$" + string(newDefType)), nil
+}
@@ -0,0 +1,87 @@
+package internal
+
+import (
+	"testing"
+
+	"golang.org/x/xerrors"
+)
+
+func qualifyForTest(defType DefinitionType) (DefinitionType, error) {
+	switch defType {
+	case "Name", "Mood":
+		return "people." + defType, nil
+	case "Secret":
+		return "", xerrors.New("Secret is not exported")
+	}
+	return defType, nil
+}
+
+func TestRenameTemplateRefs(t *testing.T) {
+	tests := []struct {
+		name     string
+		template RawTemplate
+		want     RawTemplate
+		wantErr  bool
+	}{
+		{name: "plain text is not changed", template: "Hello", want: "Hello"},
+		{name: "template without renamed refs is not changed", template: "{{ .Greeting }}", want: "{{ .Greeting }}"},
+		{name: "refs are renamed", template: "Hi {{.Name}}, {{.emoji.Good}}", want: "Hi {{.people.Name}}, {{.emoji.Good}}"},
+		{name: "refs in pipelines and conditions are renamed", template: `{{if eq .Mood "happy"}}{{upper .Name}}{{end}}`, want: `{{if eq .people.Mood "happy"}}{{upper .people.Name}}{{end}}`},
+		{name: "refs through $ are renamed", template: "{{range .List}}{{$.Name}}{{.Name}}{{end}}", want: "{{range .List}}{{$.people.Name}}{{.Name}}{{end}}"},
+		{name: "functions which are not registered are allowed", template: "{{exclaim .Name}}", want: "{{exclaim .people.Name}}"},
+		{name: "error of rename is returned", template: "{{.Secret}}", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := RenameTemplateRefs(tt.template, qualifyForTest)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RenameTemplateRefs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("RenameTemplateRefs() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenameConstraintKey(t *testing.T) {
+	tests := []struct {
+		key  RawConstraintKey
+		want RawConstraintKey
+	}{
+		{key: "Name", want: "people.Name"},
+		{key: "Name+", want: "people.Name+"},
+		{key: "Mood?/:2", want: "people.Mood?/:2"},
+		{key: "Age>=", want: "Age>="},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.key), func(t *testing.T) {
+			got, err := RenameConstraintKey(tt.key, qualifyForTest)
+			if err != nil {
+				t.Fatalf("unexpected error occurred in RenameConstraintKey(): %s", err)
+			}
+			if got != tt.want {
+				t.Errorf("RenameConstraintKey() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDefinitionRepository_Generate_WithNamespacedTypes(t *testing.T) {
+	defs := []*RawDefinition{
+		{Type: "Test", RawTemplates: []RawTemplate{"{{.people.Name}} {{upper .emoji.Good}}"}},
+		{Type: "people.Name", RawTemplates: []RawTemplate{"Alice"}, RawConstraints: RawConstraints{"emoji.Good+": "ok"}},
+		{Type: "emoji.Good", RawTemplates: []RawTemplate{"good"}},
+	}
+	d := NewDefinitionRepository(nil)
+	if err := d.Add(defs...); err != nil {
+		t.Fatalf("unexpected error occurred in DefinitionRepository.Add(): %s", err)
+	}
+	got, err := d.Generate("Test", nil, 1)
+	if err != nil {
+		t.Fatalf("unexpected error occurred in DefinitionRepository.Generate(): %s", err)
+	}
+	if want := Message("Alice OK"); got[0] != want {
+		t.Errorf("DefinitionRepository.Generate() = %v, want %v", got[0], want)
+	}
+}
@@ -17,7 +17,7 @@ func RandomTemplatePicker(def *DefinitionWithAlias, state *State) (Templates, er
 		if len(templates) == 0 {
 			break
 		}
-		tmpl, ok := templates.PopRandom()
+		tmpl, ok := templates.PopRandomWithWeight(state.Rand())
 		if !ok {
 			return nil, xerrors.Errorf("failed to pop template random from %v", templates)
 		}
@@ -26,6 +26,19 @@ func RandomTemplatePicker(def *DefinitionWithAlias, state *State) (Templates, er
 	return newTemplates, nil
 }
 
+// ConstraintsSatisfiedTemplatePicker picks templates whose constraints are satisfied by the state.
+func ConstraintsSatisfiedTemplatePicker(def *DefinitionWithAlias, state *State) (Templates, error) {
+	var newTemplates Templates
+	for _, template := range def.Templates {
+		if ok, err := template.CanBePicked(state); err != nil {
+			return nil, err
+		} else if ok {
+			newTemplates = append(newTemplates, template)
+		}
+	}
+	return newTemplates, nil
+}
+
 func NotAllowAliasDuplicateTemplatePicker(def *DefinitionWithAlias, state *State) (Templates, error) {
 	if def.alias != nil && def.alias.AllowDuplicate {
 		return def.Templates, nil
@@ -47,7 +60,7 @@ func RandomWithWeightDefinitionPicker(definitions *Definitions, state *State) ([
 		for _, def := range *definitions {
 			weights = append(weights, def.Weight)
 		}
-		def := definitions.PopByIndex(pickDefinitionIndexRandomWithWeight(weights))
+		def := definitions.PopByIndex(pickDefinitionIndexRandomWithWeight(state.Rand(), weights))
 		newDefinitions = append(newDefinitions, def)
 	}
 	return newDefinitions, nil
@@ -67,22 +80,22 @@ func ConstraintsSatisfiedDefinitionPicker(definitions *Definitions, state *State
 
 func SortByConstraintPriorityDefinitionPicker(definitions *Definitions, state *State) ([]*Definition, error) {
 	sort.SliceStable(*definitions, func(i, j int) bool {
-		return (*definitions)[i].Constraints.GetPriority(state) > (*definitions)[j].Constraints.GetPriority(state)
+		return (*definitions)[i].GetPriority(state) > (*definitions)[j].GetPriority(state)
 	})
 	return *definitions, nil
 }
 
-func pickDefinitionIndexRandomWithWeight(weights []DefinitionWeight) int {
+func pickDefinitionIndexRandomWithWeight(r *rand.Rand, weights []DefinitionWeight) int {
 	if len(weights) == 1 {
 		return 0
 	}
 
 	weightSum := calcWeightSum(weights)
-	r := randomFloat32(0, float64(weightSum))
+	v := randomFloat32(r, 0, float64(weightSum))
 	currentWeightSum := float32(0)
 	for i, weight := range weights { // O(N)
 		currentWeightSum += float32(weight)
-		if r < currentWeightSum {
+		if v < currentWeightSum {
 			return i
 		}
 	}
@@ -96,6 +109,38 @@ func calcWeightSum(weights []DefinitionWeight) (sum float32) {
 	return
 }
 
-func randomFloat32(min, max float64) float32 {
-	return float32(rand.Float64()*(max-min) + min)
+func randomFloat32(r *rand.Rand, min, max float64) float32 {
+	return float32(r.Float64()*(max-min) + min)
+}
+
+// generatedValuePicker picks indexes of generated values at random without replacement.
+// It shuffles the indexes lazily, so picking a value takes constant time regardless of the number of values.
+type generatedValuePicker struct {
+	remaining int
+	// swapped has indexes which are moved by picks. Index i which is not in swapped is at position i.
+	swapped map[int]int
+}
+
+func newGeneratedValuePicker(n int) *generatedValuePicker {
+	return &generatedValuePicker{remaining: n, swapped: map[int]int{}}
+}
+
+// pop returns an index which is not picked yet, or false if all indexes are picked.
+func (p *generatedValuePicker) pop(r *rand.Rand) (int, bool) {
+	if p.remaining == 0 {
+		return 0, false
+	}
+	i := r.Intn(p.remaining)
+	p.remaining--
+	picked := p.indexAt(i)
+	p.swapped[i] = p.indexAt(p.remaining)
+	delete(p.swapped, p.remaining)
+	return picked, true
+}
+
+func (p *generatedValuePicker) indexAt(i int) int {
+	if index, ok := p.swapped[i]; ok {
+		return index
+	}
+	return i
 }
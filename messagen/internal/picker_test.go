@@ -139,3 +139,66 @@ func TestSortByConstraintPriorityDefinitionPicker(t *testing.T) {
 		})
 	}
 }
+
+func TestRandomTemplatePicker_WithWeight(t *testing.T) {
+	def := newDefinitionWithAliasOrPanic(&RawDefinition{
+		Type:               "Test",
+		RawTemplates:       []RawTemplate{"rare", "common"},
+		TemplateAttributes: []*RawTemplateAttributes{{Weight: 0.1}, {Weight: 0.9}},
+	}, "", nil)
+	state := NewState(nil)
+	state.SetRand(rand.New(rand.NewSource(1)))
+
+	commonCnt := 0
+	for i := 0; i < 1000; i++ {
+		got, err := RandomTemplatePicker(def, state)
+		if err != nil {
+			t.Fatalf("unexpected error occurred in RandomTemplatePicker(): %s", err)
+		}
+		if len(got) != 2 {
+			t.Fatalf("RandomTemplatePicker() should return all templates: %v", got)
+		}
+		if got[0].Raw == "common" {
+			commonCnt++
+		}
+	}
+	if commonCnt < 850 || commonCnt > 950 {
+		t.Errorf("template whose weight is 0.9 should be picked first about 900 times in 1000, but %d", commonCnt)
+	}
+}
+
+func TestConstraintsSatisfiedTemplatePicker(t *testing.T) {
+	def := newDefinitionWithAliasOrPanic(&RawDefinition{
+		Type:         "Test",
+		RawTemplates: []RawTemplate{"a", "b", "c"},
+		TemplateAttributes: []*RawTemplateAttributes{
+			{Constraints: RawConstraints{"K": "V1"}},
+			nil,
+			{ValueSets: RawValueSetConstraints{"K": {"V2", "V3"}}},
+		},
+	}, "", nil)
+	tests := []struct {
+		name  string
+		state MessageMap
+		want  []RawTemplate
+	}{
+		{name: "templates without constraints are always picked", state: MessageMap{}, want: []RawTemplate{"b"}},
+		{name: "templates whose constraints are satisfied are picked", state: MessageMap{"K": "V1"}, want: []RawTemplate{"a", "b"}},
+		{name: "value set of template is satisfied by any of the values", state: MessageMap{"K": "V3"}, want: []RawTemplate{"b", "c"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ConstraintsSatisfiedTemplatePicker(def, NewState(tt.state))
+			if err != nil {
+				t.Fatalf("unexpected error occurred in ConstraintsSatisfiedTemplatePicker(): %s", err)
+			}
+			var raws []RawTemplate
+			for _, template := range got {
+				raws = append(raws, template.Raw)
+			}
+			if !reflect.DeepEqual(raws, tt.want) {
+				t.Errorf("ConstraintsSatisfiedTemplatePicker() = %v, want %v", raws, tt.want)
+			}
+		})
+	}
+}
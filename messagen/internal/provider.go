@@ -0,0 +1,103 @@
+package internal
+
+import (
+	"context"
+
+	"golang.org/x/xerrors"
+)
+
+// ProvidedValue is a candidate value of a definition type which DefinitionProvider provides.
+// It is treated as a definition which has only one template.
+type ProvidedValue struct {
+	Value Message
+	// Weight is the weight like Weight of definitions. If it is 0, 1 is used.
+	Weight DefinitionWeight
+	// Constraints are the constraints like Constraints of definitions.
+	// Constraints with + like {"Gender+": "Female"} set values to the state when the value is picked.
+	Constraints RawConstraints
+}
+
+// DefinitionProvider provides candidate values of a definition type at generation time.
+// Provide is called with the current state each time the definition type is resolved,
+// and the values participate in backtracking like ordinary definitions.
+type DefinitionProvider interface {
+	Provide(ctx context.Context, state *State) ([]*ProvidedValue, error)
+}
+
+// DefinitionProviderFunc is an adapter to use a function as DefinitionProvider.
+type DefinitionProviderFunc func(ctx context.Context, state *State) ([]*ProvidedValue, error)
+
+func (f DefinitionProviderFunc) Provide(ctx context.Context, state *State) ([]*ProvidedValue, error) {
+	return f(ctx, state)
+}
+
+// registeredProvider is a provider which is registered to a repository.
+// Provided definitions have the negative ID of the provider, so that they are distinguished from added definitions
+// and templates picked from the provider are shared between calls.
+type registeredProvider struct {
+	provider DefinitionProvider
+	id       DefinitionID
+}
+
+// AddProvider registers the provider of the definition type.
+// Values of the provider are candidates in addition to the added definitions of the type.
+func (d *DefinitionRepository) AddProvider(defType DefinitionType, provider DefinitionProvider) error {
+	if _, ok := d.providers[defType]; ok {
+		return xerrors.Errorf("definition provider of %s is already registered", defType)
+	}
+	d.providers[defType] = &registeredProvider{provider: provider, id: DefinitionID(-len(d.providers) - 1)}
+	return nil
+}
+
+func (d *DefinitionRepository) hasProvider(defType DefinitionType) bool {
+	_, ok := d.providers[defType]
+	return ok
+}
+
+// provide returns definitions of the values which the provider of the definition type provides.
+func (d *DefinitionRepository) provide(ctx context.Context, defType DefinitionType, state *State) (Definitions, error) {
+	p, ok := d.providers[defType]
+	if !ok {
+		return nil, nil
+	}
+	values, err := p.provider.Provide(ctx, state)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to provide values of %s: %w", defType, err)
+	}
+
+	var defs Definitions
+	for _, value := range values {
+		template, err := newValueTemplate(value.Value, d.funcs)
+		if err != nil {
+			return nil, xerrors.Errorf("failed to create template of provided value %q: %w", value.Value, err)
+		}
+		constraints, err := NewConstraints(value.Constraints)
+		if err != nil {
+			return nil, xerrors.Errorf("failed to create constraints of provided value %q: %w", value.Value, err)
+		}
+		weight := value.Weight
+		if weight == 0 {
+			weight = 1
+		}
+		defs = append(defs, &Definition{
+			RawDefinition: &RawDefinition{
+				Type:           defType,
+				RawTemplates:   []RawTemplate{template.Raw},
+				RawConstraints: value.Constraints,
+				Weight:         weight,
+			},
+			Constraints: constraints,
+			ID:          p.id,
+			Templates:   Templates{template},
+		})
+	}
+	return defs, nil
+}
+
+// providedTypes returns the definition types which have providers.
+func (d *DefinitionRepository) providedTypes() (types []DefinitionType) {
+	for defType := range d.providers {
+		types = append(types, defType)
+	}
+	return
+}
@@ -0,0 +1,75 @@
+package internal
+
+import (
+	"context"
+	"testing"
+
+	"golang.org/x/xerrors"
+)
+
+func TestDefinitionRepository_Generate_WithProvider(t *testing.T) {
+	defs := []*RawDefinition{
+		{
+			Type:         "Test",
+			RawTemplates: []RawTemplate{"{{.Campaign}}:{{.Check}}"},
+		},
+		{
+			Type:           "Check",
+			RawTemplates:   []RawTemplate{"ok"},
+			RawConstraints: RawConstraints{"Active": "true"},
+		},
+	}
+	var calledStates []MessageMap
+	provider := DefinitionProviderFunc(func(ctx context.Context, state *State) ([]*ProvidedValue, error) {
+		calledStates = append(calledStates, state.Map())
+		return []*ProvidedValue{
+			{Value: "closed", Constraints: RawConstraints{"Active+": "false"}},
+			{Value: "{{summer}}", Constraints: RawConstraints{"Active+": "true"}},
+		}, nil
+	})
+
+	d := NewDefinitionRepository(nil)
+	if err := d.Add(defs...); err != nil {
+		t.Fatalf("unexpected error occurred in DefinitionRepository.Add(): %s", err)
+	}
+	if err := d.AddProvider("Campaign", provider); err != nil {
+		t.Fatalf("unexpected error occurred in DefinitionRepository.AddProvider(): %s", err)
+	}
+	if err := d.AddProvider("Campaign", provider); err == nil {
+		t.Errorf("DefinitionRepository.AddProvider() should return error if provider of the type is already registered")
+	}
+
+	// the value which sets Active to false is rejected by backtracking
+	for i := 0; i < 10; i++ {
+		got, err := d.Generate("Test", NewState(MessageMap{"User": "bob"}), 1)
+		if err != nil {
+			t.Fatalf("unexpected error occurred in DefinitionRepository.Generate(): %s", err)
+		}
+		if want := Message("{{summer}}:ok"); got[0] != want {
+			t.Errorf("DefinitionRepository.Generate() = %v, want %v", got[0], want)
+		}
+	}
+	if len(calledStates) == 0 || calledStates[0]["User"] != "bob" {
+		t.Errorf("provider should be called with the current state: %v", calledStates)
+	}
+
+	if issues := d.Lint("Test", nil); issues.HasError() {
+		t.Errorf("DefinitionRepository.Lint() should regard provided types as defined: %v", issues)
+	}
+}
+
+func TestDefinitionRepository_Generate_WithProviderError(t *testing.T) {
+	providerErr := xerrors.New("provider error")
+	d := NewDefinitionRepository(nil)
+	if err := d.Add(&RawDefinition{Type: "Test", RawTemplates: []RawTemplate{"{{.Campaign}}"}}); err != nil {
+		t.Fatalf("unexpected error occurred in DefinitionRepository.Add(): %s", err)
+	}
+	if err := d.AddProvider("Campaign", DefinitionProviderFunc(func(ctx context.Context, state *State) ([]*ProvidedValue, error) {
+		return nil, providerErr
+	})); err != nil {
+		t.Fatalf("unexpected error occurred in DefinitionRepository.AddProvider(): %s", err)
+	}
+	if _, err := d.Generate("Test", nil, 1); !xerrors.Is(err, providerErr) {
+		t.Errorf("DefinitionRepository.Generate() error = %v, want %v", err, providerErr)
+	}
+}
@@ -0,0 +1,50 @@
+package internal
+
+import (
+	"math/rand"
+	"sync"
+)
+
+// globalSource is a rand.Source which delegates to the global source of math/rand.
+type globalSource struct{}
+
+func (globalSource) Int63() int64 {
+	return rand.Int63()
+}
+
+func (globalSource) Uint64() uint64 {
+	return rand.Uint64()
+}
+
+// Seed does nothing. The global source should be seeded via math/rand.
+func (globalSource) Seed(int64) {}
+
+// globalRand is used by pickers if State does not have own random source.
+var globalRand = rand.New(globalSource{})
+
+// lockedSource is a rand.Source which can be used from multiple goroutines.
+type lockedSource struct {
+	mu  sync.Mutex
+	src rand.Source
+}
+
+func (l *lockedSource) Int63() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.src.Int63()
+}
+
+func (l *lockedSource) Seed(seed int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.src.Seed(seed)
+}
+
+// NewRand returns *rand.Rand which is safe for concurrent use from the given source.
+// If src is nil, returned *rand.Rand uses the global source of math/rand.
+func NewRand(src rand.Source) *rand.Rand {
+	if src == nil {
+		return globalRand
+	}
+	return rand.New(&lockedSource{src: src})
+}
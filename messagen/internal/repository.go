@@ -1,7 +1,10 @@
 package internal
 
 import (
+	"context"
 	"fmt"
+	"math/rand"
+	"text/template"
 
 	"golang.org/x/xerrors"
 )
@@ -18,17 +21,45 @@ type DefinitionRepository struct {
 	templatePickers    []TemplatePicker
 	definitionPickers  []DefinitionPicker
 	templateValidators []TemplateValidator
+	rootTransformers   []MessageTransformer
+	typeTransformers   map[DefinitionType][]MessageTransformer
+	rand               *rand.Rand
+	limits             Limits
+	funcs              *FuncSet
+	providers          map[DefinitionType]*registeredProvider
 	maxID              DefinitionID
+	// pickGeneratedValuesAtRandom is true if values of generators are picked at random without building all templates.
+	pickGeneratedValuesAtRandom bool
 }
 
 type DefinitionRepositoryOption struct {
 	TemplatePickers    []TemplatePicker
 	DefinitionPickers  []DefinitionPicker
 	TemplateValidators []TemplateValidator
+
+	// RandSource is used by built-in pickers. If it is nil, the global source of math/rand is used.
+	RandSource rand.Source
+
+	// Limits restricts each search of Generate and Enumerate.
+	Limits Limits
+
+	// FuncMap is functions which are available in templates in addition to built-in functions.
+	FuncMap template.FuncMap
+
+	// RootTransformers are applied to messages of the root definition type of each search.
+	// TypeTransformers are applied to messages of each definition type before root transformers.
+	// Transformed messages are set to the state, and template validators see them.
+	RootTransformers []MessageTransformer
+	TypeTransformers map[DefinitionType][]MessageTransformer
+
+	// PickGeneratedValuesAtRandom picks values of generators at random one by one instead of applying TemplatePickers to all of them,
+	// so that a definition with many generated values is resolved quickly.
+	// It should be true only if TemplatePickers pick templates at random. Enumerate and Count always list all values.
+	PickGeneratedValuesAtRandom bool
 }
 
 func NewDefinitionRepository(opt *DefinitionRepositoryOption) *DefinitionRepository {
-	templatePickers := []TemplatePicker{NotAllowAliasDuplicateTemplatePicker}
+	templatePickers := []TemplatePicker{ConstraintsSatisfiedTemplatePicker, NotInHistoryTemplatePicker, NotAllowAliasDuplicateTemplatePicker}
 	if opt != nil && opt.TemplatePickers != nil {
 		templatePickers = append(templatePickers, opt.TemplatePickers...)
 	}
@@ -45,12 +76,35 @@ func NewDefinitionRepository(opt *DefinitionRepositoryOption) *DefinitionReposit
 		templateValidators = opt.TemplateValidators
 	}
 
+	var randSource rand.Source
+	var limits Limits
+	var funcMap template.FuncMap
+	var rootTransformers []MessageTransformer
+	var typeTransformers map[DefinitionType][]MessageTransformer
+	pickGeneratedValuesAtRandom := false
+	if opt != nil {
+		randSource = opt.RandSource
+		limits = opt.Limits
+		funcMap = opt.FuncMap
+		rootTransformers = opt.RootTransformers
+		typeTransformers = opt.TypeTransformers
+		pickGeneratedValuesAtRandom = opt.PickGeneratedValuesAtRandom
+	}
+
 	return &DefinitionRepository{
 		m:                  definitionMap{},
 		templatePickers:    templatePickers,
 		definitionPickers:  definitionPickers,
 		templateValidators: templateValidators,
+		rootTransformers:   rootTransformers,
+		typeTransformers:   typeTransformers,
+		rand:               NewRand(randSource),
+		limits:             limits,
+		funcs:              NewFuncSet(funcMap),
+		providers:          map[DefinitionType]*registeredProvider{},
 		maxID:              0,
+
+		pickGeneratedValuesAtRandom: pickGeneratedValuesAtRandom,
 	}
 }
 
@@ -64,7 +118,7 @@ func (d *DefinitionRepository) List(defType DefinitionType) (defs Definitions) {
 
 func (d *DefinitionRepository) Add(rawDefs ...*RawDefinition) error {
 	for _, rawDefinition := range rawDefs {
-		def, err := NewDefinition(rawDefinition)
+		def, err := NewDefinitionWithFuncs(rawDefinition, d.funcs)
 		if err != nil {
 			return xerrors.Errorf("failed to add definition to repository: %w", err)
 		}
@@ -84,82 +138,135 @@ func (d *DefinitionRepository) addDefinition(def *Definition) {
 	return
 }
 
+// isResolvable reports whether the definition type has definitions or a provider, or is an alias name of any definition.
+func (d *DefinitionRepository) isResolvable(defType DefinitionType) bool {
+	if _, ok := d.m[defType]; ok || d.hasProvider(defType) {
+		return true
+	}
+	for _, defs := range d.m {
+		for _, def := range defs {
+			if def.IsAlias(defType) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Lint statically analyzes the definitions in the repository. See Lint for details.
+func (d *DefinitionRepository) Lint(root DefinitionType, initialState *State) LintIssues {
+	rawDefs := make([]*RawDefinition, d.maxID)
+	for _, defs := range d.m {
+		for _, def := range defs {
+			rawDefs[def.ID] = def.RawDefinition
+		}
+	}
+	return Lint(rawDefs, root, initialState, d.funcs, d.providedTypes()...)
+}
+
 func (d *DefinitionRepository) Generate(defType DefinitionType, initialState *State, num uint) (messages []Message, err error) {
-	msgChan, errChan := d.Start(defType, initialState)
+	return d.GenerateContext(context.Background(), defType, initialState, num)
+}
 
+// GenerateContext generates messages like Generate, but stops the search when ctx is done.
+func (d *DefinitionRepository) GenerateContext(ctx context.Context, defType DefinitionType, initialState *State, num uint) (messages []Message, err error) {
+	detailedMessages, err := d.GenerateDetailed(ctx, defType, initialState, num)
+	if err != nil {
+		return nil, err
+	}
+	for _, detailedMessage := range detailedMessages {
+		messages = append(messages, detailedMessage.Message)
+	}
+	return messages, nil
+}
+
+// GenerateDetailed generates messages like Generate, but returns them with their final states and derivations.
+func (d *DefinitionRepository) GenerateDetailed(ctx context.Context, defType DefinitionType, initialState *State, num uint) (messages []*DetailedMessage, err error) {
 	if num == 0 {
 		return nil, fmt.Errorf("failed to generate messages. num must be greater than 1")
 	}
 
-	for {
-		select {
-		case msg, ok := <-msgChan:
-			if !ok {
-				if len(messages) == 0 {
-					return nil, xerrors.Errorf("valid message does not exist")
-				} else {
-					return messages, nil
-				}
-			}
-			messages = append(messages, msg)
-			if len(messages) == int(num) {
-				return messages, nil
-			}
-		case err := <-errChan:
-			return nil, err
+	r := newResolver(ctx, d)
+	it, err := r.newMessageIterator(defType, initialState)
+	if err != nil {
+		return nil, err
+	}
+	for len(messages) < int(num) {
+		msg, ok, err := it.nextDetailed()
+		if err != nil {
+			return nil, xerrors.Errorf("failed to generate messages: %w", err)
+		}
+		if !ok {
+			break
 		}
+		if initialState != nil && initialState.history != nil && initialState.history.HasMessage(msg.Message) {
+			r.rejections.record(&Rejection{Reason: InHistory, Path: []DefinitionType{defType}, DefinitionType: defType, Message: msg.Message})
+			continue
+		}
+		messages = append(messages, msg)
+	}
+	if len(messages) == 0 {
+		return nil, r.rejections.newNoMessageError(defType)
 	}
+	return messages, nil
+}
+
+// Enumerate returns an iterator which yields every distinct message of the definition type in deterministic order.
+// Random pickers and user-defined pickers are not applied while enumeration,
+// so definitions are picked in order of constraint priority and templates are picked in ascending order.
+// Template validators are applied as well as Generate.
+func (d *DefinitionRepository) Enumerate(ctx context.Context, defType DefinitionType, initialState *State) (*MessageIterator, error) {
+	it, err := newResolver(ctx, d.deterministic()).newMessageIterator(defType, initialState)
+	if err != nil {
+		return nil, err
+	}
+	return &MessageIterator{it: it, seen: map[Message]struct{}{}}, nil
+}
+
+// deterministic returns a repository which shares definitions, but uses only built-in pickers which do not depend on randomness.
+func (d *DefinitionRepository) deterministic() *DefinitionRepository {
+	repo := *d
+	repo.templatePickers = []TemplatePicker{ConstraintsSatisfiedTemplatePicker, NotAllowAliasDuplicateTemplatePicker}
+	repo.definitionPickers = []DefinitionPicker{ConstraintsSatisfiedDefinitionPicker, SortByConstraintPriorityDefinitionPicker}
+	repo.pickGeneratedValuesAtRandom = false
+	return &repo
 }
 
 func (d *DefinitionRepository) Start(defType DefinitionType, initialState *State) (msgChan chan Message, errChan chan error) {
-	stateChan := make(chan *State)
+	return d.StartContext(context.Background(), defType, initialState)
+}
+
+// StartContext starts message generation and returns channels which receive generated messages and an error.
+// msgChan is closed when all messages are generated or ctx is done.
+// The caller should cancel ctx when it stops reading from msgChan, otherwise the goroutine for the search is leaked.
+func (d *DefinitionRepository) StartContext(ctx context.Context, defType DefinitionType, initialState *State) (msgChan chan Message, errChan chan error) {
 	msgChan = make(chan Message)
-	errChan = make(chan error)
-	if initialState == nil {
-		initialState = NewState(nil)
-	}
-	defs, err := d.pickDefinitions(defType, initialState)
+	errChan = make(chan error, 1)
+	it, err := newResolver(ctx, d).newMessageIterator(defType, initialState)
 	if err != nil {
-		errChan <- xerrors.Errorf("failed to generate message: %w", err)
+		errChan <- err
 		close(msgChan)
 		return
 	}
 
 	go func() {
-		for _, def := range defs {
-			defWithAlias := &DefinitionWithAlias{
-				Definition: def,
-				aliasName:  "",
-				alias:      nil,
-			}
-			subStateChan, templateErrChan := resolveTemplates(defWithAlias, initialState, d)
-			if err := pipeStateChan(subStateChan, stateChan, templateErrChan); err != nil {
-				errChan <- err
-			}
-		}
-		close(stateChan)
-	}()
-
-	go func() {
+		defer close(msgChan)
 		for {
-			select {
-			case state, ok := <-stateChan:
-				if ok {
-					msg, ok := state.Get(defType)
-					if !ok {
-						errChan <- fmt.Errorf("error occurred in Generate. message not found. def type: %s", defType)
-					}
-					msgChan <- msg
-				} else {
-					close(msgChan)
-					return
-				}
-			case err, ok := <-errChan:
-				if ok {
+			msg, ok, err := it.next()
+			if err != nil {
+				if ctx.Err() == nil {
 					errChan <- err
 				}
 				return
 			}
+			if !ok {
+				return
+			}
+			select {
+			case msgChan <- msg:
+			case <-ctx.Done():
+				return
+			}
 		}
 	}()
 	return msgChan, errChan
@@ -167,7 +274,11 @@ func (d *DefinitionRepository) Start(defType DefinitionType, initialState *State
 
 func (d *DefinitionRepository) applyTemplatePickers(def *DefinitionWithAlias, state *State) (newTemplates Templates, err error) {
 	newDef := *def
-	newTemplates, err = def.Templates.Copy(newDef.Order)
+	templates, err := def.allTemplates()
+	if err != nil {
+		return nil, err
+	}
+	newTemplates, err = templates.Copy(newDef.Order)
 	if err != nil {
 		return nil, err
 	}
@@ -186,8 +297,17 @@ func (d *DefinitionRepository) applyTemplatePickers(def *DefinitionWithAlias, st
 	return newTemplates, nil
 }
 
-func (d *DefinitionRepository) pickDefinitions(defType DefinitionType, state *State) (Definitions, error) {
-	return d.applyDefinitionPickers(d.List(defType), state)
+// pickDefinitions picks definitions of the type in the locales of the state from the added definitions and values of the provider.
+func (d *DefinitionRepository) pickDefinitions(ctx context.Context, defType DefinitionType, state *State) (Definitions, error) {
+	defs := localizeDefinitions(d.List(defType), state.locales)
+	if d.hasProvider(defType) {
+		provided, err := d.provide(ctx, defType, state)
+		if err != nil {
+			return nil, err
+		}
+		defs = append(append(Definitions{}, defs...), provided...)
+	}
+	return d.applyDefinitionPickers(defs, state)
 }
 
 func (d *DefinitionRepository) applyDefinitionPickers(defs Definitions, state *State) (Definitions, error) {
@@ -211,151 +331,3 @@ func (d *DefinitionRepository) applyTemplateValidators(template *Template, state
 	}
 	return true, nil
 }
-
-func pipeStateChan(fromStateChan, toStateChan chan *State, errChan chan error) error {
-	for {
-		select {
-		case newState, ok := <-fromStateChan:
-			if ok {
-				toStateChan <- newState
-			} else {
-				return nil
-			}
-		case err, ok := <-errChan:
-			if !ok {
-				return fmt.Errorf("err chan closed")
-			}
-			return err
-		}
-	}
-}
-
-func resolveTemplates(def *DefinitionWithAlias, state *State, repo *DefinitionRepository) (chan *State, chan error) {
-	stateChan := make(chan *State)
-	errChan := make(chan error)
-	templates, err := repo.applyTemplatePickers(def, state)
-	if err != nil {
-		errChan <- err
-		return stateChan, errChan
-	}
-
-	go func() {
-		for _, defTemplate := range templates {
-			defTemplate := defTemplate
-			newState := state.Copy(def.Order)
-			if len(*defTemplate.Depends) == 0 {
-				if err := newState.Update(def, defTemplate, Message(defTemplate.Raw)); err != nil {
-					errChan <- err
-					return
-				}
-				if ok, err := repo.applyTemplateValidators(defTemplate, newState); err != nil {
-					errChan <- err
-					return
-				} else if ok {
-					stateChan <- newState
-				}
-				continue
-			}
-			subStateChan, errChan2 := resolveDefDepends(defTemplate, newState, repo, def.Aliases)
-		L:
-			for {
-				select {
-				case satisfiedState, ok := <-subStateChan:
-					if !ok {
-						break L
-					}
-					msg, err := defTemplate.Execute(satisfiedState)
-					if err != nil {
-						errChan <- err
-						return
-					}
-
-					newSatisfiedState := satisfiedState.Copy(def.Order)
-					if err := newSatisfiedState.Update(def, defTemplate, msg); err != nil {
-						errChan <- err
-						return
-					}
-					if ok, err := repo.applyTemplateValidators(defTemplate, newSatisfiedState); err != nil {
-						errChan <- err
-						return
-					} else if ok {
-						stateChan <- newSatisfiedState
-					}
-				case err := <-errChan2:
-					errChan <- err
-					return
-				}
-			}
-		}
-		close(stateChan)
-	}()
-	return stateChan, errChan
-}
-
-func resolveDefDepends(template *Template, state *State, repo *DefinitionRepository, aliases Aliases) (chan *State, chan error) {
-	errChan := make(chan error)
-	stateChan := make(chan *State)
-	if template.IsSatisfiedState(state) {
-		go func() {
-			stateChan <- state
-			close(stateChan)
-		}()
-		return stateChan, errChan
-	}
-
-	defType, _ := template.GetFirstUnsatisfiedDef(state)
-	alias, ok := aliases[AliasName(defType)]
-	var aliasName AliasName
-	if ok {
-		aliasName = AliasName(defType)
-		defType = alias.ReferType
-	}
-	pickDefStateChan, _ := pickDef(defType, aliasName, alias, state, repo) // FIXME: handle error
-
-	go func() {
-		for newState := range pickDefStateChan {
-			if ok, err := repo.applyTemplateValidators(template, newState); err != nil {
-				errChan <- err
-				return
-			} else if !ok {
-				continue
-			}
-
-			satisfiedStateChan, errChan2 := resolveDefDepends(template, newState, repo, aliases)
-			if err := pipeStateChan(satisfiedStateChan, stateChan, errChan2); err != nil {
-				errChan <- err
-				return
-			}
-		}
-		close(stateChan)
-	}()
-
-	return stateChan, errChan
-}
-
-func pickDef(defType DefinitionType, aliasName AliasName, alias *Alias, state *State, repo *DefinitionRepository) (chan *State, chan error) {
-	stateChan := make(chan *State)
-	errChan := make(chan error)
-	candidateDefs, err := repo.pickDefinitions(defType, state)
-	if err != nil {
-		errChan <- xerrors.Errorf("failed to pick definitions", err)
-		return stateChan, errChan
-	}
-
-	go func() {
-		for _, candidateDef := range candidateDefs {
-			candidateDef := candidateDef
-			candidateDefWithAlias := &DefinitionWithAlias{
-				Definition: candidateDef,
-				aliasName:  aliasName,
-				alias:      alias,
-			}
-			subStateChan, templateErrChan := resolveTemplates(candidateDefWithAlias, state, repo)
-			if err := pipeStateChan(subStateChan, stateChan, templateErrChan); err != nil {
-				errChan <- err
-			}
-		}
-		close(stateChan)
-	}()
-	return stateChan, errChan
-}
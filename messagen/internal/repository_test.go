@@ -1,8 +1,14 @@
 package internal
 
 import (
+	"context"
+	"math/rand"
 	"reflect"
+	"runtime"
 	"testing"
+	"time"
+
+	"golang.org/x/xerrors"
 )
 
 func TestDefinitionRepository_Generate(t *testing.T) {
@@ -163,6 +169,56 @@ func TestDefinitionRepository_Generate(t *testing.T) {
 			want:    "aaadddccc",
 			wantErr: false,
 		},
+		{
+			name: "template attributes set values to state",
+			defs: []*RawDefinition{
+				{
+					Type:         "Test",
+					RawTemplates: []RawTemplate{"{{.Name}}{{.Suffix}}"},
+				}, {
+					Type:         "Name",
+					RawTemplates: []RawTemplate{"aaa", "bbb"},
+					TemplateAttributes: []*RawTemplateAttributes{
+						{Constraints: RawConstraints{"Mode": "A", "Size+": "S"}},
+						{Constraints: RawConstraints{"Mode": "B"}, Sets: RawAssignments{"Size": "L"}},
+					},
+				}, {
+					Type:           "Suffix",
+					RawTemplates:   []RawTemplate{"-small"},
+					RawConstraints: RawConstraints{"Size": "S"},
+				}, {
+					Type:           "Suffix",
+					RawTemplates:   []RawTemplate{"-large"},
+					RawConstraints: RawConstraints{"Size": "L"},
+				},
+			},
+			args: args{
+				defType:      "Test",
+				initialState: NewState(MessageMap{"Mode": "B"}),
+			},
+			want:    "bbb-large",
+			wantErr: false,
+		},
+		{
+			name: "templates with attributes are not duplicated by alias",
+			defs: []*RawDefinition{
+				{
+					Type:         "Test",
+					RawTemplates: []RawTemplate{"{{.A}}{{.AnotherA}}"},
+					Aliases:      Aliases{"AnotherA": &Alias{ReferType: "A"}},
+					Order:        []DefinitionType{"AnotherA", "A"},
+				}, {
+					Type:               "A",
+					RawTemplates:       []RawTemplate{"aaa", "bbb"},
+					TemplateAttributes: []*RawTemplateAttributes{{Weight: 100}},
+				},
+			},
+			args: args{
+				defType: "Test",
+			},
+			want:    "bbbaaa",
+			wantErr: false,
+		},
 		{
 			name: "use ? operator",
 			defs: []*RawDefinition{
@@ -235,6 +291,38 @@ func TestDefinitionRepository_Generate(t *testing.T) {
 			want:    "aaabbbccc",
 			wantErr: false,
 		},
+		{
+			name: "types in not taken branch are not resolved",
+			defs: []*RawDefinition{
+				{
+					Type:         "Test",
+					RawTemplates: []RawTemplate{`{{if eq .Mode "formal"}}{{.Undefined}}{{else}}hi {{.Name}}{{end}}`},
+				}, {
+					Type:         "Name",
+					RawTemplates: []RawTemplate{"bob"},
+				},
+			},
+			args: args{
+				defType:      "Test",
+				initialState: NewState(MessageMap{"Mode": "casual"}),
+			},
+			want:    "hi bob",
+			wantErr: false,
+		},
+		{
+			name: "template which has only actions without types is executed",
+			defs: []*RawDefinition{
+				{
+					Type:         "Test",
+					RawTemplates: []RawTemplate{`{{"aaa" | upper}}`},
+				},
+			},
+			args: args{
+				defType: "Test",
+			},
+			want:    "AAA",
+			wantErr: false,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -339,6 +427,482 @@ func TestDefinitionRepository_Generate_WithValidator(t *testing.T) {
 	}
 }
 
+func TestDefinitionRepository_GenerateContext(t *testing.T) {
+	defs := []*RawDefinition{
+		{
+			Type:         "Test",
+			RawTemplates: []RawTemplate{"{{.NestTest}}{{.NestTest2}}"},
+		},
+		{
+			Type:         "NestTest",
+			RawTemplates: []RawTemplate{"a", "b", "c"},
+		},
+		{
+			Type:         "NestTest2",
+			RawTemplates: []RawTemplate{"x", "y", "z"},
+		},
+	}
+
+	t.Run("goroutines should be stopped after generation", func(t *testing.T) {
+		d := NewDefinitionRepository(nil)
+		if err := d.Add(defs...); err != nil {
+			t.Fatalf("unexpected error occurred in DefinitionRepository.Add(): %s", err)
+		}
+
+		before := runtime.NumGoroutine()
+		for i := 0; i < 10; i++ {
+			if _, err := d.GenerateContext(context.Background(), "Test", nil, 1); err != nil {
+				t.Fatalf("unexpected error occurred in DefinitionRepository.GenerateContext(): %s", err)
+			}
+		}
+
+		deadline := time.Now().Add(time.Second)
+		for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+		if after := runtime.NumGoroutine(); after > before {
+			t.Errorf("goroutines are leaked. before: %d, after: %d", before, after)
+		}
+	})
+
+	t.Run("canceled context should be returned as error", func(t *testing.T) {
+		d := NewDefinitionRepository(nil)
+		if err := d.Add(defs...); err != nil {
+			t.Fatalf("unexpected error occurred in DefinitionRepository.Add(): %s", err)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if _, err := d.GenerateContext(ctx, "Test", nil, 1); err == nil {
+			t.Errorf("DefinitionRepository.GenerateContext() should return error if context is canceled")
+		}
+	})
+}
+
+func TestDefinitionRepository_Generate_WithRandSource(t *testing.T) {
+	defs := []*RawDefinition{
+		{
+			Type:         "Test",
+			RawTemplates: []RawTemplate{"{{.NestTest}}{{.NestTest2}}", "{{.NestTest2}}{{.NestTest}}"},
+		},
+		{
+			Type:         "NestTest",
+			RawTemplates: []RawTemplate{"a", "b", "c", "d", "e"},
+		},
+		{
+			Type:         "NestTest2",
+			RawTemplates: []RawTemplate{"v", "w", "x", "y", "z"},
+		},
+		{
+			Type:         "NestTest2",
+			RawTemplates: []RawTemplate{"0", "1", "2", "3", "4"},
+			Weight:       0.5,
+		},
+	}
+
+	generate := func(seed int64) (messages []Message) {
+		d := NewDefinitionRepository(&DefinitionRepositoryOption{
+			TemplatePickers: []TemplatePicker{RandomTemplatePicker},
+			RandSource:      rand.NewSource(seed),
+		})
+		if err := d.Add(defs...); err != nil {
+			t.Fatalf("unexpected error occurred in DefinitionRepository.Add(): %s", err)
+		}
+		for i := 0; i < 10; i++ {
+			msgs, err := d.Generate("Test", nil, 3)
+			if err != nil {
+				t.Fatalf("unexpected error occurred in DefinitionRepository.Generate(): %s", err)
+			}
+			messages = append(messages, msgs...)
+		}
+		return
+	}
+
+	if got, want := generate(1), generate(1); !reflect.DeepEqual(got, want) {
+		t.Errorf("DefinitionRepository.Generate() with the same seed should generate the same messages. got = %v, want %v", got, want)
+	}
+	if got, other := generate(1), generate(2); reflect.DeepEqual(got, other) {
+		t.Errorf("DefinitionRepository.Generate() with different seeds should generate different messages. got = %v", got)
+	}
+}
+
+func TestDefinitionRepository_Generate_NoMessageError(t *testing.T) {
+	tests := []struct {
+		name         string
+		opt          *DefinitionRepositoryOption
+		defs         []*RawDefinition
+		initialState *State
+		want         *Rejection
+	}{
+		{
+			name: "definition type is not defined",
+			defs: []*RawDefinition{
+				{Type: "Test", RawTemplates: []RawTemplate{"{{.A}}{{.NoExistDef}}"}},
+				{Type: "A", RawTemplates: []RawTemplate{"a"}},
+			},
+			want: &Rejection{Reason: DefinitionNotFound, Path: []DefinitionType{"Test", "NoExistDef"}, DefinitionType: "NoExistDef"},
+		},
+		{
+			name: "constraint is not satisfied",
+			defs: []*RawDefinition{
+				{Type: "Test", RawTemplates: []RawTemplate{"{{.A}}"}},
+				{Type: "A", RawTemplates: []RawTemplate{"a"}, RawConstraints: RawConstraints{"K": "V"}},
+			},
+			initialState: NewState(MessageMap{"K": "W"}),
+			want: &Rejection{
+				Reason:          ConstraintUnsatisfied,
+				Path:            []DefinitionType{"Test", "A"},
+				DefinitionType:  "A",
+				DefinitionID:    1,
+				ConstraintKey:   "K",
+				ConstraintValue: "V",
+				StateValue:      "W",
+				HasStateValue:   true,
+			},
+		},
+		{
+			name: "constraint group is not satisfied",
+			defs: []*RawDefinition{
+				{Type: "Test", RawTemplates: []RawTemplate{"{{.A}}"}},
+				{
+					Type:                "A",
+					RawTemplates:        []RawTemplate{"a"},
+					RawConstraintGroups: map[string]*RawConstraintGroup{"Group": {Constraints: RawConstraints{"K": "V"}}},
+				},
+			},
+			initialState: NewState(MessageMap{"K": "W"}),
+			want: &Rejection{
+				Reason:          ConstraintUnsatisfied,
+				Path:            []DefinitionType{"Test", "A"},
+				DefinitionType:  "A",
+				DefinitionID:    1,
+				ConstraintGroup: "Group",
+				ConstraintKey:   "K",
+				ConstraintValue: "V",
+				StateValue:      "W",
+				HasStateValue:   true,
+			},
+		},
+		{
+			name: "constraint of template is not satisfied",
+			defs: []*RawDefinition{
+				{Type: "Test", RawTemplates: []RawTemplate{"{{.A}}"}},
+				{
+					Type:               "A",
+					RawTemplates:       []RawTemplate{"a"},
+					TemplateAttributes: []*RawTemplateAttributes{{Constraints: RawConstraints{"K": "V"}}},
+				},
+			},
+			initialState: NewState(MessageMap{"K": "W"}),
+			want: &Rejection{
+				Reason:          ConstraintUnsatisfied,
+				Path:            []DefinitionType{"Test", "A"},
+				DefinitionType:  "A",
+				DefinitionID:    1,
+				Template:        "a",
+				ConstraintKey:   "K",
+				ConstraintValue: "V",
+				StateValue:      "W",
+				HasStateValue:   true,
+			},
+		},
+		{
+			name: "templates are exhausted by alias",
+			defs: []*RawDefinition{
+				{
+					Type:         "Test",
+					RawTemplates: []RawTemplate{"{{.A}}{{.AnotherA}}"},
+					Aliases:      Aliases{"AnotherA": &Alias{ReferType: "A"}},
+				},
+				{Type: "A", RawTemplates: []RawTemplate{"a"}},
+			},
+			want: &Rejection{
+				Reason:         TemplatesExhausted,
+				Path:           []DefinitionType{"Test", "AnotherA"},
+				DefinitionType: "A",
+				AliasName:      "AnotherA",
+				DefinitionID:   1,
+			},
+		},
+		{
+			name: "template is rejected by validator",
+			opt: &DefinitionRepositoryOption{
+				TemplateValidators: []TemplateValidator{MaxStrLenValidator(1)},
+			},
+			defs: []*RawDefinition{
+				{Type: "Test", RawTemplates: []RawTemplate{"{{.A}}"}},
+				{Type: "A", RawTemplates: []RawTemplate{"aa"}},
+			},
+			want: &Rejection{
+				Reason:         ValidatorRejected,
+				Path:           []DefinitionType{"Test", "A"},
+				DefinitionType: "A",
+				DefinitionID:   1,
+				Template:       "aa",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewDefinitionRepository(tt.opt)
+			if err := d.Add(tt.defs...); err != nil {
+				t.Fatalf("unexpected error occurred in DefinitionRepository.Add(): %s", err)
+			}
+			_, err := d.Generate("Test", tt.initialState, 1)
+			var noMsgErr *NoMessageError
+			if !xerrors.As(err, &noMsgErr) {
+				t.Fatalf("DefinitionRepository.Generate() error = %v, want NoMessageError", err)
+			}
+			if len(noMsgErr.MostCommon) == 0 {
+				t.Fatalf("NoMessageError.MostCommon is empty")
+			}
+			if got := noMsgErr.MostCommon[0].Rejection; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("NoMessageError.MostCommon[0] = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDefinitionRepository_Generate_Limits(t *testing.T) {
+	recursiveDefs := []*RawDefinition{
+		{Type: "Test", RawTemplates: []RawTemplate{"{{.A}}"}},
+		{Type: "A", RawTemplates: []RawTemplate{"a{{.A}}"}},
+	}
+	tests := []struct {
+		name     string
+		limits   Limits
+		defs     []*RawDefinition
+		want     LimitKind
+		wantPath []DefinitionType
+	}{
+		{
+			name:     "recursive definition should exceed max depth",
+			limits:   Limits{MaxDepth: 3},
+			defs:     recursiveDefs,
+			want:     DepthLimit,
+			wantPath: []DefinitionType{"Test", "A", "A", "A"},
+		},
+		{
+			name:     "recursive definition should exceed max steps",
+			limits:   Limits{MaxSteps: 2},
+			defs:     recursiveDefs,
+			want:     StepLimit,
+			wantPath: []DefinitionType{"Test", "A", "A"},
+		},
+		{
+			name:   "recursive definition should exceed timeout",
+			limits: Limits{Timeout: 10 * time.Millisecond},
+			defs:   recursiveDefs,
+			want:   TimeLimit,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewDefinitionRepository(&DefinitionRepositoryOption{Limits: tt.limits})
+			if err := d.Add(tt.defs...); err != nil {
+				t.Fatalf("unexpected error occurred in DefinitionRepository.Add(): %s", err)
+			}
+			_, err := d.Generate("Test", nil, 1)
+			var limitErr *LimitExceededError
+			if !xerrors.As(err, &limitErr) {
+				t.Fatalf("DefinitionRepository.Generate() error = %v, want LimitExceededError", err)
+			}
+			if limitErr.Limit != tt.want {
+				t.Errorf("LimitExceededError.Limit = %v, want %v", limitErr.Limit, tt.want)
+			}
+			if limitErr.DefinitionType != "A" {
+				t.Errorf("LimitExceededError.DefinitionType = %v, want A", limitErr.DefinitionType)
+			}
+			if tt.wantPath != nil && !reflect.DeepEqual(limitErr.Path, tt.wantPath) {
+				t.Errorf("LimitExceededError.Path = %v, want %v", limitErr.Path, tt.wantPath)
+			}
+		})
+	}
+
+	t.Run("limits should not affect generation within them", func(t *testing.T) {
+		d := NewDefinitionRepository(&DefinitionRepositoryOption{Limits: Limits{MaxDepth: 2, MaxSteps: 2, Timeout: time.Second}})
+		if err := d.Add(&RawDefinition{Type: "Test", RawTemplates: []RawTemplate{"{{.A}}"}}, &RawDefinition{Type: "A", RawTemplates: []RawTemplate{"a"}}); err != nil {
+			t.Fatalf("unexpected error occurred in DefinitionRepository.Add(): %s", err)
+		}
+		if _, err := d.Generate("Test", nil, 1); err != nil {
+			t.Errorf("unexpected error occurred in DefinitionRepository.Generate(): %s", err)
+		}
+	})
+}
+
+func TestDefinitionRepository_GenerateDetailed(t *testing.T) {
+	defs := []*RawDefinition{
+		{
+			Type:         "Test",
+			RawTemplates: []RawTemplate{"{{.A}}-{{.AnotherA}}"},
+			Aliases:      Aliases{"AnotherA": &Alias{ReferType: "A"}},
+		},
+		{
+			Type:           "A",
+			RawTemplates:   []RawTemplate{"a{{.B}}", "x"},
+			RawConstraints: RawConstraints{"K+": "V"},
+		},
+		{
+			Type:         "B",
+			RawTemplates: []RawTemplate{"b"},
+		},
+	}
+	want := &DetailedMessage{
+		Message: "ab-x",
+		State:   MessageMap{"Test": "ab-x", "A": "ab", "AnotherA": "x", "B": "b", "K": "V"},
+		Derivation: &Derivation{
+			Type:         "Test",
+			DefinitionID: 0,
+			Template:     "{{.A}}-{{.AnotherA}}",
+			Message:      "ab-x",
+			AddedState:   MessageMap{},
+			Children: []*Derivation{
+				{
+					Type:         "A",
+					DefinitionID: 1,
+					Template:     "a{{.B}}",
+					Message:      "ab",
+					AddedState:   MessageMap{"K": "V"},
+					Children: []*Derivation{
+						{Type: "B", DefinitionID: 2, Template: "b", Message: "b", AddedState: MessageMap{}},
+					},
+				},
+				{
+					Type:         "A",
+					AliasName:    "AnotherA",
+					DefinitionID: 1,
+					Template:     "x",
+					Message:      "x",
+					AddedState:   MessageMap{},
+				},
+			},
+		},
+	}
+
+	d := NewDefinitionRepository(nil)
+	if err := d.Add(defs...); err != nil {
+		t.Fatalf("unexpected error occurred in DefinitionRepository.Add(): %s", err)
+	}
+	got, err := d.GenerateDetailed(context.Background(), "Test", nil, 1)
+	if err != nil {
+		t.Fatalf("unexpected error occurred in DefinitionRepository.GenerateDetailed(): %s", err)
+	}
+	if len(got) != 1 {
+		t.Fatalf("DefinitionRepository.GenerateDetailed() returns %d messages, want 1", len(got))
+	}
+	if !reflect.DeepEqual(got[0], want) {
+		t.Errorf("DefinitionRepository.GenerateDetailed() = %#v, want %#v", got[0], want)
+	}
+}
+
+func TestDefinitionRepository_Enumerate(t *testing.T) {
+	tests := []struct {
+		name         string
+		opt          *DefinitionRepositoryOption
+		defs         []*RawDefinition
+		initialState *State
+		want         []Message
+	}{
+		{
+			name: "should yield messages in ascending order",
+			defs: []*RawDefinition{
+				{
+					Type:         "Test",
+					RawTemplates: []RawTemplate{"{{.NestTest}}{{.NestTest2}}"},
+				},
+				{
+					Type:         "NestTest",
+					RawTemplates: []RawTemplate{"a", "b"},
+				},
+				{
+					Type:         "NestTest2",
+					RawTemplates: []RawTemplate{"x", "y"},
+				},
+			},
+			want: []Message{"ax", "ay", "bx", "by"},
+		},
+		{
+			name: "should not yield duplicated messages",
+			defs: []*RawDefinition{
+				{
+					Type:         "Test",
+					RawTemplates: []RawTemplate{"{{.NestTest}}", "a"},
+				},
+				{
+					Type:         "NestTest",
+					RawTemplates: []RawTemplate{"a", "b"},
+				},
+			},
+			want: []Message{"a", "b"},
+		},
+		{
+			name: "should pick definitions in order of constraint priority",
+			defs: []*RawDefinition{
+				{
+					Type:         "Test",
+					RawTemplates: []RawTemplate{"a"},
+				},
+				{
+					Type:           "Test",
+					RawTemplates:   []RawTemplate{"b"},
+					RawConstraints: RawConstraints{"K:1": "V"},
+				},
+				{
+					Type:           "Test",
+					RawTemplates:   []RawTemplate{"c"},
+					RawConstraints: RawConstraints{"K": "X"},
+				},
+			},
+			initialState: NewState(MessageMap{"K": "V"}),
+			want:         []Message{"b", "a"},
+		},
+		{
+			name: "should apply validators",
+			opt: &DefinitionRepositoryOption{
+				TemplateValidators: []TemplateValidator{MaxStrLenValidator(2)},
+			},
+			defs: []*RawDefinition{
+				{
+					Type:         "Test",
+					RawTemplates: []RawTemplate{"{{.NestTest}}{{.NestTest}}"},
+				},
+				{
+					Type:         "NestTest",
+					RawTemplates: []RawTemplate{"a", "bb"},
+				},
+			},
+			want: []Message{"aa"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewDefinitionRepository(tt.opt)
+			if err := d.Add(tt.defs...); err != nil {
+				t.Fatalf("unexpected error occurred in DefinitionRepository.Add(): %s", err)
+			}
+			it, err := d.Enumerate(context.Background(), "Test", tt.initialState)
+			if err != nil {
+				t.Fatalf("unexpected error occurred in DefinitionRepository.Enumerate(): %s", err)
+			}
+
+			var got []Message
+			for {
+				msg, ok, err := it.Next()
+				if err != nil {
+					t.Fatalf("unexpected error occurred in MessageIterator.Next(): %s", err)
+				}
+				if !ok {
+					break
+				}
+				got = append(got, msg)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DefinitionRepository.Enumerate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestRandomTemplatePicker(t *testing.T) {
 	def := newDefinitionWithAliasOrPanic(&RawDefinition{
 		Type:         "Test",
@@ -377,3 +941,91 @@ func TestRandomTemplatePicker(t *testing.T) {
 		})
 	}
 }
+
+func TestDefinitionRepository_UndefinedConditionType(t *testing.T) {
+	defs := []*RawDefinition{
+		{Type: "Test", RawTemplates: []RawTemplate{"{{if .Formal}}{{.Polite}}{{else}}{{.Casual}}{{end}}, {{.Name}}"}},
+		{Type: "Polite", RawTemplates: []RawTemplate{"Good morning"}},
+		{Type: "Casual", RawTemplates: []RawTemplate{"Hi"}},
+		{Type: "Name", RawTemplates: []RawTemplate{"Alice"}},
+	}
+	tests := []struct {
+		name         string
+		initialState *State
+		want         Message
+	}{
+		{name: "else branch is taken if the condition type is not defined", want: "Hi, Alice"},
+		{name: "first branch is taken if the state has the condition type", initialState: NewState(MessageMap{"Formal": "yes"}), want: "Good morning, Alice"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewDefinitionRepository(nil)
+			if err := d.Add(defs...); err != nil {
+				t.Fatalf("unexpected error occurred in DefinitionRepository.Add(): %s", err)
+			}
+
+			messages, err := d.Generate("Test", tt.initialState, 1)
+			if err != nil {
+				t.Fatalf("unexpected error occurred in DefinitionRepository.Generate(): %s", err)
+			}
+			if !reflect.DeepEqual(messages, []Message{tt.want}) {
+				t.Errorf("DefinitionRepository.Generate() = %v, want %v", messages, tt.want)
+			}
+
+			it, err := d.Enumerate(context.Background(), "Test", tt.initialState)
+			if err != nil {
+				t.Fatalf("unexpected error occurred in DefinitionRepository.Enumerate(): %s", err)
+			}
+			var enumerated []Message
+			for {
+				msg, ok, err := it.Next()
+				if err != nil {
+					t.Fatalf("unexpected error occurred in MessageIterator.Next(): %s", err)
+				}
+				if !ok {
+					break
+				}
+				enumerated = append(enumerated, msg)
+			}
+			if !reflect.DeepEqual(enumerated, []Message{tt.want}) {
+				t.Errorf("DefinitionRepository.Enumerate() = %v, want %v", enumerated, tt.want)
+			}
+
+			result, err := d.Count(context.Background(), "Test", tt.initialState)
+			if err != nil {
+				t.Fatalf("unexpected error occurred in DefinitionRepository.Count(): %s", err)
+			}
+			if result.Count.Int64() != 1 {
+				t.Errorf("DefinitionRepository.Count() = %v, want 1", result.Count)
+			}
+
+			if issues := d.Lint("Test", tt.initialState); len(issues) > 0 {
+				t.Errorf("DefinitionRepository.Lint() = %v, want no issues", issues)
+			}
+		})
+	}
+}
+
+func TestDefinitionRepository_Generate_ReferringConstraintsWithRandSource(t *testing.T) {
+	defs := []*RawDefinition{
+		{Type: "Test", RawTemplates: []RawTemplate{"{{.Hero}} vs {{.Rival}}"}},
+		// Echo+ refers Side which is set by Side+ of the same definition
+		{Type: "Hero", RawTemplates: []RawTemplate{"Alice"}, RawConstraints: RawConstraints{"Side+": "light", "Echo+": "$Side", "Alpha+": "$Echo"}},
+		{Type: "Rival", RawTemplates: []RawTemplate{"Bob"}, RawConstraints: RawConstraints{"Echo": "light", "Alpha": "light"}},
+	}
+
+	// constraints are created from maps each time, so map order differs between runs
+	for i := 0; i < 20; i++ {
+		d := NewDefinitionRepository(&DefinitionRepositoryOption{RandSource: rand.NewSource(1)})
+		if err := d.Add(defs...); err != nil {
+			t.Fatalf("unexpected error occurred in DefinitionRepository.Add(): %s", err)
+		}
+		messages, err := d.Generate("Test", nil, 1)
+		if err != nil {
+			t.Fatalf("unexpected error occurred in DefinitionRepository.Generate(): %s", err)
+		}
+		if want := []Message{"Alice vs Bob"}; !reflect.DeepEqual(messages, want) {
+			t.Fatalf("DefinitionRepository.Generate() = %v, want %v", messages, want)
+		}
+	}
+}
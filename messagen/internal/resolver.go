@@ -0,0 +1,558 @@
+package internal
+
+import (
+	"context"
+
+	"golang.org/x/xerrors"
+)
+
+// stateIterator lazily yields states in which the target of the iterator is resolved.
+// next returns false when no more states exist.
+type stateIterator interface {
+	next() (*State, bool, error)
+}
+
+// resolver searches states which satisfy constraints by depth-first backtracking on a single goroutine.
+// Each level of the search tree is represented by an iterator, and the search proceeds only when next is called.
+type resolver struct {
+	ctx        context.Context
+	repo       *DefinitionRepository
+	rejections *rejectionRecorder
+	budget     *budget
+}
+
+func newResolver(ctx context.Context, repo *DefinitionRepository) *resolver {
+	return &resolver{ctx: ctx, repo: repo, rejections: newRejectionRecorder(), budget: newBudget(repo.limits)}
+}
+
+// definitionIterator yields states in which a definition type is resolved by one of the picked definitions.
+type definitionIterator struct {
+	r         *resolver
+	defType   DefinitionType
+	defs      Definitions
+	aliasName AliasName
+	alias     *Alias
+	state     *State
+	path      []DefinitionType
+	index     int
+	current   stateIterator
+	exhausted bool
+}
+
+func (r *resolver) newDefinitionIterator(defType DefinitionType, aliasName AliasName, alias *Alias, state *State, path []DefinitionType) (*definitionIterator, error) {
+	if err := r.budget.checkDepth(defType, path); err != nil {
+		return nil, err
+	}
+	defs, err := r.repo.pickDefinitions(r.ctx, defType, state)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to pick definitions: %w", err)
+	}
+	return &definitionIterator{
+		r:         r,
+		defType:   defType,
+		defs:      defs,
+		aliasName: aliasName,
+		alias:     alias,
+		state:     state,
+		path:      path,
+	}, nil
+}
+
+// recordRejections records why definitions which are not picked are rejected.
+// This is called only when the iterator is exhausted, so it does not slow down successful searches.
+func (it *definitionIterator) recordRejections() {
+	allDefs := localizeDefinitions(it.r.repo.List(it.defType), it.state.locales)
+	if len(allDefs) == 0 && !it.r.repo.hasProvider(it.defType) {
+		it.r.rejections.record(&Rejection{
+			Reason:         DefinitionNotFound,
+			Path:           it.path,
+			DefinitionType: it.defType,
+			AliasName:      it.aliasName,
+		})
+		return
+	}
+
+	picked := map[DefinitionID]bool{}
+	for _, def := range it.defs {
+		picked[def.ID] = true
+	}
+	for _, def := range allDefs {
+		if picked[def.ID] {
+			continue
+		}
+		if it.recordUnsatisfiedConstraints(def, "", def.Constraints) > 0 {
+			continue
+		}
+		// constraints of the definition are satisfied, so none of the constraint groups is satisfied
+		for _, group := range def.ConstraintGroups {
+			it.recordUnsatisfiedConstraints(def, group.Name, group.Constraints)
+		}
+	}
+}
+
+// recordUnsatisfiedConstraints records constraints which are not satisfied by the state, and returns the number of them.
+func (it *definitionIterator) recordUnsatisfiedConstraints(def *Definition, groupName string, constraints *Constraints) int {
+	n := 0
+	for _, constraint := range constraints.values {
+		if constraint.IsSatisfied(it.state) {
+			continue
+		}
+		n++
+		stateValue, hasStateValue := it.state.Get(constraint.key.DefinitionType)
+		it.r.rejections.record(&Rejection{
+			Reason:          ConstraintUnsatisfied,
+			Path:            it.path,
+			DefinitionType:  it.defType,
+			AliasName:       it.aliasName,
+			DefinitionID:    def.ID,
+			ConstraintGroup: groupName,
+			ConstraintKey:   constraint.key.Raw,
+			ConstraintValue: constraint.rawValue(),
+			StateValue:      stateValue,
+			HasStateValue:   hasStateValue,
+		})
+	}
+	return n
+}
+
+func (it *definitionIterator) next() (*State, bool, error) {
+	for {
+		if it.current != nil {
+			state, ok, err := it.current.next()
+			if err != nil || ok {
+				return state, ok, err
+			}
+			it.current = nil
+		}
+
+		if it.index >= len(it.defs) {
+			if !it.exhausted {
+				it.exhausted = true
+				it.recordRejections()
+			}
+			return nil, false, nil
+		}
+		def := &DefinitionWithAlias{
+			Definition: it.defs[it.index],
+			aliasName:  it.aliasName,
+			alias:      it.alias,
+		}
+		it.index++
+
+		current, err := it.r.newTemplateIterator(def, it.state, it.path)
+		if err != nil {
+			return nil, false, err
+		}
+		it.current = current
+	}
+}
+
+// templateIterator yields states in which one of the picked templates of a definition is resolved.
+type templateIterator struct {
+	r         *resolver
+	def       *DefinitionWithAlias
+	state     *State
+	path      []DefinitionType
+	templates Templates
+	index     int
+	template  *Template
+	depends   stateIterator
+	// generated picks values of the generator of the definition instead of templates if it is not nil.
+	generated *generatedValuePicker
+}
+
+func (r *resolver) newTemplateIterator(def *DefinitionWithAlias, state *State, path []DefinitionType) (*templateIterator, error) {
+	if r.repo.pickGeneratedValuesAtRandom && def.generated != nil {
+		return &templateIterator{
+			r:         r,
+			def:       def,
+			state:     state,
+			path:      path,
+			generated: newGeneratedValuePicker(def.generated.n),
+		}, nil
+	}
+
+	// pickers may overwrite def.Templates, so keep the original templates
+	allTemplates, err := def.allTemplates()
+	if err != nil {
+		return nil, err
+	}
+	templates, err := r.repo.applyTemplatePickers(def, state)
+	if err != nil {
+		return nil, err
+	}
+	for _, template := range allTemplates {
+		r.recordUnsatisfiedTemplateConstraints(def, template, state, path)
+		if state.history != nil && state.history.HasTemplate(def.Type, template.Raw) {
+			r.rejections.record(&Rejection{
+				Reason:         InHistory,
+				Path:           path,
+				DefinitionType: def.Type,
+				AliasName:      def.aliasName,
+				DefinitionID:   def.ID,
+				Template:       template.Raw,
+			})
+		}
+	}
+	if len(templates) == 0 && len(allTemplates) > 0 && len(allTemplates.Subtract(pickedTemplates(state, def.ID)...)) == 0 {
+		r.rejections.record(&Rejection{
+			Reason:         TemplatesExhausted,
+			Path:           path,
+			DefinitionType: def.Type,
+			AliasName:      def.aliasName,
+			DefinitionID:   def.ID,
+		})
+	}
+	return &templateIterator{
+		r:         r,
+		def:       def,
+		state:     state,
+		path:      path,
+		templates: templates,
+	}, nil
+}
+
+// recordUnsatisfiedTemplateConstraints records constraints of the template which are not satisfied by the state.
+func (r *resolver) recordUnsatisfiedTemplateConstraints(def *DefinitionWithAlias, template *Template, state *State, path []DefinitionType) {
+	if template.Constraints == nil {
+		return
+	}
+	for _, constraint := range template.Constraints.values {
+		if constraint.IsSatisfied(state) {
+			continue
+		}
+		stateValue, hasStateValue := state.Get(constraint.key.DefinitionType)
+		r.rejections.record(&Rejection{
+			Reason:          ConstraintUnsatisfied,
+			Path:            path,
+			DefinitionType:  def.Type,
+			AliasName:       def.aliasName,
+			DefinitionID:    def.ID,
+			Template:        template.Raw,
+			ConstraintKey:   constraint.key.Raw,
+			ConstraintValue: constraint.rawValue(),
+			StateValue:      stateValue,
+			HasStateValue:   hasStateValue,
+		})
+	}
+}
+
+func pickedTemplates(state *State, defID DefinitionID) Templates {
+	templates, ok := state.pickedTemplates[defID]
+	if !ok {
+		return nil
+	}
+	return *templates
+}
+
+func (it *templateIterator) next() (*State, bool, error) {
+	for {
+		if err := it.r.ctx.Err(); err != nil {
+			return nil, false, err
+		}
+
+		if it.depends != nil {
+			satisfiedState, ok, err := it.depends.next()
+			if err != nil {
+				return nil, false, err
+			}
+			if !ok {
+				it.depends = nil
+				continue
+			}
+			msg, err := it.template.Execute(satisfiedState)
+			if err != nil {
+				return nil, false, err
+			}
+			newState := satisfiedState.Copy(it.def.Order)
+			children := satisfiedState.trace.children
+			newState.trace = satisfiedState.trace.parent
+			if ok, err := it.r.update(it.def, it.template, newState, msg, children, it.path); err != nil || ok {
+				return newState, ok, err
+			}
+			continue
+		}
+
+		template, ok, err := it.nextTemplate()
+		if err != nil || !ok {
+			return nil, false, err
+		}
+		if err := it.r.budget.step(it.def.Type, it.path); err != nil {
+			return nil, false, err
+		}
+
+		newState := it.state.Copy(it.def.Order)
+		if _, ok := it.def.getFirstUnsatisfiedDef(template, newState); template.IsPlainText() && !ok {
+			if ok, err := it.r.update(it.def, template, newState, Message(template.Raw), nil, it.path); err != nil || ok {
+				return newState, ok, err
+			}
+			continue
+		}
+		// derivations resolved by depends are collected in a new frame, and become children of the template's derivation.
+		newState.trace = newState.trace.push()
+		it.template = template
+		it.depends = it.r.newDependsIterator(it.def, template, newState, it.path)
+	}
+}
+
+// nextTemplate returns the next template to be tried.
+func (it *templateIterator) nextTemplate() (*Template, bool, error) {
+	if it.generated != nil {
+		return it.nextGeneratedTemplate()
+	}
+	if it.index >= len(it.templates) {
+		return nil, false, nil
+	}
+	template := it.templates[it.index]
+	it.index++
+	return template, true, nil
+}
+
+// nextGeneratedTemplate builds the template of a value which is picked at random from the values of the generator.
+// Values in the history and values which the alias already picked are skipped like template pickers do.
+func (it *templateIterator) nextGeneratedTemplate() (*Template, bool, error) {
+	for {
+		i, ok := it.generated.pop(it.state.Rand())
+		if !ok {
+			return nil, false, nil
+		}
+		template, err := it.def.generated.at(i)
+		if err != nil {
+			return nil, false, err
+		}
+		if it.state.history != nil && it.state.history.HasTemplate(it.def.Type, template.Raw) {
+			it.r.rejections.record(&Rejection{
+				Reason:         InHistory,
+				Path:           it.path,
+				DefinitionType: it.def.Type,
+				AliasName:      it.def.aliasName,
+				DefinitionID:   it.def.ID,
+				Template:       template.Raw,
+			})
+			continue
+		}
+		picked := pickedTemplates(it.state, it.def.ID)
+		if (it.def.alias == nil || !it.def.alias.AllowDuplicate) && picked.Has(template) {
+			continue
+		}
+		return template, true, nil
+	}
+}
+
+// update transforms the message generated by the template, sets it to state and records its derivation, then validates it.
+func (r *resolver) update(def *DefinitionWithAlias, template *Template, state *State, msg Message, children []*Derivation, path []DefinitionType) (bool, error) {
+	msg, err := transform(msg, r.repo.transformers(def, path))
+	if err != nil {
+		return false, err
+	}
+
+	var addedKeys []DefinitionType
+	constraints := def.allConstraints()
+	if template.Constraints != nil {
+		constraints = append(constraints[:len(constraints):len(constraints)], template.Constraints.values...)
+	}
+	for _, constraint := range constraints {
+		if !constraint.key.WillAddValue {
+			continue
+		}
+		if _, ok := state.Get(constraint.key.DefinitionType); !ok {
+			addedKeys = append(addedKeys, constraint.key.DefinitionType)
+		}
+	}
+
+	if err := state.Update(def, template, msg); err != nil {
+		return false, err
+	}
+
+	addedState := MessageMap{}
+	for _, key := range addedKeys {
+		if value, ok := state.Get(key); ok {
+			addedState[string(key)] = value
+		}
+	}
+	state.trace = state.trace.withChild(&Derivation{
+		Type:         def.Type,
+		AliasName:    def.aliasName,
+		DefinitionID: def.ID,
+		Template:     template.Raw,
+		Message:      msg,
+		AddedState:   addedState,
+		Children:     children,
+	})
+	return r.validate(def, template, state, path)
+}
+
+// validate applies template validators to the transformed message, and records the rejection if the template is rejected.
+func (r *resolver) validate(def *DefinitionWithAlias, template *Template, state *State, path []DefinitionType) (bool, error) {
+	validatedTemplate, err := r.repo.transformedTemplate(def, template, state, path)
+	if err != nil {
+		return false, err
+	}
+	ok, err := r.repo.applyTemplateValidators(validatedTemplate, state)
+	if err != nil || ok {
+		return ok, err
+	}
+	r.rejections.record(&Rejection{
+		Reason:         ValidatorRejected,
+		Path:           path,
+		DefinitionType: def.Type,
+		AliasName:      def.aliasName,
+		DefinitionID:   def.ID,
+		Template:       template.Raw,
+	})
+	return false, nil
+}
+
+// dependsIterator yields states which satisfy all definition types that a template depends on.
+// Definition types are resolved one by one in the order of Template.Depends,
+// and the iterator for each resolved definition type is kept in an explicit stack for backtracking.
+type dependsIterator struct {
+	r         *resolver
+	def       *DefinitionWithAlias
+	template  *Template
+	state     *State
+	path      []DefinitionType
+	stack     []*definitionIterator
+	started   bool
+	satisfied bool
+}
+
+func (r *resolver) newDependsIterator(def *DefinitionWithAlias, template *Template, state *State, path []DefinitionType) *dependsIterator {
+	return &dependsIterator{
+		r:        r,
+		def:      def,
+		template: template,
+		state:    state,
+		path:     path,
+	}
+}
+
+func (it *dependsIterator) next() (*State, bool, error) {
+	if !it.started {
+		it.started = true
+		if _, ok := it.def.getFirstUnsatisfiedDef(it.template, it.state); !ok {
+			it.satisfied = true
+			return it.state, true, nil
+		}
+		if err := it.push(it.state); err != nil {
+			return nil, false, err
+		}
+	}
+	if it.satisfied {
+		return nil, false, nil
+	}
+
+	for len(it.stack) > 0 {
+		newState, ok, err := it.stack[len(it.stack)-1].next()
+		if err != nil {
+			return nil, false, err
+		}
+		if !ok {
+			it.stack = it.stack[:len(it.stack)-1]
+			continue
+		}
+
+		if ok, err := it.r.validate(it.def, it.template, newState, it.path); err != nil {
+			return nil, false, err
+		} else if !ok {
+			continue
+		}
+
+		if _, ok := it.def.getFirstUnsatisfiedDef(it.template, newState); !ok {
+			return newState, true, nil
+		}
+		if err := it.push(newState); err != nil {
+			return nil, false, err
+		}
+	}
+	return nil, false, nil
+}
+
+// push starts to resolve the first unsatisfied definition type of the template.
+func (it *dependsIterator) push(state *State) error {
+	defType, _ := it.def.getFirstUnsatisfiedDef(it.template, state)
+	path := make([]DefinitionType, len(it.path), len(it.path)+1)
+	copy(path, it.path)
+	path = append(path, defType)
+
+	alias, ok := it.def.Aliases[AliasName(defType)]
+	var aliasName AliasName
+	if ok {
+		aliasName = AliasName(defType)
+		defType = alias.ReferType
+	}
+	defIterator, err := it.r.newDefinitionIterator(defType, aliasName, alias, state, path)
+	if err != nil {
+		return err
+	}
+	it.stack = append(it.stack, defIterator)
+	return nil
+}
+
+// messageIterator yields messages of the definition type.
+type messageIterator struct {
+	defType DefinitionType
+	states  stateIterator
+}
+
+func (r *resolver) newMessageIterator(defType DefinitionType, initialState *State) (*messageIterator, error) {
+	state := NewState(nil)
+	if initialState != nil {
+		state = initialState.Copy(nil)
+	}
+	if state.rand == nil {
+		state.SetRand(r.repo.rand)
+	}
+	state.resolvable = r.repo.isResolvable
+	states, err := r.newDefinitionIterator(defType, "", nil, state, []DefinitionType{defType})
+	if err != nil {
+		return nil, xerrors.Errorf("failed to generate message: %w", err)
+	}
+	return &messageIterator{defType: defType, states: states}, nil
+}
+
+func (it *messageIterator) next() (Message, bool, error) {
+	detailed, ok, err := it.nextDetailed()
+	if err != nil || !ok {
+		return "", false, err
+	}
+	return detailed.Message, true, nil
+}
+
+// nextDetailed returns the next message with its final state and derivation.
+func (it *messageIterator) nextDetailed() (*DetailedMessage, bool, error) {
+	state, ok, err := it.states.next()
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	msg, ok := state.Get(it.defType)
+	if !ok {
+		return nil, false, xerrors.Errorf("error occurred in Generate. message not found. def type: %s", it.defType)
+	}
+	var derivation *Derivation
+	if state.trace != nil && len(state.trace.children) > 0 {
+		derivation = state.trace.children[len(state.trace.children)-1]
+	}
+	return &DetailedMessage{Message: msg, State: state.Map(), Derivation: derivation}, true, nil
+}
+
+// MessageIterator yields distinct messages.
+type MessageIterator struct {
+	it   *messageIterator
+	seen map[Message]struct{}
+}
+
+// Next returns the next message. ok is false if no more messages exist.
+func (m *MessageIterator) Next() (msg Message, ok bool, err error) {
+	for {
+		msg, ok, err := m.it.next()
+		if err != nil || !ok {
+			return "", false, err
+		}
+		if _, seen := m.seen[msg]; seen {
+			continue
+		}
+		m.seen[msg] = struct{}{}
+		return msg, true, nil
+	}
+}
@@ -0,0 +1,84 @@
+package internal
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestResolver_newMessageIterator(t *testing.T) {
+	tests := []struct {
+		name         string
+		defs         []*RawDefinition
+		initialState *State
+		want         []Message
+	}{
+		{
+			name: "should yield all combinations by backtracking",
+			defs: []*RawDefinition{
+				{
+					Type:         "Test",
+					RawTemplates: []RawTemplate{"{{.NestTest}}{{.NestTest2}}"},
+				},
+				{
+					Type:         "NestTest",
+					RawTemplates: []RawTemplate{"a", "b"},
+				},
+				{
+					Type:         "NestTest2",
+					RawTemplates: []RawTemplate{"x"},
+				},
+				{
+					Type:           "NestTest2",
+					RawTemplates:   []RawTemplate{"y"},
+					RawConstraints: RawConstraints{"NestTest": "b"},
+				},
+			},
+			want: []Message{"ax", "bx", "by"},
+		},
+		{
+			name: "should not yield anything if dependency can not be resolved",
+			defs: []*RawDefinition{
+				{
+					Type:         "Test",
+					RawTemplates: []RawTemplate{"{{.NestTest}}{{.NoExistDef}}"},
+				},
+				{
+					Type:         "NestTest",
+					RawTemplates: []RawTemplate{"a", "b"},
+				},
+			},
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewDefinitionRepository(nil)
+			if err := d.Add(tt.defs...); err != nil {
+				t.Fatalf("unexpected error occurred in DefinitionRepository.Add(): %s", err)
+			}
+			it, err := newResolver(context.Background(), d).newMessageIterator("Test", tt.initialState)
+			if err != nil {
+				t.Fatalf("unexpected error occurred in newMessageIterator(): %s", err)
+			}
+
+			var got []Message
+			for {
+				msg, ok, err := it.next()
+				if err != nil {
+					t.Fatalf("unexpected error occurred in messageIterator.next(): %s", err)
+				}
+				if !ok {
+					break
+				}
+				got = append(got, msg)
+			}
+			sort.Slice(got, func(i, j int) bool { return got[i] < got[j] })
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("messageIterator.next() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
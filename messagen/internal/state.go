@@ -1,6 +1,9 @@
 package internal
 
 import (
+	"math/rand"
+	"strings"
+
 	"golang.org/x/xerrors"
 )
 
@@ -16,16 +19,14 @@ func (m MessageMap) copy() MessageMap {
 
 type PickedTemplateMap map[DefinitionID]*Templates
 
-func (p PickedTemplateMap) copy(order []DefinitionType) (PickedTemplateMap, error) {
+func (p PickedTemplateMap) copy() PickedTemplateMap {
 	newP := PickedTemplateMap{}
 	for id, templates := range p {
-		newTemplates, err := templates.Copy(order)
-		if err != nil {
-			return nil, err
-		}
+		newTemplates := make(Templates, len(*templates))
+		copy(newTemplates, *templates)
 		newP[id] = &newTemplates
 	}
-	return newP, nil
+	return newP
 }
 
 type AliasName string
@@ -46,6 +47,12 @@ type State struct {
 	m               MessageMap
 	pickedTemplates PickedTemplateMap
 	aliases         AliasMap
+	rand            *rand.Rand
+	trace           *traceFrame
+	history         *History
+	locales         []Locale
+	// resolvable reports whether a definition type can be resolved. If it is nil, all types are regarded as resolvable.
+	resolvable func(DefinitionType) bool
 }
 
 func NewState(m MessageMap) *State {
@@ -59,6 +66,35 @@ func NewState(m MessageMap) *State {
 	}
 }
 
+// Rand returns the random source which pickers should use while the state is resolved.
+// If random source is not set, the global source of math/rand is returned.
+func (s *State) Rand() *rand.Rand {
+	if s.rand == nil {
+		return globalRand
+	}
+	return s.rand
+}
+
+// SetRand sets the random source which is used by pickers.
+func (s *State) SetRand(r *rand.Rand) {
+	s.rand = r
+}
+
+// SetHistory sets the history of messages and templates which should not be generated again.
+func (s *State) SetHistory(h *History) {
+	s.history = h
+}
+
+// SetLocales sets the locale fallback chain. Definitions of the first locale in the chain which the definition type has are picked.
+func (s *State) SetLocales(locales []Locale) {
+	s.locales = locales
+}
+
+// canResolve reports whether the definition type can be resolved by definitions or providers.
+func (s *State) canResolve(defType DefinitionType) bool {
+	return s.resolvable == nil || s.resolvable(defType)
+}
+
 func (s *State) Set(defType DefinitionType, msg Message) {
 	s.m[string(defType)] = msg
 }
@@ -78,7 +114,11 @@ func (s *State) SetByConstraint(constraint *Constraint) (bool, error) {
 		return false, nil
 	}
 
-	msg, err := constraint.value.ToMessage()
+	value, ok := constraint.value.resolve(s)
+	if !ok {
+		return false, nil
+	}
+	msg, err := value.ToMessage()
 	if err != nil {
 		return false, xerrors.Errorf("failed to set constraint value to state: %w", err)
 	}
@@ -93,12 +133,7 @@ func (s *State) SetByConstraint(constraint *Constraint) (bool, error) {
 
 func (s *State) SetByConstraints(constraints *Constraints) (int, error) {
 	cnt := 0
-	for rKey, rValue := range constraints.raw {
-		constraint, err := NewConstraint(rKey, rValue)
-		if err != nil {
-			return -1, xerrors.Errorf("failed to set state from constraints: %w", err)
-		}
-
+	for _, constraint := range constraints.values {
 		ok, err := s.SetByConstraint(constraint)
 		if err != nil {
 			return -1, xerrors.Errorf("failed to set state from constraints: %w", err)
@@ -111,6 +146,8 @@ func (s *State) SetByConstraints(constraints *Constraints) (int, error) {
 }
 
 func (s *State) SetByDef(def *DefinitionWithAlias, msg Message) error {
+	// the constraint group is decided before the state is updated
+	group := def.SatisfiedConstraintGroup(s)
 	if def.aliasName == "" {
 		s.Set(def.Type, msg)
 	} else {
@@ -119,6 +156,27 @@ func (s *State) SetByDef(def *DefinitionWithAlias, msg Message) error {
 	if _, err := s.SetByConstraints(def.Constraints); err != nil {
 		return xerrors.Errorf("failed to update state while message generating: %w", err)
 	}
+	if group != nil {
+		if _, err := s.SetByConstraints(group.Constraints); err != nil {
+			return xerrors.Errorf("failed to update state by constraint group %s: %w", group.Name, err)
+		}
+	}
+	if err := s.SetByAssignments(def.Assignments); err != nil {
+		return xerrors.Errorf("failed to update state by assignments of %s: %w", def.Type, err)
+	}
+	return nil
+}
+
+// SetByTemplate applies constraints with + and assignments of the picked template to the state.
+func (s *State) SetByTemplate(template *Template) error {
+	if template.Constraints != nil {
+		if _, err := s.SetByConstraints(template.Constraints); err != nil {
+			return xerrors.Errorf("failed to update state by constraints of template %s: %w", template.Raw, err)
+		}
+	}
+	if err := s.SetByAssignments(template.Assignments); err != nil {
+		return xerrors.Errorf("failed to update state by assignments of template %s: %w", template.Raw, err)
+	}
 	return nil
 }
 
@@ -126,6 +184,9 @@ func (s *State) Update(def *DefinitionWithAlias, pickedTemplate *Template, msg M
 	if err := s.SetByDef(def, msg); err != nil {
 		return err
 	}
+	if err := s.SetByTemplate(pickedTemplate); err != nil {
+		return err
+	}
 	s.AddPickedTemplate(def.ID, pickedTemplate)
 	return nil
 }
@@ -139,6 +200,49 @@ func (s *State) AddPickedTemplate(defID DefinitionID, template *Template) {
 	}
 }
 
+// Map returns a copy of the state values.
+func (s *State) Map() MessageMap {
+	return s.m.copy()
+}
+
+// data returns the state values as the data of templates.
+// Functions usually accept string, so values are passed as string instead of Message.
+// Values of namespaced types like "emoji.Good" are nested in maps of the namespaces, so templates refer them like {{.emoji.Good}}.
+func (s *State) data() interface{} {
+	data := make(map[string]string, len(s.m))
+	hasNamespace := false
+	for key, value := range s.m {
+		data[key] = string(value)
+		hasNamespace = hasNamespace || strings.Contains(key, NamespaceSeparator)
+	}
+	if !hasNamespace {
+		return data
+	}
+	return nestNamespaces(data)
+}
+
+// nestNamespaces returns the data whose namespaced keys are nested in maps of the namespaces.
+// If a key conflicts with a namespace, the namespace is preferred.
+func nestNamespaces(data map[string]string) map[string]interface{} {
+	nested := map[string]interface{}{}
+	for key, value := range data {
+		names := strings.Split(key, NamespaceSeparator)
+		m := nested
+		for _, name := range names[:len(names)-1] {
+			child, ok := m[name].(map[string]interface{})
+			if !ok {
+				child = map[string]interface{}{}
+				m[name] = child
+			}
+			m = child
+		}
+		if _, ok := m[names[len(names)-1]].(map[string]interface{}); !ok {
+			m[names[len(names)-1]] = value
+		}
+	}
+	return nested
+}
+
 func (s *State) Get(defType DefinitionType) (Message, bool) {
 	v, ok := s.m[string(defType)]
 	return v, ok
@@ -154,12 +258,13 @@ func (s *State) IsPickedTemplate(defID DefinitionID, template *Template) bool {
 
 func (s *State) Copy(order []DefinitionType) *State {
 	ns := NewState(s.m.copy())
-	pickedTemplates, err := s.pickedTemplates.copy(order)
-	if err != nil {
-		panic(err) // FIXME
-	}
-	ns.pickedTemplates = pickedTemplates
+	ns.pickedTemplates = s.pickedTemplates.copy()
 	ns.aliases = s.aliases.copy()
+	ns.rand = s.rand
+	ns.trace = s.trace
+	ns.history = s.history
+	ns.locales = s.locales
+	ns.resolvable = s.resolvable
 
 	return ns
 }
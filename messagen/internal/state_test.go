@@ -55,6 +55,26 @@ func TestState_SetByConstraint(t *testing.T) {
 			wantState: NewState(MessageMap{"K1": "V1"}),
 			wantErr:   false,
 		},
+		{
+			name: "referred value is set",
+			s:    NewState(MessageMap{"K1": "V1"}),
+			args: args{
+				constraint: newConstraintOrPanic("K2+", "$K1"),
+			},
+			want:      true,
+			wantState: NewState(MessageMap{"K1": "V1", "K2": "V1"}),
+			wantErr:   false,
+		},
+		{
+			name: "nothing is set if referred value does not exist",
+			s:    NewState(MessageMap{"K1": "V1"}),
+			args: args{
+				constraint: newConstraintOrPanic("K2+", "$K3"),
+			},
+			want:      false,
+			wantState: NewState(MessageMap{"K1": "V1"}),
+			wantErr:   false,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -108,3 +128,23 @@ func TestState_SetByConstraints(t *testing.T) {
 		})
 	}
 }
+
+func TestState_SetByDef_WithConstraintGroup(t *testing.T) {
+	def := newDefinitionWithAliasOrPanic(&RawDefinition{
+		Type:         "Greeting",
+		RawTemplates: []RawTemplate{"hi"},
+		RawConstraintGroups: map[string]*RawConstraintGroup{
+			"Morning": {Constraints: RawConstraints{"Time": "Morning", "Mood+": "Fresh"}},
+			"Night":   {Constraints: RawConstraints{"Time": "Night", "Mood+": "Sleepy"}},
+		},
+	}, "", nil)
+
+	s := NewState(MessageMap{"Time": "Night"})
+	if err := s.SetByDef(def, "hi"); err != nil {
+		t.Fatalf("unexpected error occurred in State.SetByDef(): %s", err)
+	}
+	want := NewState(MessageMap{"Time": "Night", "Greeting": "hi", "Mood": "Sleepy"})
+	if !reflect.DeepEqual(s.m, want.m) {
+		t.Errorf("State.SetByDef() state = %v, want %v", s.m, want.m)
+	}
+}
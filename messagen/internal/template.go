@@ -3,20 +3,82 @@ package internal
 import (
 	"bytes"
 	"math/rand"
-	"regexp"
 	"strings"
 	"text/template"
+	"text/template/parse"
 
 	"golang.org/x/xerrors"
 )
 
 type RawTemplate string
 
-func (r RawTemplate) extractDefRefTypeFromRawTemplate() (defTypes DefinitionTypes) {
-	re := regexp.MustCompile(`\{\{\.(.*?)\}\}`)
-	for _, match := range re.FindAllStringSubmatch(string(r), -1) {
-		defTypes = append(defTypes, DefinitionType(match[1]))
+// extractDefRefTypes returns definition types which are referred in the parsed template tree, in order of appearance.
+// References nested in pipelines and control structures like {{upper .Name}} or {{if .Name}} are also extracted.
+// In the body of range and with, dot is not the state, so only references through $ like {{$.Name}} are extracted.
+func extractDefRefTypes(node parse.Node, dotIsState bool) (defTypes DefinitionTypes) {
+	visitDefRefs(node, dotIsState, func(ident []string) []string {
+		defTypes = append(defTypes, DefinitionType(strings.Join(ident, NamespaceSeparator)))
+		return ident
+	})
+	return
+}
+
+// visitDefRefs calls visit with identifiers of each reference to a definition type in the parsed template tree, in order of appearance.
+// Identifiers are like ["Name"] of {{.Name}} or ["emoji", "Good"] of {{.emoji.Good}} and {{$.emoji.Good}},
+// and they are replaced with the returned identifiers.
+func visitDefRefs(node parse.Node, dotIsState bool, visit func(ident []string) []string) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			visitDefRefs(child, dotIsState, visit)
+		}
+	case *parse.ActionNode:
+		visitDefRefs(n.Pipe, dotIsState, visit)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, cmd := range n.Cmds {
+			visitDefRefs(cmd, dotIsState, visit)
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			visitDefRefs(arg, dotIsState, visit)
+		}
+	case *parse.FieldNode:
+		if dotIsState {
+			n.Ident = visit(n.Ident)
+		}
+	case *parse.VariableNode:
+		if n.Ident[0] == "$" && len(n.Ident) > 1 {
+			n.Ident = append([]string{"$"}, visit(n.Ident[1:])...)
+		}
+	case *parse.ChainNode:
+		visitDefRefs(n.Node, dotIsState, visit)
+	case *parse.IfNode:
+		visitDefRefsInBranch(&n.BranchNode, dotIsState, dotIsState, visit)
+	case *parse.RangeNode:
+		visitDefRefsInBranch(&n.BranchNode, dotIsState, false, visit)
+	case *parse.WithNode:
+		visitDefRefsInBranch(&n.BranchNode, dotIsState, false, visit)
+	case *parse.TemplateNode:
+		visitDefRefs(n.Pipe, dotIsState, visit)
 	}
+}
+
+func visitDefRefsInBranch(n *parse.BranchNode, dotIsState, dotIsStateInList bool, visit func(ident []string) []string) {
+	visitDefRefs(n.Pipe, dotIsState, visit)
+	visitDefRefs(n.List, dotIsStateInList, visit)
+	visitDefRefs(n.ElseList, dotIsState, visit)
+}
+
+func extractDefRefTypesFromBranch(n *parse.BranchNode, dotIsState, dotIsStateInList bool) (defTypes DefinitionTypes) {
+	defTypes = append(defTypes, extractDefRefTypes(n.Pipe, dotIsState)...)
+	defTypes = append(defTypes, extractDefRefTypes(n.List, dotIsStateInList)...)
+	defTypes = append(defTypes, extractDefRefTypes(n.ElseList, dotIsState)...)
 	return
 }
 
@@ -52,6 +114,15 @@ func (d *DefinitionTypes) sortByOrder(order DefinitionTypes) {
 	*d = append(defs, *d...)
 }
 
+func (d DefinitionTypes) has(defType DefinitionType) bool {
+	for _, t := range d {
+		if t == defType {
+			return true
+		}
+	}
+	return false
+}
+
 func (d *DefinitionTypes) copy() DefinitionTypes {
 	dst := make([]DefinitionType, len(*d))
 	copy(dst, *d)
@@ -59,30 +130,218 @@ func (d *DefinitionTypes) copy() DefinitionTypes {
 }
 
 type Template struct {
-	Raw     RawTemplate
+	Raw RawTemplate
+	// Depends is the definition types which are referred in all branches of the template.
 	Depends *DefinitionTypes
 	tmpl    *template.Template
+	funcs   *FuncSet
+	order   DefinitionTypes
+	// conditional is true if the template has if actions whose conditions or branches refer definition types.
+	conditional bool
+	// Weight is the relative probability that the template is picked by RandomTemplatePicker.
+	Weight DefinitionWeight
+	// Constraints must be satisfied to pick the template. It is nil if the template has no constraints.
+	Constraints *Constraints
+	// Assignments are applied to the state when the template is picked.
+	Assignments []*Assignment
+}
+
+// RawTemplateAttributes are optional attributes of a template.
+type RawTemplateAttributes struct {
+	// Weight is the relative probability that the template is picked. If it is 0, 1 is used.
+	Weight DefinitionWeight
+	// Constraints and ValueSets must be satisfied to pick the template.
+	// Constraints with + set values to the state when the template is picked, like constraints of definitions.
+	Constraints RawConstraints
+	ValueSets   RawValueSetConstraints
+	// Sets are state assignments which are applied when the template is picked.
+	Sets RawAssignments
+}
+
+// setAttributes sets the attributes to the template.
+func (t *Template) setAttributes(attrs *RawTemplateAttributes, funcs *FuncSet) error {
+	if attrs.Weight < 0 {
+		return xerrors.Errorf("weight of template must not be negative: %s", t.Raw)
+	}
+	if attrs.Weight > 0 {
+		t.Weight = attrs.Weight
+	}
+	if len(attrs.Constraints) > 0 || len(attrs.ValueSets) > 0 {
+		constraints, err := NewConstraintsWithValueSets(attrs.Constraints, attrs.ValueSets)
+		if err != nil {
+			return xerrors.Errorf("failed to set constraints to template %s: %w", t.Raw, err)
+		}
+		t.Constraints = constraints
+	}
+	assignments, err := NewAssignments(attrs.Sets, funcs)
+	if err != nil {
+		return xerrors.Errorf("failed to set assignments to template %s: %w", t.Raw, err)
+	}
+	t.Assignments = assignments
+	return nil
 }
 
+// CanBePicked reports whether constraints of the template are satisfied by the state.
+func (t *Template) CanBePicked(state *State) (bool, error) {
+	if t.Constraints == nil {
+		return true, nil
+	}
+	return t.Constraints.AreSatisfied(state)
+}
+
+// NewTemplate returns a template which can use built-in functions.
 func NewTemplate(rawTemplate RawTemplate, order []DefinitionType) (*Template, error) {
-	defTypes := rawTemplate.extractDefRefTypeFromRawTemplate()
-	tmpl, err := template.New(string(rawTemplate)).Parse(string(rawTemplate))
+	return NewTemplateWithFuncs(rawTemplate, order, nil)
+}
+
+// NewTemplateWithFuncs returns a template which can use funcs. If funcs is nil, built-in functions are used.
+func NewTemplateWithFuncs(rawTemplate RawTemplate, order []DefinitionType, funcs *FuncSet) (*Template, error) {
+	if funcs == nil {
+		funcs = builtinFuncSet
+	}
+	tmpl, err := funcs.parse(rawTemplate)
 	if err != nil {
 		return nil, xerrors.Errorf("failed to create new template: %w", err)
 	}
 
+	defTypes := extractDefRefTypes(tmpl.Tree.Root, true)
 	defTypes.sortByOrder(order)
 
+	return &Template{
+		Raw:         rawTemplate,
+		Depends:     &defTypes,
+		tmpl:        tmpl,
+		funcs:       funcs,
+		order:       order,
+		conditional: hasConditionalRefs(tmpl.Tree.Root),
+		Weight:      1,
+	}, err
+}
+
+// newValueTemplate returns a template which generates the value as it is.
+// The parsed template is not cached because values are usually different for each generation.
+func newValueTemplate(value Message, funcs *FuncSet) (*Template, error) {
+	if funcs == nil {
+		funcs = builtinFuncSet
+	}
+	rawTemplate := escapeTemplate(string(value))
+	tmpl, err := template.New(string(rawTemplate)).Funcs(funcs.funcs).Parse(string(rawTemplate))
+	if err != nil {
+		return nil, xerrors.Errorf("failed to create new template: %w", err)
+	}
 	return &Template{
 		Raw:     rawTemplate,
-		Depends: &defTypes,
+		Depends: &DefinitionTypes{},
 		tmpl:    tmpl,
-	}, err
+		funcs:   funcs,
+		Weight:  1,
+	}, nil
+}
+
+// hasConditionalRefs reports whether the node has if actions whose conditions or branches refer definition types.
+func hasConditionalRefs(node parse.Node) bool {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return false
+		}
+		for _, child := range n.Nodes {
+			if hasConditionalRefs(child) {
+				return true
+			}
+		}
+	case *parse.IfNode:
+		return len(extractDefRefTypes(n.Pipe, true)) > 0 || len(extractDefRefTypes(n.List, true)) > 0 || len(extractDefRefTypes(n.ElseList, true)) > 0
+	}
+	return false
+}
+
+// conditionDepends returns definition types which are referred in conditions of if actions.
+// Values of them decide which branches are taken.
+func (t *Template) conditionDepends() DefinitionTypes {
+	return extractConditionRefTypes(t.tmpl.Tree.Root)
+}
+
+func extractConditionRefTypes(node parse.Node) (defTypes DefinitionTypes) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			defTypes = append(defTypes, extractConditionRefTypes(child)...)
+		}
+	case *parse.IfNode:
+		defTypes = append(defTypes, extractDefRefTypes(n.Pipe, true)...)
+		defTypes = append(defTypes, extractConditionRefTypes(n.List)...)
+		defTypes = append(defTypes, extractConditionRefTypes(n.ElseList)...)
+	}
+	return
+}
+
+// activeDepends returns definition types which are referred in the branches taken with the state.
+// If the condition of an if action refers definition types which are not in the state yet,
+// the condition's types are returned instead of the types in its branches.
+// Types in the condition which can not be resolved are regarded as unset, so they are false in the condition.
+func (t *Template) activeDepends(state *State) DefinitionTypes {
+	defTypes := t.extractActiveDefRefTypes(t.tmpl.Tree.Root, state)
+	defTypes.sortByOrder(t.order)
+	return defTypes
+}
+
+func (t *Template) extractActiveDefRefTypes(node parse.Node, state *State) (defTypes DefinitionTypes) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			defTypes = append(defTypes, t.extractActiveDefRefTypes(child, state)...)
+		}
+	case *parse.IfNode:
+		pending := false
+		for _, defType := range extractDefRefTypes(n.Pipe, true) {
+			_, ok := state.Get(defType)
+			if !ok && !state.canResolve(defType) {
+				continue
+			}
+			defTypes = append(defTypes, defType)
+			pending = pending || !ok
+		}
+		if pending {
+			return
+		}
+		taken, err := t.evalCondition(n.Pipe, state)
+		if err != nil {
+			// the error is reported when the template is executed
+			return append(defTypes, extractDefRefTypesFromBranch(&n.BranchNode, true, true)...)
+		}
+		if taken {
+			return append(defTypes, t.extractActiveDefRefTypes(n.List, state)...)
+		}
+		return append(defTypes, t.extractActiveDefRefTypes(n.ElseList, state)...)
+	default:
+		defTypes = extractDefRefTypes(n, true)
+	}
+	return
+}
+
+// evalCondition reports whether the if action with the pipeline takes its first branch with the state.
+func (t *Template) evalCondition(pipe *parse.PipeNode, state *State) (bool, error) {
+	tmpl, err := t.funcs.parse(RawTemplate("{{if " + pipe.String() + "}}1{{end}}"))
+	if err != nil {
+		return false, err
+	}
+	buf := &bytes.Buffer{}
+	if err := tmpl.Execute(buf, state.data()); err != nil {
+		return false, err
+	}
+	return buf.String() == "1", nil
 }
 
 func (t *Template) Execute(state *State) (Message, error) {
 	buf := &bytes.Buffer{}
-	if err := t.tmpl.Execute(buf, state.m); err != nil {
+	if err := t.tmpl.Execute(buf, state.data()); err != nil {
 		return "", xerrors.Errorf("failed to execute template. template:%s  state:%#v : %w", t.Raw, state, err)
 	}
 	return Message(buf.String()), nil
@@ -110,26 +369,15 @@ func (t *Template) ExecuteWithIncompleteState(state *State) (Message, []Definiti
 	return msg, incompleteDefTypes, nil
 }
 
+// toChunks splits the template into templates of top-level nodes of the parsed tree.
 func (t *Template) toChunks() (Templates, error) {
 	var newTemplates Templates
-	chunkRawTemplates := strings.Split(string(t.Raw), "}}")
-	for _, chunkRawTemplate := range chunkRawTemplates {
-		chunks := strings.Split(chunkRawTemplate, "{{")
-		tmpl1, err := NewTemplate(RawTemplate(chunks[0]), nil)
+	for _, node := range t.tmpl.Tree.Root.Nodes {
+		chunk, err := NewTemplateWithFuncs(RawTemplate(node.String()), nil, t.funcs)
 		if err != nil {
 			return nil, err
 		}
-		newTemplates = append(newTemplates, tmpl1)
-
-		if len(chunks) != 2 {
-			continue
-		}
-
-		tmpl2, err := NewTemplate("{{"+RawTemplate(chunks[1])+"}}", nil)
-		if err != nil {
-			return nil, err
-		}
-		newTemplates = append(newTemplates, tmpl2)
+		newTemplates = append(newTemplates, chunk)
 	}
 	return newTemplates, nil
 }
@@ -139,8 +387,9 @@ func (t *Template) IsSatisfiedState(state *State) bool {
 	return !ok
 }
 
+// GetFirstUnsatisfiedDef returns the first definition type which is referred in the taken branches but not in the state.
 func (t *Template) GetFirstUnsatisfiedDef(state *State) (DefinitionType, bool) {
-	for _, defType := range *t.Depends {
+	for _, defType := range t.dependsWithState(state) {
 		if _, ok := state.Get(defType); ok {
 			continue
 		}
@@ -149,6 +398,40 @@ func (t *Template) GetFirstUnsatisfiedDef(state *State) (DefinitionType, bool) {
 	return "", false
 }
 
+// dependsWithState returns the definition types which are referred in the branches taken with the state.
+func (t *Template) dependsWithState(state *State) DefinitionTypes {
+	if t.conditional {
+		return t.activeDepends(state)
+	}
+	return *t.Depends
+}
+
+func (t *Template) copy(order []DefinitionType) *Template {
+	depends := t.Depends.copy()
+	depends.sortByOrder(order)
+	return &Template{
+		Raw:         t.Raw,
+		Depends:     &depends,
+		tmpl:        t.tmpl,
+		funcs:       t.funcs,
+		order:       order,
+		conditional: t.conditional,
+		Weight:      t.Weight,
+		Constraints: t.Constraints,
+		Assignments: t.Assignments,
+	}
+}
+
+// IsPlainText reports whether the template has no actions, so the raw template is the message as it is.
+func (t *Template) IsPlainText() bool {
+	for _, node := range t.tmpl.Tree.Root.Nodes {
+		if node.Type() != parse.NodeText {
+			return false
+		}
+	}
+	return true
+}
+
 func (t *Template) Equals(template *Template) bool {
 	return t.Raw == template.Raw
 }
@@ -156,9 +439,14 @@ func (t *Template) Equals(template *Template) bool {
 type Templates []*Template
 
 func NewTemplates(rawTemplates []RawTemplate, order []DefinitionType) (Templates, error) {
+	return NewTemplatesWithFuncs(rawTemplates, order, nil)
+}
+
+// NewTemplatesWithFuncs returns templates which can use funcs. If funcs is nil, built-in functions are used.
+func NewTemplatesWithFuncs(rawTemplates []RawTemplate, order []DefinitionType, funcs *FuncSet) (Templates, error) {
 	var templates []*Template
 	for _, rawTemplate := range rawTemplates {
-		t, err := NewTemplate(rawTemplate, order)
+		t, err := NewTemplateWithFuncs(rawTemplate, order, funcs)
 		if err != nil {
 			return nil, xerrors.Errorf("failed to create Templates: %w", err)
 		}
@@ -169,15 +457,48 @@ func NewTemplates(rawTemplates []RawTemplate, order []DefinitionType) (Templates
 }
 
 func (t *Templates) PopRandom() (*Template, bool) {
+	return t.PopRandomWith(globalRand)
+}
+
+// PopRandomWith pops a template at random by r.
+func (t *Templates) PopRandomWith(r *rand.Rand) (*Template, bool) {
+	if len(*t) == 0 {
+		return nil, false
+	}
+	i := r.Intn(len(*t))
+	tmpl := (*t)[i]
+	t.DeleteByIndex(i)
+	return tmpl, true
+}
+
+// PopRandomWithWeight pops a template at random by r according to weights of the templates.
+func (t *Templates) PopRandomWithWeight(r *rand.Rand) (*Template, bool) {
 	if len(*t) == 0 {
 		return nil, false
 	}
-	i := rand.Intn(len(*t))
+	// templates without weights are picked as before, so that the same seed generates the same messages
+	if t.hasUniformWeights() {
+		return t.PopRandomWith(r)
+	}
+	var weights []DefinitionWeight
+	for _, tmpl := range *t {
+		weights = append(weights, tmpl.Weight)
+	}
+	i := pickDefinitionIndexRandomWithWeight(r, weights)
 	tmpl := (*t)[i]
 	t.DeleteByIndex(i)
 	return tmpl, true
 }
 
+func (t *Templates) hasUniformWeights() bool {
+	for _, tmpl := range *t {
+		if tmpl.Weight != (*t)[0].Weight {
+			return false
+		}
+	}
+	return true
+}
+
 func (t *Templates) DeleteByIndex(i int) {
 	if i == 0 {
 		*t = (*t)[1:]
@@ -214,10 +535,12 @@ func (t *Templates) Add(template *Template) {
 	*t = append(*t, template)
 }
 
+// Copy returns new templates whose dependencies are sorted by order.
+// Parsed templates are shared between the original and the copy because they are never modified.
 func (t *Templates) Copy(order []DefinitionType) (Templates, error) {
-	var newRawTemplates []RawTemplate
+	var newTemplates Templates
 	for _, tmpl := range *t {
-		newRawTemplates = append(newRawTemplates, tmpl.Raw)
+		newTemplates = append(newTemplates, tmpl.copy(order))
 	}
-	return NewTemplates(newRawTemplates, order)
+	return newTemplates, nil
 }
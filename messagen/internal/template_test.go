@@ -22,7 +22,7 @@ func newTemplatesOrPanic(order []DefinitionType, rawTemplates ...RawTemplate) Te
 	return templates
 }
 
-func TestRawTemplate_extractDefRefIDFromRawTemplate(t *testing.T) {
+func Test_extractDefRefTypes(t *testing.T) {
 	tests := []struct {
 		name            string
 		r               RawTemplate
@@ -33,11 +33,30 @@ func TestRawTemplate_extractDefRefIDFromRawTemplate(t *testing.T) {
 			r:               "{{.id1}}test{{.id2}}",
 			wantDefRefTypes: DefinitionTypes{"id1", "id2"},
 		},
+		{
+			name:            "should extract RefID nested in pipelines",
+			r:               `{{upper .id1}}{{.id2 | replace "a" "b"}}{{printf "%s-%s" .id3 (lower .id4)}}`,
+			wantDefRefTypes: DefinitionTypes{"id1", "id2", "id3", "id4"},
+		},
+		{
+			name:            "should extract RefID in control structures",
+			r:               "{{if .id1}}{{.id2}}{{else}}{{.id3}}{{end}}",
+			wantDefRefTypes: DefinitionTypes{"id1", "id2", "id3"},
+		},
+		{
+			name:            "should not extract fields of dot in with",
+			r:               "{{with .id1}}{{.Field}}{{$.id2}}{{end}}",
+			wantDefRefTypes: DefinitionTypes{"id1", "id2"},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if gotDefRefTypes := tt.r.extractDefRefTypeFromRawTemplate(); !reflect.DeepEqual(gotDefRefTypes, tt.wantDefRefTypes) {
-				t.Errorf("RawTemplate.extractDefRefTypeFromRawTemplate() = %v, want %v", gotDefRefTypes, tt.wantDefRefTypes)
+			tmpl, err := template.New("").Funcs(BuiltinFuncs()).Parse(string(tt.r))
+			if err != nil {
+				t.Fatalf("failed to parse template: %s", err)
+			}
+			if gotDefRefTypes := extractDefRefTypes(tmpl.Tree.Root, true); !reflect.DeepEqual(gotDefRefTypes, tt.wantDefRefTypes) {
+				t.Errorf("extractDefRefTypes() = %v, want %v", gotDefRefTypes, tt.wantDefRefTypes)
 			}
 		})
 	}
@@ -197,6 +216,79 @@ func TestTemplate_Execute(t *testing.T) {
 	}
 }
 
+func TestTemplate_GetFirstUnsatisfiedDef(t *testing.T) {
+	tests := []struct {
+		name   string
+		raw    RawTemplate
+		order  []DefinitionType
+		state  *State
+		want   DefinitionType
+		wantOk bool
+	}{
+		{
+			name:   "returns first type which is not in state",
+			raw:    "{{.A}}{{.B}}",
+			state:  NewState(MessageMap{"A": "a"}),
+			want:   "B",
+			wantOk: true,
+		},
+		{
+			name:   "returns condition type before branch types",
+			raw:    "{{if .A}}{{.B}}{{else}}{{.C}}{{end}}",
+			state:  NewState(nil),
+			want:   "A",
+			wantOk: true,
+		},
+		{
+			name:   "returns type in taken branch",
+			raw:    "{{if .A}}{{.B}}{{else}}{{.C}}{{end}}",
+			state:  NewState(MessageMap{"A": "a"}),
+			want:   "B",
+			wantOk: true,
+		},
+		{
+			name:   "returns type in else branch if condition value is empty",
+			raw:    "{{if .A}}{{.B}}{{else}}{{.C}}{{end}}",
+			state:  NewState(MessageMap{"A": ""}),
+			want:   "C",
+			wantOk: true,
+		},
+		{
+			name:   "evaluates condition with functions",
+			raw:    `{{if eq .Mode "formal"}}{{.Title}}{{else if eq .Mode "casual"}}{{.Nickname}}{{end}}`,
+			state:  NewState(MessageMap{"Mode": "casual"}),
+			want:   "Nickname",
+			wantOk: true,
+		},
+		{
+			name:   "is satisfied if types in not taken branch are not in state",
+			raw:    "{{if .A}}{{.B}}{{else}}{{.C}}{{end}}",
+			state:  NewState(MessageMap{"A": "a", "B": "b"}),
+			wantOk: false,
+		},
+		{
+			name:   "types in taken branch are sorted by order",
+			raw:    "{{if .A}}{{.B}}{{.C}}{{end}}",
+			order:  []DefinitionType{"C"},
+			state:  NewState(MessageMap{"A": "a"}),
+			want:   "C",
+			wantOk: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			template, err := NewTemplate(tt.raw, tt.order)
+			if err != nil {
+				t.Fatalf("failed to create new template: error = %v", err)
+			}
+			got, ok := template.GetFirstUnsatisfiedDef(tt.state)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("Template.GetFirstUnsatisfiedDef() = (%v, %v), want (%v, %v)", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
 func TestTemplates_DeleteByIndex(t *testing.T) {
 	type args struct {
 		i int
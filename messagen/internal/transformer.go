@@ -0,0 +1,135 @@
+package internal
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/xerrors"
+)
+
+// MessageTransformer post-processes a generated message like trimming spaces.
+type MessageTransformer = func(msg string) (string, error)
+
+// BuiltinTransformers returns built-in transformers by name.
+func BuiltinTransformers() map[string]MessageTransformer {
+	return map[string]MessageTransformer{
+		"trim":                  TrimTransformer,
+		"collapse-space":        CollapseSpaceTransformer,
+		"capitalize":            CapitalizeTransformer,
+		"article":               ArticleTransformer,
+		"normalize-punctuation": NormalizePunctuationTransformer,
+	}
+}
+
+// TrimTransformer removes leading and trailing white spaces.
+func TrimTransformer(msg string) (string, error) {
+	return strings.TrimSpace(msg), nil
+}
+
+var repeatedSpaceRegExp = regexp.MustCompile(`[^\S\n]{2,}`)
+
+// CollapseSpaceTransformer replaces repeated white spaces in a line with a single space. Line breaks are kept.
+func CollapseSpaceTransformer(msg string) (string, error) {
+	return repeatedSpaceRegExp.ReplaceAllString(msg, " "), nil
+}
+
+var sentenceStartRegExp = regexp.MustCompile(`(^\s*|[.!?]\s+)\pL`)
+
+// CapitalizeTransformer converts the first letter of each sentence to upper case.
+// A sentence starts at the beginning of the message or after ".", "!" or "?" which is followed by white spaces.
+func CapitalizeTransformer(msg string) (string, error) {
+	return sentenceStartRegExp.ReplaceAllStringFunc(msg, func(match string) string {
+		letter, size := utf8.DecodeLastRuneInString(match)
+		return match[:len(match)-size] + string(unicode.ToUpper(letter))
+	}), nil
+}
+
+var articleRegExp = regexp.MustCompile(`\b(an?|An?)\s+([A-Za-z]+)`)
+
+// ArticleTransformer fixes "a" and "an" by the sound of the following word like "an hour" and "a unicorn".
+// "A" and "An" are fixed only at the start of a sentence, so that words like "Plan A is ready" are kept.
+func ArticleTransformer(msg string) (string, error) {
+	var fixed strings.Builder
+	last := 0
+	for _, match := range articleRegExp.FindAllStringSubmatchIndex(msg, -1) {
+		articleStart, articleEnd := match[2], match[3]
+		article, word := msg[articleStart:articleEnd], msg[match[4]:match[5]]
+		if article[0] == 'A' && !isSentenceEnd(msg[:articleStart]) {
+			continue
+		}
+		newArticle := "a"
+		if startsWithVowelSound(word) {
+			newArticle = "an"
+		}
+		if article[0] == 'A' {
+			newArticle = "A" + newArticle[1:]
+		}
+		fixed.WriteString(msg[last:articleStart])
+		fixed.WriteString(newArticle)
+		last = articleEnd
+	}
+	fixed.WriteString(msg[last:])
+	return fixed.String(), nil
+}
+
+// isSentenceEnd reports whether a new sentence starts after s, that is, s is empty or ends with ".", "!" or "?" and white spaces.
+func isSentenceEnd(s string) bool {
+	s = strings.TrimRightFunc(s, unicode.IsSpace)
+	return s == "" || strings.ContainsAny(s[len(s)-1:], ".!?")
+}
+
+// NormalizePunctuationTransformer converts full-width punctuation like "！" and "？" to half-width one like "!" and "?".
+// Full-width letters and digits are kept.
+func NormalizePunctuationTransformer(msg string) (string, error) {
+	return strings.Map(func(r rune) rune {
+		if r < '！' || r > '～' {
+			return r
+		}
+		halfWidth := r - '！' + '!'
+		if unicode.IsLetter(halfWidth) || unicode.IsDigit(halfWidth) {
+			return r
+		}
+		return halfWidth
+	}, msg), nil
+}
+
+// transform applies the transformers to the message in order.
+func transform(msg Message, transformers []MessageTransformer) (Message, error) {
+	s := string(msg)
+	for _, transformer := range transformers {
+		var err error
+		if s, err = transformer(s); err != nil {
+			return "", xerrors.Errorf("failed to transform message %q: %w", msg, err)
+		}
+	}
+	return Message(s), nil
+}
+
+// transformers returns transformers which are applied to messages of the definition.
+// Transformers of the definition type are applied first, then root transformers if the definition is the root of the search.
+func (d *DefinitionRepository) transformers(def *DefinitionWithAlias, path []DefinitionType) []MessageTransformer {
+	transformers := d.typeTransformers[def.Type]
+	if len(path) == 1 && len(d.rootTransformers) > 0 {
+		transformers = append(transformers[:len(transformers):len(transformers)], d.rootTransformers...)
+	}
+	return transformers
+}
+
+// transformedTemplate returns the template which validators validate.
+// If the definition has transformers, it is the template of the transformed message, so validators see the real output.
+func (d *DefinitionRepository) transformedTemplate(def *DefinitionWithAlias, template *Template, state *State, path []DefinitionType) (*Template, error) {
+	transformers := d.transformers(def, path)
+	if len(transformers) == 0 || len(d.templateValidators) == 0 {
+		return template, nil
+	}
+	msg, _, err := template.ExecuteWithIncompleteState(state)
+	if err != nil {
+		return nil, err
+	}
+	if msg, err = transform(msg, transformers); err != nil {
+		return nil, err
+	}
+	return newValueTemplate(msg, d.funcs)
+}
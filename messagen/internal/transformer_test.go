@@ -0,0 +1,112 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBuiltinTransformers(t *testing.T) {
+	tests := []struct {
+		name        string
+		transformer string
+		msg         string
+		want        string
+	}{
+		{name: "trim", transformer: "trim", msg: "  Hello world. \n", want: "Hello world."},
+		{name: "collapse-space", transformer: "collapse-space", msg: "Hello  \t world.\n\nBye   .", want: "Hello world.\n\nBye ."},
+		{name: "capitalize", transformer: "capitalize", msg: "hello. how are you?  fine! ok", want: "Hello. How are you?  Fine! Ok"},
+		{name: "capitalize does not change words after abbreviation without space", transformer: "capitalize", msg: "e.g.test", want: "E.g.test"},
+		{name: "article a to an", transformer: "article", msg: "a apple. A orange", want: "an apple. An orange"},
+		{name: "article an to a", transformer: "article", msg: "An banana and an cat", want: "A banana and a cat"},
+		{name: "article does not change words which contain a", transformer: "article", msg: "banana apple", want: "banana apple"},
+		{name: "article does not change capital A in a sentence", transformer: "article", msg: "Plan A is ready", want: "Plan A is ready"},
+		{name: "article an before silent h", transformer: "article", msg: "a hour and a honest man", want: "an hour and an honest man"},
+		{name: "article keeps an before silent h", transformer: "article", msg: "an hour", want: "an hour"},
+		{name: "article a before vowel letter with consonant sound", transformer: "article", msg: "an unicorn and an European", want: "a unicorn and a European"},
+		{name: "article keeps a before vowel letter with consonant sound", transformer: "article", msg: "a unicorn", want: "a unicorn"},
+		{name: "article at the start of a sentence", transformer: "article", msg: "It is. A owl! An cat", want: "It is. An owl! A cat"},
+		{name: "article is not changed if it is not followed by a word", transformer: "article", msg: "a 1 and a", want: "a 1 and a"},
+		{name: "normalize-punctuation", transformer: "normalize-punctuation", msg: "Ｈｉ！ 元気？（１２３）", want: "Ｈｉ! 元気?(１２３)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transformer, ok := BuiltinTransformers()[tt.transformer]
+			if !ok {
+				t.Fatalf("transformer %s does not exist", tt.transformer)
+			}
+			got, err := transformer(tt.msg)
+			if err != nil {
+				t.Fatalf("unexpected error occurred in transformer: %s", err)
+			}
+			if got != tt.want {
+				t.Errorf("%s transformer = %q, want %q", tt.transformer, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDefinitionRepository_Generate_WithTransformers(t *testing.T) {
+	defs := []*RawDefinition{
+		{Type: "Test", RawTemplates: []RawTemplate{"  {{.Fruit}}  is   {{.Taste}}.  "}},
+		{Type: "Fruit", RawTemplates: []RawTemplate{"a apple"}},
+		{Type: "Taste", RawTemplates: []RawTemplate{"SWEET"}},
+	}
+	lower := func(msg string) (string, error) { return strings.ToLower(msg), nil }
+
+	tests := []struct {
+		name    string
+		opt     *DefinitionRepositoryOption
+		want    Message
+		wantErr bool
+	}{
+		{
+			name: "root transformers are applied to the root message",
+			opt: &DefinitionRepositoryOption{
+				RootTransformers: []MessageTransformer{TrimTransformer, CollapseSpaceTransformer, CapitalizeTransformer},
+			},
+			want: "A apple is SWEET.",
+		},
+		{
+			name: "type transformers are applied to messages of the type before root transformers",
+			opt: &DefinitionRepositoryOption{
+				RootTransformers: []MessageTransformer{TrimTransformer, CollapseSpaceTransformer, CapitalizeTransformer},
+				TypeTransformers: map[DefinitionType][]MessageTransformer{"Fruit": {ArticleTransformer}, "Taste": {lower}},
+			},
+			want: "An apple is sweet.",
+		},
+		{
+			name: "validators see transformed messages",
+			opt: &DefinitionRepositoryOption{
+				TemplateValidators: []TemplateValidator{MaxStrLenValidator(17)},
+				RootTransformers:   []MessageTransformer{TrimTransformer, CollapseSpaceTransformer},
+			},
+			want: "a apple is SWEET.",
+		},
+		{
+			name: "validators reject transformed messages",
+			opt: &DefinitionRepositoryOption{
+				TemplateValidators: []TemplateValidator{MaxStrLenValidator(16)},
+				RootTransformers:   []MessageTransformer{TrimTransformer, CollapseSpaceTransformer},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewDefinitionRepository(tt.opt)
+			if err := d.Add(defs...); err != nil {
+				t.Fatalf("unexpected error occurred in DefinitionRepository.Add(): %s", err)
+			}
+			got, err := d.Generate("Test", nil, 1)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DefinitionRepository.Generate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got[0] != tt.want {
+				t.Errorf("DefinitionRepository.Generate() = %q, want %q", got[0], tt.want)
+			}
+		})
+	}
+}
@@ -2,22 +2,188 @@
 package messagen
 
 import (
+	"context"
+	"math/big"
+	"math/rand"
+	"text/template"
+	"time"
+
 	"github.com/mpppk/messagen/messagen/internal"
+	"golang.org/x/xerrors"
 )
 
 type Definition struct {
-	Type           string            `yaml:"Type"`
-	Templates      []string          `yaml:"Templates"`
-	Constraints    map[string]string `yaml:"Constraints"`
-	Aliases        map[string]*Alias `yaml:"Aliases"`
-	AllowDuplicate bool              `yaml:"AllowDuplicate"`
-	Order          []string          `yaml:"Order"`
-	Weight         float32           `yaml:"Weight"`
+	Type           string            `yaml:"Type,omitempty"`
+	Templates      []string          `yaml:"Templates,omitempty"`
+	Constraints    map[string]string `yaml:"Constraints,omitempty"`
+	Aliases        map[string]*Alias `yaml:"Aliases,omitempty"`
+	AllowDuplicate bool              `yaml:"AllowDuplicate,omitempty"`
+	Order          []string          `yaml:"Order,omitempty"`
+	Weight         float32           `yaml:"Weight,omitempty"`
+	// Generator generates templates instead of Templates.
+	Generator *Generator `yaml:"Generator,omitempty"`
+	// ValueSetConstraints are constraints which are satisfied if the state value matches any of the values.
+	// In YAML, they are written as lists in Constraints like {Season: [Spring, Summer]}.
+	ValueSetConstraints map[string][]string `yaml:"-"`
+	// ConstraintGroups are named constraint sets.
+	// If any group exists, the definition can be picked only if at least one of the groups is satisfied in addition to Constraints.
+	// Constraints with + of the first satisfied group in name order set values to the state.
+	ConstraintGroups map[string]*ConstraintGroup `yaml:"ConstraintGroups,omitempty"`
+	// Sets are state assignments which are applied when the definition is picked, like {Gender: Female}.
+	// Values are templates which are executed with the state after the value of the definition is set.
+	// Keys overwrite state values, and keys with ? like "Formality?" are assigned only if the state does not have the key.
+	Sets map[string]string `yaml:"Sets,omitempty"`
+	// TemplateAttributes are optional attributes of Templates with the same index. nil means default attributes.
+	// In YAML, templates with attributes are written as objects like {Text: "...", Weight: 0.2, Constraints: {...}, Sets: {...}}.
+	TemplateAttributes []*TemplateAttributes `yaml:"-"`
+	// Locale is the locale of the definition like "ja" or "en". Definitions without locale are used in all locales.
+	Locale string `yaml:"Locale,omitempty"`
+}
+
+// TemplateAttributes are optional attributes of a template.
+type TemplateAttributes struct {
+	// Weight is the relative probability that the template is picked. If it is 0, 1 is used.
+	Weight float32
+	// Constraints must be satisfied to pick the template like Constraints of Definition.
+	Constraints         map[string]string
+	ValueSetConstraints map[string][]string
+	// Sets are state assignments which are applied when the template is picked like Sets of Definition.
+	Sets map[string]string
+}
+
+func newRawTemplateAttributes(attrs []*TemplateAttributes) []*internal.RawTemplateAttributes {
+	var rawAttrs []*internal.RawTemplateAttributes
+	for _, attr := range attrs {
+		if attr == nil {
+			rawAttrs = append(rawAttrs, nil)
+			continue
+		}
+		rawAttrs = append(rawAttrs, &internal.RawTemplateAttributes{
+			Weight:      internal.DefinitionWeight(attr.Weight),
+			Constraints: newRawConstraints(attr.Constraints),
+			ValueSets:   newRawValueSetConstraints(attr.ValueSetConstraints),
+			Sets:        newRawAssignments(attr.Sets),
+		})
+	}
+	return rawAttrs
+}
+
+// ConstraintGroup is a set of constraints which are satisfied together.
+// In YAML, it is written as a map like Constraints of Definition.
+type ConstraintGroup struct {
+	Constraints         map[string]string
+	ValueSetConstraints map[string][]string
+}
+
+func newRawConstraints(constraints map[string]string) internal.RawConstraints {
+	rawConstraints := internal.RawConstraints{}
+	for key, value := range constraints {
+		rawConstraints[internal.RawConstraintKey(key)] = internal.RawConstraintValue(value)
+	}
+	return rawConstraints
+}
+
+func newRawValueSetConstraints(valueSets map[string][]string) internal.RawValueSetConstraints {
+	if len(valueSets) == 0 {
+		return nil
+	}
+	rawValueSets := internal.RawValueSetConstraints{}
+	for key, values := range valueSets {
+		var rawValues []internal.RawConstraintValue
+		for _, value := range values {
+			rawValues = append(rawValues, internal.RawConstraintValue(value))
+		}
+		rawValueSets[internal.RawConstraintKey(key)] = rawValues
+	}
+	return rawValueSets
+}
+
+func newRawConstraintGroups(groups map[string]*ConstraintGroup) map[string]*internal.RawConstraintGroup {
+	if len(groups) == 0 {
+		return nil
+	}
+	rawGroups := map[string]*internal.RawConstraintGroup{}
+	for name, group := range groups {
+		rawGroups[name] = &internal.RawConstraintGroup{
+			Constraints: newRawConstraints(group.Constraints),
+			ValueSets:   newRawValueSetConstraints(group.ValueSetConstraints),
+		}
+	}
+	return rawGroups
+}
+
+func newRawAssignments(sets map[string]string) internal.RawAssignments {
+	if len(sets) == 0 {
+		return nil
+	}
+	rawAssignments := internal.RawAssignments{}
+	for key, value := range sets {
+		rawAssignments[internal.RawAssignmentKey(key)] = internal.RawTemplate(value)
+	}
+	return rawAssignments
+}
+
+// Generator generates values of a definition like integer ranges, digits, dates and characters.
+// Exactly one of the fields must be set.
+type Generator struct {
+	// Int generates integers from Min to Max like "1", "2", ..., "999".
+	Int *IntGenerator `yaml:"Int,omitempty"`
+	// Digits generates zero-padded digits like "000", "001", ..., "999".
+	Digits *DigitsGenerator `yaml:"Digits,omitempty"`
+	// Date generates dates from From to To day by day.
+	Date *DateGenerator `yaml:"Date,omitempty"`
+	// Chars generates strings of characters which are chosen from a character class like "A-Z".
+	Chars *CharsGenerator `yaml:"Chars,omitempty"`
+}
+
+type IntGenerator struct {
+	Min int `yaml:"Min"`
+	Max int `yaml:"Max"`
+	// Step is 1 if it is omitted.
+	Step int `yaml:"Step"`
+}
+
+type DigitsGenerator struct {
+	Length int `yaml:"Length"`
+}
+
+type DateGenerator struct {
+	// From and To are dates like "2006-01-02".
+	From string `yaml:"From"`
+	To   string `yaml:"To"`
+	// Layout is the golang time layout of generated dates. If it is omitted, "2006-01-02" is used.
+	Layout string `yaml:"Layout"`
+}
+
+type CharsGenerator struct {
+	Class string `yaml:"Class"`
+	// Length is 1 if it is omitted.
+	Length int `yaml:"Length"`
+}
+
+func (g *Generator) toGenerator() *internal.Generator {
+	if g == nil {
+		return nil
+	}
+	generator := &internal.Generator{}
+	if g.Int != nil {
+		generator.Int = &internal.IntGenerator{Min: g.Int.Min, Max: g.Int.Max, Step: g.Int.Step}
+	}
+	if g.Digits != nil {
+		generator.Digits = &internal.DigitsGenerator{Length: g.Digits.Length}
+	}
+	if g.Date != nil {
+		generator.Date = &internal.DateGenerator{From: g.Date.From, To: g.Date.To, Layout: g.Date.Layout}
+	}
+	if g.Chars != nil {
+		generator.Chars = &internal.CharsGenerator{Class: g.Chars.Class, Length: g.Chars.Length}
+	}
+	return generator
 }
 
 type Alias struct {
-	Type           string `yaml:"Type"`
-	AllowDuplicate bool   `yaml:"AllowDuplicate"`
+	Type           string `yaml:"Type,omitempty"`
+	AllowDuplicate bool   `yaml:"AllowDuplicate,omitempty"`
 }
 
 func (a *Alias) toAlias() *internal.Alias {
@@ -40,19 +206,21 @@ func (d *Definition) toRawDefinition() (*internal.RawDefinition, error) {
 	for _, rt := range d.Templates {
 		rawTemplates = append(rawTemplates, internal.RawTemplate(rt))
 	}
-	rawConstraints := internal.RawConstraints{}
-	for key, value := range d.Constraints {
-		rawConstraints[internal.RawConstraintKey(key)] = internal.RawConstraintValue(value)
-	}
-
 	return &internal.RawDefinition{
 		Type:           internal.DefinitionType(d.Type),
 		RawTemplates:   rawTemplates,
-		RawConstraints: rawConstraints,
+		RawConstraints: newRawConstraints(d.Constraints),
 		AllowDuplicate: d.AllowDuplicate,
 		Aliases:        newAliases(d.Aliases),
 		Order:          d.getOrder(),
 		Weight:         internal.DefinitionWeight(d.Weight),
+		Generator:      d.Generator.toGenerator(),
+
+		RawValueSetConstraints: newRawValueSetConstraints(d.ValueSetConstraints),
+		RawConstraintGroups:    newRawConstraintGroups(d.ConstraintGroups),
+		Sets:                   newRawAssignments(d.Sets),
+		TemplateAttributes:     newRawTemplateAttributes(d.TemplateAttributes),
+		Locale:                 internal.Locale(d.Locale),
 	}, nil
 }
 
@@ -64,12 +232,65 @@ func (d *Definition) getOrder() (order []internal.DefinitionType) {
 }
 
 type Messagen struct {
-	repo *internal.DefinitionRepository
+	repo    *internal.DefinitionRepository
+	history *HistoryOption
+	locales []internal.Locale
 }
 type Option struct {
 	TemplatePickers    []internal.TemplatePicker
 	DefinitionPickers  []internal.DefinitionPicker
 	TemplateValidators []internal.TemplateValidator
+
+	// RandSource is the random source used by built-in pickers like RandomTemplatePicker.
+	// Generators which have sources with the same seed generate the same messages.
+	// If RandSource is nil, the global source of math/rand is used.
+	RandSource rand.Source
+
+	// MaxDepth, MaxSteps and Timeout limit each search of Generate and Enumerate.
+	// If a limit is exceeded, *LimitExceededError is returned. Zero value means no limit.
+	// MaxDepth is the max depth of derivation trees. The root definition type is depth 1.
+	// It protects the search from definitions which refer their own type.
+	MaxDepth int
+	// MaxSteps is the max number of templates which are tried in a search.
+	MaxSteps int
+	// Timeout is the max wall-clock time of a search.
+	Timeout time.Duration
+
+	// FuncMap is functions which are available in templates like {{upper .Name}}.
+	// Built-in functions (upper, lower, title, trim, replace, default, join, runeLength and printf) are always available,
+	// and functions in FuncMap override them.
+	// Templates converted by ParseTracery need TraceryFuncMap.
+	FuncMap template.FuncMap
+
+	// History avoids messages and templates which were generated recently by Generate and GenerateDetailed.
+	// If History is nil, the history is not used.
+	History *HistoryOption
+
+	// PostProcess is transformers which are applied in order to generated messages of the root definition type like trimming spaces.
+	// TypePostProcess is transformers which are applied to messages of each definition type before PostProcess.
+	// TemplateValidators validate transformed messages, so length checks see the real output.
+	PostProcess     []MessageTransformer
+	TypePostProcess map[string][]MessageTransformer
+
+	// Locales is the locale fallback chain like ["ja", "en"] which Generate, Enumerate and Count use.
+	// For each definition type, definitions of the first locale in Locales which the type has are picked with definitions without locale.
+	// If Locales is empty, definitions of all locales are picked.
+	Locales []string
+}
+
+// NewTransformers returns built-in transformers of the names in order.
+// Names are "trim", "collapse-space", "capitalize", "article" and "normalize-punctuation".
+func NewTransformers(names []string) ([]MessageTransformer, error) {
+	builtinTransformers := internal.BuiltinTransformers()
+	var transformers []MessageTransformer
+	for _, name := range names {
+		transformer, ok := builtinTransformers[name]
+		if !ok {
+			return nil, xerrors.Errorf("unknown post process: %s", name)
+		}
+		transformers = append(transformers, transformer)
+	}
+	return transformers, nil
 }
 
 func New(opt *Option) (*Messagen, error) {
@@ -88,17 +309,67 @@ func New(opt *Option) (*Messagen, error) {
 		templateValidators = opt.TemplateValidators
 	}
 
+	var randSource rand.Source
+	var limits internal.Limits
+	var funcs template.FuncMap
+	var history *HistoryOption
+	var locales []internal.Locale
+	var rootTransformers []MessageTransformer
+	var typeTransformers map[internal.DefinitionType][]MessageTransformer
+	if opt != nil {
+		randSource = opt.RandSource
+		limits = internal.Limits{MaxDepth: opt.MaxDepth, MaxSteps: opt.MaxSteps, Timeout: opt.Timeout}
+		funcs = opt.FuncMap
+		history = opt.History
+		locales = newLocales(opt.Locales)
+		rootTransformers = opt.PostProcess
+		for defType, transformers := range opt.TypePostProcess {
+			if typeTransformers == nil {
+				typeTransformers = map[internal.DefinitionType][]MessageTransformer{}
+			}
+			typeTransformers[internal.DefinitionType(defType)] = transformers
+		}
+	}
+	if history != nil && history.Store == nil {
+		return nil, xerrors.Errorf("failed to create Messagen: store of history is nil")
+	}
+
 	return &Messagen{
 		repo: internal.NewDefinitionRepository(
 			&internal.DefinitionRepositoryOption{
 				TemplatePickers:    templatePickers,
 				DefinitionPickers:  definitionPickers,
 				TemplateValidators: templateValidators,
+				RandSource:         randSource,
+				Limits:             limits,
+				FuncMap:            funcs,
+				RootTransformers:   rootTransformers,
+				TypeTransformers:   typeTransformers,
+
+				// built-in random picker is replaced with picking generated values one by one
+				PickGeneratedValuesAtRandom: opt == nil || opt.TemplatePickers == nil,
 			},
 		),
+		history: history,
+		locales: locales,
 	}, nil
 }
 
+// WithLocales returns a Messagen which shares definitions with m, but uses the locale fallback chain like Locales of Option.
+func (m *Messagen) WithLocales(locales ...string) *Messagen {
+	newMessagen := *m
+	newMessagen.locales = newLocales(locales)
+	return &newMessagen
+}
+
+func newLocales(locales []string) []internal.Locale {
+	var newLocales []internal.Locale
+	for _, locale := range locales {
+		newLocales = append(newLocales, internal.Locale(locale))
+	}
+	return newLocales
+}
+
 func (m *Messagen) AddDefinition(defs ...*Definition) error {
 	for _, def := range defs {
 		rawDef, err := def.toRawDefinition()
@@ -112,18 +383,253 @@ func (m *Messagen) AddDefinition(defs ...*Definition) error {
 	return nil
 }
 
+// ProvidedValue is a candidate value which DefinitionProvider provides.
+type ProvidedValue struct {
+	Value string
+	// Weight is the weight like Weight of Definition. If it is 0, 1 is used.
+	Weight float32
+	// Constraints are the constraints like Constraints of Definition.
+	// Constraints with + like {"Gender+": "Female"} set values to the state when the value is picked.
+	Constraints map[string]string
+}
+
+// DefinitionProvider provides candidate values of a definition type at generation time.
+// Provide is called with the current state each time the type is resolved,
+// and the values participate in backtracking like values of ordinary definitions.
+type DefinitionProvider interface {
+	Provide(ctx context.Context, state *State) ([]*ProvidedValue, error)
+}
+
+// DefinitionProviderFunc is an adapter to use a function as DefinitionProvider.
+type DefinitionProviderFunc func(ctx context.Context, state *State) ([]*ProvidedValue, error)
+
+func (f DefinitionProviderFunc) Provide(ctx context.Context, state *State) ([]*ProvidedValue, error) {
+	return f(ctx, state)
+}
+
+// AddDefinitionProvider registers the provider of the definition type.
+// Values of the provider are candidates in addition to the added definitions of the type,
+// and Lint regards the type as defined.
+func (m *Messagen) AddDefinitionProvider(defType string, provider DefinitionProvider) error {
+	return m.repo.AddProvider(internal.DefinitionType(defType), internal.DefinitionProviderFunc(
+		func(ctx context.Context, state *internal.State) ([]*internal.ProvidedValue, error) {
+			values, err := provider.Provide(ctx, state)
+			if err != nil {
+				return nil, err
+			}
+			var providedValues []*internal.ProvidedValue
+			for _, value := range values {
+				providedValues = append(providedValues, &internal.ProvidedValue{
+					Value:       internal.Message(value.Value),
+					Weight:      internal.DefinitionWeight(value.Weight),
+					Constraints: newRawConstraints(value.Constraints),
+				})
+			}
+			return providedValues, nil
+		}))
+}
+
 func (m *Messagen) Generate(defType string, state map[string]string, num uint) ([]string, error) {
-	msgs, err := m.repo.Generate(internal.DefinitionType(defType), newState(state), num)
+	return m.GenerateContext(context.Background(), defType, state, num)
+}
+
+// GenerateContext generates messages like Generate, but stops the search when ctx is done.
+func (m *Messagen) GenerateContext(ctx context.Context, defType string, state map[string]string, num uint) ([]string, error) {
+	msgs, err := m.generateDetailed(ctx, defType, state, num)
 	if err != nil {
 		return nil, err
 	}
 	var strMsgs []string
 	for _, msg := range msgs {
-		strMsgs = append(strMsgs, string(msg))
+		strMsgs = append(strMsgs, string(msg.Message))
 	}
 	return strMsgs, err
 }
 
+// generateDetailed generates messages which are not in the history, and adds them to the history.
+func (m *Messagen) generateDetailed(ctx context.Context, defType string, state map[string]string, num uint) ([]*internal.DetailedMessage, error) {
+	s := m.newState(state)
+	now := time.Now()
+	if m.history != nil {
+		history, err := m.history.newHistory(now)
+		if err != nil {
+			return nil, err
+		}
+		s.SetHistory(history)
+	}
+
+	msgs, err := m.repo.GenerateDetailed(ctx, internal.DefinitionType(defType), s, num)
+	if err != nil {
+		return nil, err
+	}
+
+	if m.history != nil {
+		var entries []*HistoryEntry
+		for _, msg := range msgs {
+			entries = append(entries, newHistoryEntry(msg, now))
+		}
+		if err := m.history.Store.Add(entries...); err != nil {
+			return nil, xerrors.Errorf("failed to add messages to history: %w", err)
+		}
+	}
+	return msgs, nil
+}
+
+// DetailedMessage is a generated message with its final state and derivation.
+type DetailedMessage struct {
+	Message string
+	// State is the final state contents after the message is generated.
+	State      map[string]string
+	Derivation *Derivation
+}
+
+// Derivation is a node of the derivation tree of a message.
+type Derivation struct {
+	// Type is the definition type of the picked definition.
+	Type string
+	// Alias is the alias name if the definition is referred by alias, otherwise empty.
+	Alias        string
+	DefinitionID int
+	// Template is the picked template.
+	Template string
+	Message  string
+	// AddedState is state values which are added by constraints of the definition.
+	AddedState map[string]string
+	// Children are derivations of definition types referred from the template, in resolved order.
+	Children []*Derivation
+}
+
+func newDerivation(d *internal.Derivation) *Derivation {
+	if d == nil {
+		return nil
+	}
+	derivation := &Derivation{
+		Type:         string(d.Type),
+		Alias:        string(d.AliasName),
+		DefinitionID: int(d.DefinitionID),
+		Template:     string(d.Template),
+		Message:      string(d.Message),
+		AddedState:   toStrMap(d.AddedState),
+	}
+	for _, child := range d.Children {
+		derivation.Children = append(derivation.Children, newDerivation(child))
+	}
+	return derivation
+}
+
+// GenerateDetailed generates messages like Generate, but returns them with their final states and derivation trees.
+func (m *Messagen) GenerateDetailed(defType string, state map[string]string, num uint) ([]*DetailedMessage, error) {
+	return m.GenerateDetailedContext(context.Background(), defType, state, num)
+}
+
+// GenerateDetailedContext generates messages like GenerateDetailed, but stops the search when ctx is done.
+func (m *Messagen) GenerateDetailedContext(ctx context.Context, defType string, state map[string]string, num uint) ([]*DetailedMessage, error) {
+	msgs, err := m.generateDetailed(ctx, defType, state, num)
+	if err != nil {
+		return nil, err
+	}
+	var detailedMessages []*DetailedMessage
+	for _, msg := range msgs {
+		detailedMessages = append(detailedMessages, &DetailedMessage{
+			Message:    string(msg.Message),
+			State:      toStrMap(msg.State),
+			Derivation: newDerivation(msg.Derivation),
+		})
+	}
+	return detailedMessages, nil
+}
+
+// Enumerate returns an iterator which yields every distinct message of defType in deterministic order.
+// Definitions are picked in order of constraint priority and addition, and templates are picked in ascending order.
+// TemplatePickers and DefinitionPickers in Option are not applied, but TemplateValidators are.
+func (m *Messagen) Enumerate(defType string, state map[string]string) (*MessageIterator, error) {
+	return m.EnumerateContext(context.Background(), defType, state)
+}
+
+// EnumerateContext returns an iterator like Enumerate, but the iterator stops the search when ctx is done.
+func (m *Messagen) EnumerateContext(ctx context.Context, defType string, state map[string]string) (*MessageIterator, error) {
+	it, err := m.repo.Enumerate(ctx, internal.DefinitionType(defType), m.newState(state))
+	if err != nil {
+		return nil, err
+	}
+	return &MessageIterator{it: it}, nil
+}
+
+// CountResult represents the number of messages which can be generated.
+type CountResult struct {
+	// Count is the number of distinct derivations of messages.
+	// Different derivations may generate the same message, so the number of distinct messages may be smaller than Count.
+	// If IsExact is false, Count is an upper bound.
+	Count *big.Int
+	// IsExact reports whether Count is exact.
+	IsExact bool
+	// Reasons describe why Count is not exact.
+	Reasons []string
+}
+
+// Count returns the number of distinct derivations of defType under the initial state without enumerating them.
+// The number is computed by dynamic programming over definition types, constraints and alias duplicate rules.
+// TemplateValidators can not be considered, so if any validator is registered, the result is an upper bound.
+func (m *Messagen) Count(defType string, state map[string]string) (*CountResult, error) {
+	return m.CountContext(context.Background(), defType, state)
+}
+
+// CountContext returns the number of derivations like Count, but stops counting when ctx is done.
+func (m *Messagen) CountContext(ctx context.Context, defType string, state map[string]string) (*CountResult, error) {
+	result, err := m.repo.Count(ctx, internal.DefinitionType(defType), m.newState(state))
+	if err != nil {
+		return nil, err
+	}
+	return &CountResult{
+		Count:   result.Count,
+		IsExact: result.IsExact,
+		Reasons: result.Reasons,
+	}, nil
+}
+
+// Lint statically analyzes the added definitions, and returns issues like references to undefined types,
+// constraints to types which are never set, unreachable definitions and recursive references.
+// Keys of state are regarded as defined types.
+func (m *Messagen) Lint(rootType string, state map[string]string) LintIssues {
+	return m.repo.Lint(internal.DefinitionType(rootType), newState(state))
+}
+
+// LintDefinitions analyzes definitions like Messagen.Lint without adding them.
+// Unlike Messagen.Lint, definitions which can not be added like invalid regexp constraints are reported as issues.
+// DefinitionIndex of each issue is the index in defs.
+func LintDefinitions(rootType string, state map[string]string, defs ...*Definition) LintIssues {
+	return LintDefinitionsWithFuncMap(rootType, state, nil, defs...)
+}
+
+// LintDefinitionsWithFuncMap analyzes definitions like LintDefinitions.
+// Templates can call functions in funcMap like FuncMap of Option.
+func LintDefinitionsWithFuncMap(rootType string, state map[string]string, funcMap template.FuncMap, defs ...*Definition) LintIssues {
+	var rawDefs []*internal.RawDefinition
+	for _, def := range defs {
+		rawDef, _ := def.toRawDefinition()
+		rawDefs = append(rawDefs, rawDef)
+	}
+	return internal.Lint(rawDefs, internal.DefinitionType(rootType), newState(state), internal.NewFuncSet(funcMap))
+}
+
+// MessageIterator yields generated messages one by one.
+type MessageIterator struct {
+	it *internal.MessageIterator
+}
+
+// Next returns the next message. ok is false if no more messages exist.
+func (m *MessageIterator) Next() (msg string, ok bool, err error) {
+	message, ok, err := m.it.Next()
+	return string(message), ok, err
+}
+
+// newState returns the initial state of a search in the locales of m.
+func (m *Messagen) newState(s map[string]string) *internal.State {
+	state := newState(s)
+	state.SetLocales(m.locales)
+	return state
+}
+
 func newState(s map[string]string) *internal.State {
 	state := internal.NewState(nil)
 	for key, value := range s {
@@ -131,3 +637,11 @@ func newState(s map[string]string) *internal.State {
 	}
 	return state
 }
+
+func toStrMap(m internal.MessageMap) map[string]string {
+	strMap := map[string]string{}
+	for key, value := range m {
+		strMap[key] = string(value)
+	}
+	return strMap
+}
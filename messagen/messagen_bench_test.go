@@ -0,0 +1,50 @@
+package messagen
+
+import (
+	"testing"
+)
+
+func newBenchmarkGenerator(b *testing.B, filePath string, opt *Option) *Messagen {
+	b.Helper()
+	config, err := ParseYamlFile(filePath)
+	if err != nil {
+		b.Fatalf("failed to parse %s: %s", filePath, err)
+	}
+	generator, err := New(opt)
+	if err != nil {
+		b.Fatalf("failed to create generator: %s", err)
+	}
+	if err := generator.AddDefinition(config.Definitions...); err != nil {
+		b.Fatalf("failed to add definitions from %s: %s", filePath, err)
+	}
+	return generator
+}
+
+func BenchmarkMessagen_Generate(b *testing.B) {
+	benchmarks := []struct {
+		name     string
+		filePath string
+		state    map[string]string
+		num      uint
+	}{
+		{name: "hello", filePath: "../testdata/hello.yaml", num: 1},
+		{name: "greeting", filePath: "../testdata/greeting.yaml", num: 1},
+		{name: "gatya", filePath: "../testdata/gatya.yaml", num: 1},
+		{name: "gurume", filePath: "../testdata/gurume.yaml", num: 1},
+		{name: "gurume all", filePath: "../testdata/gurume.yaml", num: 1000},
+		{name: "sutaba", filePath: "../testdata/sutaba.yaml", state: map[string]string{"Class": "Sutaba", "Confidence": "High"}, num: 1},
+		{name: "pokemon", filePath: "../examples/iroha/pokemon.yaml", num: 1},
+	}
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			generator := newBenchmarkGenerator(b, bm.filePath, nil)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := generator.Generate("Root", bm.state, bm.num); err != nil {
+					b.Fatalf("failed to generate message: %s", err)
+				}
+			}
+		})
+	}
+}
@@ -1,14 +1,18 @@
 package messagen_test
 
 import (
+	"context"
 	"fmt"
 	"math/rand"
+	"text/template"
 
 	"github.com/mpppk/messagen/messagen"
 )
 
 func Example() {
-	generator, _ := messagen.New(nil)
+	// RandSource is used to pick definitions and templates.
+	// Generators which have the same seed generate the same messages.
+	generator, _ := messagen.New(&messagen.Option{RandSource: rand.NewSource(0)})
 
 	definitions := []*messagen.Definition{
 		{
@@ -19,7 +23,8 @@ func Example() {
 
 			// Templates are template for generate message.
 			// If two ore more templates are given, one of them is picked at random.
-			// You can write template like golang text/template format. (but currently functions are unavailable)
+			// You can write template like golang text/template format.
+			// Built-in functions like {{upper .SomeType}} and functions given by Option.FuncMap are available.
 			// If Type is embedded by the notation like {{.SomeType}},
 			// one of definition that have the Type is chosen and inject generated message.
 			// For example, below template refers three Types, Pronoun, FirstName, and LastName.
@@ -63,9 +68,6 @@ func Example() {
 	// AddDefinition definitions to generator.
 	_ = generator.AddDefinition(definitions...)
 
-	// Set random seed for pick definitions and templates.
-	rand.Seed(0)
-
 	// Generate method generate message according to added definitions.
 	// First argument represent definition Type of start point.
 	messages, _ := generator.Generate("Root", nil, 1)
@@ -78,10 +80,10 @@ func Example() {
 	maleMessages, _ := generator.Generate("Root", map[string]string{"Gender": "Male"}, 1)
 	fmt.Printf("%s\n%s\n%s\n", messages[0], femaleMessages[0], maleMessages[0])
 
-	// Output
+	// Output:
 	// She is Charlotte Williams.
-	// She is Emily Smith.
-	// He is James Smith.
+	// She is Sofia Smith.
+	// He is Liam Brown.
 }
 
 func ExampleParseYaml() {
@@ -98,3 +100,150 @@ Definitions:
 	// Output:
 	// Root hello
 }
+
+func ExampleMessagen_Enumerate() {
+	generator, _ := messagen.New(nil)
+	_ = generator.AddDefinition(
+		&messagen.Definition{
+			Type:      "Root",
+			Templates: []string{"{{.Pronoun}} is {{.FirstName}}."},
+		},
+		&messagen.Definition{
+			Type:      "Pronoun",
+			Templates: []string{"He", "She"},
+		},
+		&messagen.Definition{
+			Type:        "FirstName",
+			Templates:   []string{"Liam", "James"},
+			Constraints: map[string]string{"Pronoun": "He"},
+		},
+		&messagen.Definition{
+			Type:        "FirstName",
+			Templates:   []string{"Emily"},
+			Constraints: map[string]string{"Pronoun": "She"},
+		},
+	)
+
+	// Enumerate yields every message which can be generated in deterministic order.
+	it, _ := generator.Enumerate("Root", nil)
+	for {
+		msg, ok, err := it.Next()
+		if err != nil || !ok {
+			break
+		}
+		fmt.Println(msg)
+	}
+
+	// Output:
+	// He is Liam.
+	// He is James.
+	// She is Emily.
+}
+
+func ExampleMessagen_Count() {
+	generator, _ := messagen.New(nil)
+	_ = generator.AddDefinition(
+		&messagen.Definition{
+			Type:      "Root",
+			Templates: []string{"{{.Pronoun}} is {{.FirstName}}."},
+		},
+		&messagen.Definition{
+			Type:      "Pronoun",
+			Templates: []string{"He", "She"},
+		},
+		&messagen.Definition{
+			Type:        "FirstName",
+			Templates:   []string{"Liam", "James"},
+			Constraints: map[string]string{"Pronoun": "He"},
+		},
+		&messagen.Definition{
+			Type:        "FirstName",
+			Templates:   []string{"Emily"},
+			Constraints: map[string]string{"Pronoun": "She"},
+		},
+	)
+
+	// Count computes the number of messages without enumerating them.
+	result, _ := generator.Count("Root", nil)
+	fmt.Println(result.Count, result.IsExact)
+
+	// Output:
+	// 3 true
+}
+
+func ExampleOption_funcMap() {
+	generator, _ := messagen.New(&messagen.Option{
+		// Functions in FuncMap are available in templates in addition to built-in functions.
+		FuncMap: template.FuncMap{
+			"exclaim": func(s string) string { return s + "!" },
+		},
+	})
+	_ = generator.AddDefinition(
+		&messagen.Definition{
+			Type:      "Root",
+			Templates: []string{`{{upper .Greeting | exclaim}} {{.Name | replace "o" "0"}} ({{runeLength .Name}})`},
+		},
+		&messagen.Definition{
+			Type:      "Greeting",
+			Templates: []string{"hello"},
+		},
+		&messagen.Definition{
+			Type:      "Name",
+			Templates: []string{"Bob"},
+		},
+	)
+
+	messages, _ := generator.Generate("Root", nil, 1)
+	fmt.Println(messages[0])
+
+	// Output:
+	// HELLO! B0b (3)
+}
+
+func ExampleMessagen_AddDefinitionProvider() {
+	generator, _ := messagen.New(nil)
+	_ = generator.AddDefinition(
+		&messagen.Definition{
+			Type:      "Root",
+			Templates: []string{"{{.UserName}} joined. Say hello to {{.Pronoun}}!"},
+		},
+		&messagen.Definition{
+			Type:        "Pronoun",
+			Templates:   []string{"him"},
+			Constraints: map[string]string{"Gender": "Male"},
+		},
+		&messagen.Definition{
+			Type:        "Pronoun",
+			Templates:   []string{"her"},
+			Constraints: map[string]string{"Gender": "Female"},
+		},
+	)
+
+	// The provider is called at generation time, so values can come from application data.
+	users := map[string]string{"Alice": "Female", "Bob": "Male"}
+	_ = generator.AddDefinitionProvider("UserName", messagen.DefinitionProviderFunc(
+		func(ctx context.Context, state *messagen.State) ([]*messagen.ProvidedValue, error) {
+			var values []*messagen.ProvidedValue
+			for _, name := range []string{"Alice", "Bob"} {
+				values = append(values, &messagen.ProvidedValue{
+					Value: name,
+					// constraints with + set values to the state like ordinary definitions
+					Constraints: map[string]string{"Gender+": users[name]},
+				})
+			}
+			return values, nil
+		}))
+
+	it, _ := generator.Enumerate("Root", nil)
+	for {
+		msg, ok, err := it.Next()
+		if err != nil || !ok {
+			break
+		}
+		fmt.Println(msg)
+	}
+
+	// Output:
+	// Alice joined. Say hello to her!
+	// Bob joined. Say hello to him!
+}
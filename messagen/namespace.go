@@ -0,0 +1,254 @@
+package messagen
+
+import (
+	"strings"
+
+	"github.com/mpppk/messagen/messagen/internal"
+	"golang.org/x/xerrors"
+)
+
+// loadedConfig is a config with the file path or URL which it is loaded from.
+type loadedConfig struct {
+	filePathOrUrl string
+	config        *Config
+}
+
+// resolveNamespaces qualifies definition types of configs which have Namespace like "emoji.Good",
+// and replaces references to them in the configs with the qualified types.
+// References to types which are not exported from other namespaces and ambiguous references are error.
+func resolveNamespaces(configs []*loadedConfig) error {
+	globalTypes := map[string]bool{}
+	// namespaces has whether each type of each namespace is exported
+	namespaces := map[string]map[string]bool{}
+	for _, lc := range configs {
+		c := lc.config
+		if c.Namespace == "" {
+			if len(c.Exports) > 0 {
+				return xerrors.Errorf("Exports of %s requires Namespace", lc.filePathOrUrl)
+			}
+			for _, def := range c.Definitions {
+				globalTypes[def.Type] = true
+			}
+			continue
+		}
+		if strings.Contains(c.Namespace, internal.NamespaceSeparator) {
+			return xerrors.Errorf("Namespace of %s must not contain %q: %s", lc.filePathOrUrl, internal.NamespaceSeparator, c.Namespace)
+		}
+
+		types, ok := namespaces[c.Namespace]
+		if !ok {
+			types = map[string]bool{}
+			namespaces[c.Namespace] = types
+		}
+		exports := map[string]bool{}
+		for _, export := range c.Exports {
+			exports[export] = true
+		}
+		definedTypes := map[string]bool{}
+		for _, def := range c.Definitions {
+			if strings.Contains(def.Type, internal.NamespaceSeparator) {
+				return xerrors.Errorf("definition type %s in %s must not contain %q because the config has Namespace", def.Type, lc.filePathOrUrl, internal.NamespaceSeparator)
+			}
+			definedTypes[def.Type] = true
+			types[def.Type] = types[def.Type] || len(c.Exports) == 0 || exports[def.Type]
+		}
+		for _, export := range c.Exports {
+			if !definedTypes[export] {
+				return xerrors.Errorf("exported type %s is not defined in %s", export, lc.filePathOrUrl)
+			}
+		}
+	}
+	if len(namespaces) == 0 {
+		return nil
+	}
+
+	for namespace := range namespaces {
+		if globalTypes[namespace] {
+			return xerrors.Errorf("namespace %s is ambiguous because definition type %s is also defined", namespace, namespace)
+		}
+	}
+	for _, lc := range configs {
+		r := &namespaceResolver{namespace: lc.config.Namespace, globalTypes: globalTypes, namespaces: namespaces}
+		for _, def := range lc.config.Definitions {
+			if err := r.qualifyDefinition(def); err != nil {
+				return xerrors.Errorf("failed to resolve references of %s in %s: %w", def.Type, lc.filePathOrUrl, err)
+			}
+		}
+		if err := r.qualifyTypePostProcess(lc.config); err != nil {
+			return xerrors.Errorf("failed to resolve TypePostProcess in %s: %w", lc.filePathOrUrl, err)
+		}
+	}
+	return nil
+}
+
+// namespaceResolver resolves references in a config of the namespace. namespace is empty if the config has no namespace.
+type namespaceResolver struct {
+	namespace   string
+	globalTypes map[string]bool
+	namespaces  map[string]map[string]bool
+}
+
+// rename returns the qualified type of the reference.
+// In a namespaced config, unqualified references to types of the namespace are qualified.
+func (r *namespaceResolver) rename(defType internal.DefinitionType) (internal.DefinitionType, error) {
+	name := string(defType)
+	if i := strings.Index(name, internal.NamespaceSeparator); i >= 0 {
+		namespace, typeName := name[:i], name[i+len(internal.NamespaceSeparator):]
+		if namespace == r.namespace {
+			return defType, nil
+		}
+		if exported, ok := r.namespaces[namespace][typeName]; ok && !exported {
+			return "", xerrors.Errorf("%s is not exported from namespace %s", typeName, namespace)
+		}
+		return defType, nil
+	}
+
+	if r.namespace == "" {
+		return defType, nil
+	}
+	if _, ok := r.namespaces[r.namespace][name]; !ok {
+		return defType, nil
+	}
+	qualifiedType := r.namespace + internal.NamespaceSeparator + name
+	if r.globalTypes[name] {
+		return "", xerrors.Errorf("reference to %s is ambiguous: both %s and %s are defined", name, name, qualifiedType)
+	}
+	return internal.DefinitionType(qualifiedType), nil
+}
+
+// qualifyDefinition qualifies the type of the definition, and resolves references in templates, constraints, aliases, order and sets.
+func (r *namespaceResolver) qualifyDefinition(def *Definition) error {
+	if r.namespace != "" {
+		def.Type = r.namespace + internal.NamespaceSeparator + def.Type
+	}
+	for i, template := range def.Templates {
+		renamed, err := r.renameTemplate(template)
+		if err != nil {
+			return err
+		}
+		def.Templates[i] = renamed
+	}
+
+	var err error
+	if def.Constraints, def.ValueSetConstraints, err = r.renameConstraints(def.Constraints, def.ValueSetConstraints); err != nil {
+		return err
+	}
+	for _, group := range def.ConstraintGroups {
+		if group.Constraints, group.ValueSetConstraints, err = r.renameConstraints(group.Constraints, group.ValueSetConstraints); err != nil {
+			return err
+		}
+	}
+	for _, attr := range def.TemplateAttributes {
+		if attr == nil {
+			continue
+		}
+		if attr.Constraints, attr.ValueSetConstraints, err = r.renameConstraints(attr.Constraints, attr.ValueSetConstraints); err != nil {
+			return err
+		}
+		if attr.Sets, err = r.renameSets(attr.Sets); err != nil {
+			return err
+		}
+	}
+	if def.Sets, err = r.renameSets(def.Sets); err != nil {
+		return err
+	}
+
+	for _, alias := range def.Aliases {
+		renamed, err := r.rename(internal.DefinitionType(alias.Type))
+		if err != nil {
+			return err
+		}
+		alias.Type = string(renamed)
+	}
+	for i, o := range def.Order {
+		renamed, err := r.rename(internal.DefinitionType(o))
+		if err != nil {
+			return err
+		}
+		def.Order[i] = string(renamed)
+	}
+	return nil
+}
+
+func (r *namespaceResolver) qualifyTypePostProcess(c *Config) error {
+	var typePostProcess map[string][]string
+	for defType, names := range c.TypePostProcess {
+		renamed, err := r.rename(internal.DefinitionType(defType))
+		if err != nil {
+			return err
+		}
+		if typePostProcess == nil {
+			typePostProcess = map[string][]string{}
+		}
+		typePostProcess[string(renamed)] = names
+	}
+	c.TypePostProcess = typePostProcess
+	return nil
+}
+
+func (r *namespaceResolver) renameTemplate(template string) (string, error) {
+	renamed, err := internal.RenameTemplateRefs(internal.RawTemplate(template), r.rename)
+	return string(renamed), err
+}
+
+func (r *namespaceResolver) renameConstraints(constraints map[string]string, valueSets map[string][]string) (map[string]string, map[string][]string, error) {
+	var newConstraints map[string]string
+	for key, value := range constraints {
+		newKey, err := internal.RenameConstraintKey(internal.RawConstraintKey(key), r.rename)
+		if err != nil {
+			return nil, nil, err
+		}
+		newValue, err := internal.RenameConstraintValue(internal.RawConstraintValue(value), r.rename)
+		if err != nil {
+			return nil, nil, err
+		}
+		if newConstraints == nil {
+			newConstraints = map[string]string{}
+		}
+		newConstraints[string(newKey)] = string(newValue)
+	}
+
+	var newValueSets map[string][]string
+	for key, values := range valueSets {
+		newKey, err := internal.RenameConstraintKey(internal.RawConstraintKey(key), r.rename)
+		if err != nil {
+			return nil, nil, err
+		}
+		var newValues []string
+		for _, value := range values {
+			newValue, err := internal.RenameConstraintValue(internal.RawConstraintValue(value), r.rename)
+			if err != nil {
+				return nil, nil, err
+			}
+			newValues = append(newValues, string(newValue))
+		}
+		if newValueSets == nil {
+			newValueSets = map[string][]string{}
+		}
+		newValueSets[string(newKey)] = newValues
+	}
+	return newConstraints, newValueSets, nil
+}
+
+func (r *namespaceResolver) renameSets(sets map[string]string) (map[string]string, error) {
+	var newSets map[string]string
+	for key, value := range sets {
+		onlyIfAbsent := strings.HasSuffix(key, "?")
+		newKey, err := r.rename(internal.DefinitionType(strings.TrimSuffix(key, "?")))
+		if err != nil {
+			return nil, err
+		}
+		newValue, err := r.renameTemplate(value)
+		if err != nil {
+			return nil, err
+		}
+		if newSets == nil {
+			newSets = map[string]string{}
+		}
+		if onlyIfAbsent {
+			newKey += "?"
+		}
+		newSets[string(newKey)] = newValue
+	}
+	return newSets, nil
+}
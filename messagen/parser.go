@@ -1,8 +1,11 @@
 package messagen
 
 import (
+	"bytes"
 	"io/ioutil"
 	"net/http"
+	"net/url"
+	"path/filepath"
 	"strings"
 
 	"golang.org/x/xerrors"
@@ -11,14 +14,52 @@ import (
 )
 
 type Config struct {
+	// Imports are files or URLs whose configs are merged before this config, like [names.yaml, ../emoji.yaml].
+	// Relative paths are resolved from the importing file, or from the base URL if the importing config is fetched from URL.
+	// Imports are resolved by ParseFileOrUrl, ParseFile and ParseFilesOrUrls, but not by Parse, ParseYaml, ParseJSON and ParseTOML.
+	Imports []string `yaml:"Imports,omitempty"`
+	// Namespace qualifies definition types of this config like "emoji.Good" of Good in namespace emoji.
+	// Other configs refer them like {{.emoji.Good}}, and templates of this config refer them like {{.Good}}.
+	// Namespaces are resolved with imports, so Parse does not qualify types.
+	Namespace string `yaml:"Namespace,omitempty"`
+	// Exports are definition types which other configs can refer. If it is empty, all types are exported.
+	Exports     []string      `yaml:"Exports,omitempty"`
 	Definitions []*Definition `yaml:"Definitions"`
+	// PostProcess is names of built-in transformers which are applied to generated messages like [trim, collapse-space, capitalize].
+	PostProcess []string `yaml:"PostProcess,omitempty"`
+	// TypePostProcess is names of built-in transformers which are applied to messages of each definition type.
+	TypePostProcess map[string][]string `yaml:"TypePostProcess,omitempty"`
 }
 
-func ReadYamlFromFileOrUrl(filePathOrUrl string) ([]byte, error) {
-	if strings.HasPrefix(filePathOrUrl, "http") {
+// Transformers returns transformers of PostProcess and TypePostProcess, which are set to PostProcess and TypePostProcess of Option.
+func (c *Config) Transformers() (postProcess []MessageTransformer, typePostProcess map[string][]MessageTransformer, err error) {
+	postProcess, err = NewTransformers(c.PostProcess)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("invalid PostProcess: %w", err)
+	}
+	for defType, names := range c.TypePostProcess {
+		transformers, err := NewTransformers(names)
+		if err != nil {
+			return nil, nil, xerrors.Errorf("invalid TypePostProcess of %s: %w", defType, err)
+		}
+		if typePostProcess == nil {
+			typePostProcess = map[string][]MessageTransformer{}
+		}
+		typePostProcess[defType] = transformers
+	}
+	return postProcess, typePostProcess, nil
+}
+
+func isUrl(filePathOrUrl string) bool {
+	return strings.HasPrefix(filePathOrUrl, "http")
+}
+
+// ReadFromFileOrUrl reads the file or URL, and detects its format by the extension or the content type of the response.
+func ReadFromFileOrUrl(filePathOrUrl string) ([]byte, Format, error) {
+	if isUrl(filePathOrUrl) {
 		res, err := http.Get(filePathOrUrl)
 		if err != nil {
-			return nil, xerrors.Errorf("failed to fetch yaml from %s: %w", filePathOrUrl, err)
+			return nil, "", xerrors.Errorf("failed to fetch definitions from %s: %w", filePathOrUrl, err)
 		}
 		defer func() {
 			if e := res.Body.Close(); e != nil {
@@ -28,32 +69,160 @@ func ReadYamlFromFileOrUrl(filePathOrUrl string) ([]byte, error) {
 
 		contents, err := ioutil.ReadAll(res.Body)
 		if err != nil {
-			return nil, xerrors.Errorf("failed to read yaml from %s: %w", filePathOrUrl, err)
+			return nil, "", xerrors.Errorf("failed to read definitions from %s: %w", filePathOrUrl, err)
 		}
-		return contents, nil
+		return contents, DetectFormat(filePathOrUrl, res.Header.Get("Content-Type")), nil
 	}
 
 	contents, err := ioutil.ReadFile(filePathOrUrl)
 	if err != nil {
-		return nil, xerrors.Errorf("failed to parse yaml: %w", err)
+		return nil, "", xerrors.Errorf("failed to read definitions: %w", err)
 	}
-	return contents, nil
+	return contents, DetectFormat(filePathOrUrl, ""), nil
+}
+
+// ReadYamlFromFileOrUrl reads the file or URL. It is kept for compatibility, and ReadFromFileOrUrl also returns the format.
+func ReadYamlFromFileOrUrl(filePathOrUrl string) ([]byte, error) {
+	contents, _, err := ReadFromFileOrUrl(filePathOrUrl)
+	return contents, err
+}
+
+// ParseFileOrUrl parses the file or URL in the detected format, and merges configs of its imports.
+func ParseFileOrUrl(filePathOrUrl string) (*Config, error) {
+	return ParseFilesOrUrls(filePathOrUrl)
+}
+
+// ParseFile parses the file in the format detected by the extension, and merges configs of its imports.
+func ParseFile(filePath string) (*Config, error) {
+	return ParseFilesOrUrls(filePath)
 }
 
+// ParseYamlFileOrUrl is kept for compatibility. It is the same as ParseFileOrUrl, so files in any format can be parsed.
 func ParseYamlFileOrUrl(filePathOrUrl string) (*Config, error) {
-	contents, err := ReadYamlFromFileOrUrl(filePathOrUrl)
-	if err != nil {
+	return ParseFilesOrUrls(filePathOrUrl)
+}
+
+// ParseYamlFile is kept for compatibility. It is the same as ParseFile, so files in any format can be parsed.
+func ParseYamlFile(filePath string) (*Config, error) {
+	return ParseFilesOrUrls(filePath)
+}
+
+// ParseYamlFilesOrUrls is kept for compatibility. It is the same as ParseFilesOrUrls, so files in any format can be parsed.
+func ParseYamlFilesOrUrls(filePathsOrUrls ...string) (*Config, error) {
+	return ParseFilesOrUrls(filePathsOrUrls...)
+}
+
+// ParseFilesOrUrls parses the files or URLs with their imports, and merges them in order.
+// The format of each file or URL is detected by DetectFormat, so files in different formats can import each other.
+// Imported configs are merged before the importing config, and each file or URL is merged only once even if it is imported several times.
+// Cyclic imports are error.
+func ParseFilesOrUrls(filePathsOrUrls ...string) (*Config, error) {
+	loader := &configLoader{loaded: map[string]bool{}}
+	for _, filePathOrUrl := range filePathsOrUrls {
+		if err := loader.load(filePathOrUrl, nil); err != nil {
+			return nil, err
+		}
+	}
+	if err := resolveNamespaces(loader.configs); err != nil {
 		return nil, err
 	}
-	return ParseYaml(contents)
+
+	config := &Config{Definitions: []*Definition{}}
+	for _, lc := range loader.configs {
+		config.merge(lc.config)
+	}
+	return config, nil
 }
 
-func ParseYamlFile(filePath string) (*Config, error) {
-	contents, err := ioutil.ReadFile(filePath)
+// configLoader loads configs and their imports.
+type configLoader struct {
+	loaded map[string]bool
+	// configs are the loaded configs in merge order.
+	configs []*loadedConfig
+}
+
+// load loads configs of the imports of the file or URL and the config of itself.
+// chain is the files or URLs which import the file or URL in order.
+func (l *configLoader) load(filePathOrUrl string, chain []string) error {
+	if !isUrl(filePathOrUrl) {
+		filePathOrUrl = filepath.Clean(filePathOrUrl)
+	}
+	for _, c := range chain {
+		if c == filePathOrUrl {
+			return xerrors.Errorf("import cycle is detected: %s", strings.Join(append(chain, filePathOrUrl), " -> "))
+		}
+	}
+	if l.loaded[filePathOrUrl] {
+		return nil
+	}
+	l.loaded[filePathOrUrl] = true
+
+	c, err := l.parse(filePathOrUrl)
 	if err != nil {
-		return nil, xerrors.Errorf("failed to parse yaml: %w", err)
+		if len(chain) > 0 {
+			return xerrors.Errorf("failed to import %s (import chain: %s): %w", filePathOrUrl, strings.Join(append(chain, filePathOrUrl), " -> "), err)
+		}
+		return err
+	}
+
+	chain = append(chain[:len(chain):len(chain)], filePathOrUrl)
+	for _, importPath := range c.Imports {
+		resolvedPath, err := resolveImport(filePathOrUrl, importPath)
+		if err != nil {
+			return xerrors.Errorf("failed to resolve import %s (import chain: %s): %w", importPath, strings.Join(chain, " -> "), err)
+		}
+		if err := l.load(resolvedPath, chain); err != nil {
+			return err
+		}
+	}
+	l.configs = append(l.configs, &loadedConfig{filePathOrUrl: filePathOrUrl, config: c})
+	return nil
+}
+
+func (l *configLoader) parse(filePathOrUrl string) (*Config, error) {
+	contents, format, err := ReadFromFileOrUrl(filePathOrUrl)
+	if err != nil {
+		return nil, err
+	}
+	config, err := Parse(contents, format)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to parse %s: %w", filePathOrUrl, err)
+	}
+	return config, nil
+}
+
+// resolveImport returns the file path or URL of the import which is written in the config of base.
+func resolveImport(base, importPath string) (string, error) {
+	if isUrl(importPath) {
+		return importPath, nil
+	}
+	if isUrl(base) {
+		baseUrl, err := url.Parse(base)
+		if err != nil {
+			return "", err
+		}
+		importUrl, err := url.Parse(importPath)
+		if err != nil {
+			return "", err
+		}
+		return baseUrl.ResolveReference(importUrl).String(), nil
+	}
+	if filepath.IsAbs(importPath) {
+		return importPath, nil
+	}
+	return filepath.Join(filepath.Dir(base), importPath), nil
+}
+
+// merge appends Definitions, PostProcess and TypePostProcess of other to c.
+func (c *Config) merge(other *Config) {
+	c.Definitions = append(c.Definitions, other.Definitions...)
+	c.PostProcess = append(c.PostProcess, other.PostProcess...)
+	for defType, names := range other.TypePostProcess {
+		if c.TypePostProcess == nil {
+			c.TypePostProcess = map[string][]string{}
+		}
+		c.TypePostProcess[defType] = append(c.TypePostProcess[defType], names...)
 	}
-	return ParseYaml(contents)
 }
 
 func ParseYaml(contents []byte) (*Config, error) {
@@ -63,3 +232,246 @@ func ParseYaml(contents []byte) (*Config, error) {
 	}
 	return &config, nil
 }
+
+// MarshalYaml encodes the config to YAML which ParseYaml decodes to the same config.
+func MarshalYaml(config *Config) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(config); err != nil {
+		return nil, xerrors.Errorf("failed to marshal config to yaml: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, xerrors.Errorf("failed to marshal config to yaml: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalYAML decodes the definition.
+// Lists in Constraints like {Season: [Spring, Summer]} are decoded to ValueSetConstraints,
+// and templates written as objects like {Text: "...", Weight: 0.2} are decoded to Templates and TemplateAttributes.
+func (d *Definition) UnmarshalYAML(node *yaml.Node) error {
+	type plainDefinition Definition
+	if node.Kind != yaml.MappingNode {
+		return node.Decode((*plainDefinition)(d))
+	}
+
+	rest, nodes := splitMapping(node, "Constraints", "Templates")
+	if err := rest.Decode((*plainDefinition)(d)); err != nil {
+		return err
+	}
+	if templatesNode, ok := nodes["Templates"]; ok {
+		templates, attrs, err := decodeTemplates(templatesNode)
+		if err != nil {
+			return xerrors.Errorf("failed to decode templates of %s: %w", d.Type, err)
+		}
+		d.Templates, d.TemplateAttributes = templates, attrs
+	}
+	if constraintsNode, ok := nodes["Constraints"]; ok {
+		constraints, valueSets, err := decodeConstraints(constraintsNode)
+		if err != nil {
+			return xerrors.Errorf("failed to decode constraints of %s: %w", d.Type, err)
+		}
+		d.Constraints, d.ValueSetConstraints = constraints, valueSets
+	}
+	return nil
+}
+
+// MarshalYAML encodes the definition in the form which UnmarshalYAML decodes.
+// ValueSetConstraints are encoded as lists in Constraints, and templates with attributes are encoded as objects.
+func (d *Definition) MarshalYAML() (interface{}, error) {
+	type plainDefinition Definition
+	var node yaml.Node
+	if err := node.Encode((*plainDefinition)(d)); err != nil {
+		return nil, err
+	}
+	rest, _ := splitMapping(&node, "Templates", "Constraints")
+
+	var extra []*yaml.Node
+	if len(d.Templates) > 0 {
+		templatesNode, err := encodeTemplates(d.Templates, d.TemplateAttributes)
+		if err != nil {
+			return nil, xerrors.Errorf("failed to encode templates of %s: %w", d.Type, err)
+		}
+		extra = append(extra, &yaml.Node{Kind: yaml.ScalarNode, Value: "Templates"}, templatesNode)
+	}
+	if constraints := mergeConstraints(d.Constraints, d.ValueSetConstraints); len(constraints) > 0 {
+		constraintsNode := &yaml.Node{}
+		if err := constraintsNode.Encode(constraints); err != nil {
+			return nil, xerrors.Errorf("failed to encode constraints of %s: %w", d.Type, err)
+		}
+		extra = append(extra, &yaml.Node{Kind: yaml.ScalarNode, Value: "Constraints"}, constraintsNode)
+	}
+
+	// Templates and Constraints follow Type like hand-written definitions.
+	i := 0
+	if len(rest.Content) > 0 && rest.Content[0].Value == "Type" {
+		i = 2
+	}
+	rest.Content = append(append(rest.Content[:i:i], extra...), rest.Content[i:]...)
+	return rest, nil
+}
+
+// encodeTemplates encodes templates as strings, or as objects if they have attributes.
+func encodeTemplates(templates []string, attrs []*TemplateAttributes) (*yaml.Node, error) {
+	var items []interface{}
+	for i, template := range templates {
+		if i >= len(attrs) || attrs[i] == nil {
+			items = append(items, template)
+			continue
+		}
+		attr := attrs[i]
+		items = append(items, struct {
+			Text        string                 `yaml:"Text"`
+			Weight      float32                `yaml:"Weight,omitempty"`
+			Constraints map[string]interface{} `yaml:"Constraints,omitempty"`
+			Sets        map[string]string      `yaml:"Sets,omitempty"`
+		}{
+			Text:        template,
+			Weight:      attr.Weight,
+			Constraints: mergeConstraints(attr.Constraints, attr.ValueSetConstraints),
+			Sets:        attr.Sets,
+		})
+	}
+	node := &yaml.Node{}
+	if err := node.Encode(items); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+// mergeConstraints merges constraints and value sets into a map which decodeConstraints decodes.
+func mergeConstraints(constraints map[string]string, valueSets map[string][]string) map[string]interface{} {
+	if len(constraints) == 0 && len(valueSets) == 0 {
+		return nil
+	}
+	merged := map[string]interface{}{}
+	for key, value := range constraints {
+		merged[key] = value
+	}
+	for key, values := range valueSets {
+		merged[key] = values
+	}
+	return merged
+}
+
+// splitMapping returns the mapping node without the keys, and the values of the keys.
+func splitMapping(node *yaml.Node, keys ...string) (*yaml.Node, map[string]*yaml.Node) {
+	rest := *node
+	rest.Content = nil
+	values := map[string]*yaml.Node{}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		found := false
+		for _, k := range keys {
+			if key.Value == k {
+				values[k] = value
+				found = true
+			}
+		}
+		if !found {
+			rest.Content = append(rest.Content, key, value)
+		}
+	}
+	return &rest, values
+}
+
+// decodeTemplates decodes templates which are strings or objects like {Text: "...", Weight: 0.2, Constraints: {...}, Sets: {...}}.
+// attrs is nil if no template is an object.
+func decodeTemplates(node *yaml.Node) (templates []string, attrs []*TemplateAttributes, err error) {
+	if node.Kind != yaml.SequenceNode {
+		if err := node.Decode(&templates); err != nil {
+			return nil, nil, err
+		}
+		return templates, nil, nil
+	}
+
+	hasAttrs := false
+	for _, item := range node.Content {
+		if item.Kind != yaml.MappingNode {
+			var template string
+			if err := item.Decode(&template); err != nil {
+				return nil, nil, err
+			}
+			templates = append(templates, template)
+			attrs = append(attrs, nil)
+			continue
+		}
+
+		rest, nodes := splitMapping(item, "Constraints")
+		var object struct {
+			Text   *string           `yaml:"Text"`
+			Weight float32           `yaml:"Weight"`
+			Sets   map[string]string `yaml:"Sets"`
+		}
+		if err := rest.Decode(&object); err != nil {
+			return nil, nil, err
+		}
+		if object.Text == nil {
+			return nil, nil, xerrors.Errorf("template object must have Text (line %d)", item.Line)
+		}
+		attr := &TemplateAttributes{Weight: object.Weight, Sets: object.Sets}
+		if constraintsNode, ok := nodes["Constraints"]; ok {
+			attr.Constraints, attr.ValueSetConstraints, err = decodeConstraints(constraintsNode)
+			if err != nil {
+				return nil, nil, xerrors.Errorf("failed to decode constraints of template %q: %w", *object.Text, err)
+			}
+		}
+		templates = append(templates, *object.Text)
+		attrs = append(attrs, attr)
+		hasAttrs = true
+	}
+	if !hasAttrs {
+		attrs = nil
+	}
+	return templates, attrs, nil
+}
+
+// UnmarshalYAML decodes the constraint group which is written as a map like {Day: [Sat, Sun], Weather: Sunny}.
+func (c *ConstraintGroup) UnmarshalYAML(node *yaml.Node) error {
+	constraints, valueSets, err := decodeConstraints(node)
+	if err != nil {
+		return err
+	}
+	c.Constraints, c.ValueSetConstraints = constraints, valueSets
+	return nil
+}
+
+// MarshalYAML encodes the constraint group as a map like {Day: [Sat, Sun], Weather: Sunny}.
+func (c *ConstraintGroup) MarshalYAML() (interface{}, error) {
+	return mergeConstraints(c.Constraints, c.ValueSetConstraints), nil
+}
+
+// decodeConstraints decodes a map of constraints. Scalar values are constraints and lists are value sets.
+func decodeConstraints(node *yaml.Node) (constraints map[string]string, valueSets map[string][]string, err error) {
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!null" {
+		return nil, nil, nil
+	}
+	if node.Kind != yaml.MappingNode {
+		return nil, nil, xerrors.Errorf("constraints must be a map (line %d)", node.Line)
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i].Value, node.Content[i+1]
+		switch value.Kind {
+		case yaml.SequenceNode:
+			var values []string
+			if err := value.Decode(&values); err != nil {
+				return nil, nil, xerrors.Errorf("failed to decode value set of %s: %w", key, err)
+			}
+			if valueSets == nil {
+				valueSets = map[string][]string{}
+			}
+			valueSets[key] = values
+		default:
+			var v string
+			if err := value.Decode(&v); err != nil {
+				return nil, nil, xerrors.Errorf("failed to decode constraint value of %s: %w", key, err)
+			}
+			if constraints == nil {
+				constraints = map[string]string{}
+			}
+			constraints[key] = v
+		}
+	}
+	return constraints, valueSets, nil
+}
@@ -1,10 +1,42 @@
 package messagen
 
 import (
+	"net/http"
+	"net/http/httptest"
 	"reflect"
+	"strings"
 	"testing"
 )
 
+func TestParseYaml_ValueSetConstraints(t *testing.T) {
+	contents := []byte(`
+Definitions:
+  - Type: Greeting
+    Templates: ["hi"]
+    Constraints: {Season: [Spring, Summer], Weather: Sunny}
+    ConstraintGroups:
+      Weekend: {Day: [Sat, Sun]}
+      Holiday: {Holiday: "true"}
+`)
+	want := &Definition{
+		Type:                "Greeting",
+		Templates:           []string{"hi"},
+		Constraints:         map[string]string{"Weather": "Sunny"},
+		ValueSetConstraints: map[string][]string{"Season": {"Spring", "Summer"}},
+		ConstraintGroups: map[string]*ConstraintGroup{
+			"Weekend": {ValueSetConstraints: map[string][]string{"Day": {"Sat", "Sun"}}},
+			"Holiday": {Constraints: map[string]string{"Holiday": "true"}},
+		},
+	}
+	got, err := ParseYaml(contents)
+	if err != nil {
+		t.Fatalf("unexpected error occurred in ParseYaml(): %s", err)
+	}
+	if len(got.Definitions) != 1 || !reflect.DeepEqual(got.Definitions[0], want) {
+		t.Errorf("ParseYaml() = %#v, want %#v", got.Definitions, want)
+	}
+}
+
 func TestParseYaml(t *testing.T) {
 	type args struct {
 		filePath string
@@ -49,3 +81,342 @@ func TestParseYaml(t *testing.T) {
 		})
 	}
 }
+
+func TestParseYaml_TemplateAttributes(t *testing.T) {
+	contents := []byte(`
+Definitions:
+  - Type: Greeting
+    Templates:
+      - hi
+      - Text: good morning
+        Weight: 0.2
+        Constraints: {Time: [Morning, Dawn]}
+        Sets: {Formality: Polite}
+`)
+	want := &Definition{
+		Type:      "Greeting",
+		Templates: []string{"hi", "good morning"},
+		TemplateAttributes: []*TemplateAttributes{
+			nil,
+			{
+				Weight:              0.2,
+				ValueSetConstraints: map[string][]string{"Time": {"Morning", "Dawn"}},
+				Sets:                map[string]string{"Formality": "Polite"},
+			},
+		},
+	}
+	got, err := ParseYaml(contents)
+	if err != nil {
+		t.Fatalf("unexpected error occurred in ParseYaml(): %s", err)
+	}
+	if len(got.Definitions) != 1 || !reflect.DeepEqual(got.Definitions[0], want) {
+		t.Errorf("ParseYaml() = %#v, want %#v", got.Definitions, want)
+	}
+
+	if _, err := ParseYaml([]byte("Definitions: [{Type: Greeting, Templates: [{Weight: 1}]}]")); err == nil {
+		t.Errorf("ParseYaml() should return error if template object does not have Text")
+	}
+}
+
+func TestParseYaml_PostProcess(t *testing.T) {
+	contents := []byte(`
+PostProcess: [trim, collapse-space, capitalize]
+TypePostProcess:
+  Fruit: [article]
+Definitions:
+  - Type: Root
+    Templates: ["  {{.Fruit}}  is sweet.  "]
+  - Type: Fruit
+    Templates: ["a apple"]
+`)
+	config, err := ParseYaml(contents)
+	if err != nil {
+		t.Fatalf("unexpected error occurred in ParseYaml(): %s", err)
+	}
+	postProcess, typePostProcess, err := config.Transformers()
+	if err != nil {
+		t.Fatalf("unexpected error occurred in Config.Transformers(): %s", err)
+	}
+	generator, err := New(&Option{PostProcess: postProcess, TypePostProcess: typePostProcess})
+	if err != nil {
+		t.Fatalf("unexpected error occurred in New(): %s", err)
+	}
+	if err := generator.AddDefinition(config.Definitions...); err != nil {
+		t.Fatalf("unexpected error occurred in AddDefinition(): %s", err)
+	}
+	msgs, err := generator.Generate("Root", nil, 1)
+	if err != nil {
+		t.Fatalf("unexpected error occurred in Generate(): %s", err)
+	}
+	if want := "An apple is sweet."; msgs[0] != want {
+		t.Errorf("Generate() = %q, want %q", msgs[0], want)
+	}
+
+	config.PostProcess = []string{"unknown"}
+	if _, _, err := config.Transformers(); err == nil {
+		t.Errorf("Config.Transformers() error = nil, want error for unknown post process")
+	}
+}
+
+func TestParseYamlFilesOrUrls_Imports(t *testing.T) {
+	tests := []struct {
+		name         string
+		paths        []string
+		wantTypes    []string
+		wantErrParts []string
+	}{
+		{
+			name:      "imports are resolved relative to the importing file and merged once",
+			paths:     []string{"../testdata/imports/main.yaml"},
+			wantTypes: []string{"Emoji", "Name", "Root"},
+		},
+		{
+			name:      "multiple files are merged in order",
+			paths:     []string{"../testdata/imports/vocabulary/names.yaml", "../testdata/hello.yaml"},
+			wantTypes: []string{"Emoji", "Name", "Root"},
+		},
+		{
+			name:         "import cycle is error",
+			paths:        []string{"../testdata/imports/cycle_a.yaml"},
+			wantErrParts: []string{"import cycle", "cycle_a.yaml -> ../testdata/imports/cycle_b.yaml -> ../testdata/imports/cycle_a.yaml"},
+		},
+		{
+			name:         "error of imported file shows the import chain",
+			paths:        []string{"../testdata/imports/missing.yaml"},
+			wantErrParts: []string{"missing.yaml -> ../testdata/imports/vocabulary/missing.yaml"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseYamlFilesOrUrls(tt.paths...)
+			if (err != nil) != (len(tt.wantErrParts) > 0) {
+				t.Fatalf("ParseYamlFilesOrUrls() error = %v, wantErr %v", err, tt.wantErrParts)
+			}
+			for _, part := range tt.wantErrParts {
+				if !strings.Contains(err.Error(), part) {
+					t.Errorf("ParseYamlFilesOrUrls() error = %v, want to contain %q", err, part)
+				}
+			}
+			if err != nil {
+				return
+			}
+			var gotTypes []string
+			for _, def := range got.Definitions {
+				gotTypes = append(gotTypes, def.Type)
+			}
+			if !reflect.DeepEqual(gotTypes, tt.wantTypes) {
+				t.Errorf("ParseYamlFilesOrUrls() types = %v, want %v", gotTypes, tt.wantTypes)
+			}
+		})
+	}
+}
+
+func TestParseYamlFileOrUrl_ImportsFromUrl(t *testing.T) {
+	files := map[string]string{
+		"/defs/main.yaml":             "Imports: [vocabulary/names.yaml]\nDefinitions: [{Type: Root, Templates: ['Hi {{.Name}}']}]",
+		"/defs/vocabulary/names.yaml": "Definitions: [{Type: Name, Templates: [Alice]}]",
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contents, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(contents))
+	}))
+	defer server.Close()
+
+	got, err := ParseYamlFileOrUrl(server.URL + "/defs/main.yaml")
+	if err != nil {
+		t.Fatalf("unexpected error occurred in ParseYamlFileOrUrl(): %s", err)
+	}
+	if len(got.Definitions) != 2 || got.Definitions[0].Type != "Name" || got.Definitions[1].Type != "Root" {
+		t.Errorf("ParseYamlFileOrUrl() = %#v, want Name and Root definitions", got.Definitions)
+	}
+}
+
+func TestParseYamlFilesOrUrls_Namespaces(t *testing.T) {
+	tests := []struct {
+		name         string
+		path         string
+		wantTypes    []string
+		wantErrParts []string
+	}{
+		{
+			name:      "types of namespaced files are qualified",
+			path:      "../testdata/namespaces/main.yaml",
+			wantTypes: []string{"emoji.Good", "emoji.Face", "people.Greeting", "people.Name", "people.Mood", "Root"},
+		},
+		{
+			name:         "types which are not exported can not be referred",
+			path:         "../testdata/namespaces/private.yaml",
+			wantErrParts: []string{"Face is not exported from namespace emoji"},
+		},
+		{
+			name:         "ambiguous reference is error",
+			path:         "../testdata/namespaces/ambiguous.yaml",
+			wantErrParts: []string{"reference to Name is ambiguous", "people.yaml"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseYamlFilesOrUrls(tt.path)
+			if (err != nil) != (len(tt.wantErrParts) > 0) {
+				t.Fatalf("ParseYamlFilesOrUrls() error = %v, wantErr %v", err, tt.wantErrParts)
+			}
+			for _, part := range tt.wantErrParts {
+				if !strings.Contains(err.Error(), part) {
+					t.Errorf("ParseYamlFilesOrUrls() error = %v, want to contain %q", err, part)
+				}
+			}
+			if err != nil {
+				return
+			}
+			var gotTypes []string
+			for _, def := range got.Definitions {
+				gotTypes = append(gotTypes, def.Type)
+			}
+			if !reflect.DeepEqual(gotTypes, tt.wantTypes) {
+				t.Errorf("ParseYamlFilesOrUrls() types = %v, want %v", gotTypes, tt.wantTypes)
+			}
+		})
+	}
+
+	config, err := ParseYamlFilesOrUrls("../testdata/namespaces/main.yaml")
+	if err != nil {
+		t.Fatalf("unexpected error occurred in ParseYamlFilesOrUrls(): %s", err)
+	}
+	generator, err := New(nil)
+	if err != nil {
+		t.Fatalf("unexpected error occurred in New(): %s", err)
+	}
+	if err := generator.AddDefinition(config.Definitions...); err != nil {
+		t.Fatalf("unexpected error occurred in AddDefinition(): %s", err)
+	}
+	msgs, err := generator.Generate("Root", nil, 1)
+	if err != nil {
+		t.Fatalf("unexpected error occurred in Generate(): %s", err)
+	}
+	if want := "Hi Alice :)b :)b"; msgs[0] != want {
+		t.Errorf("Generate() = %q, want %q", msgs[0], want)
+	}
+}
+
+func TestParseFilesOrUrls_Formats(t *testing.T) {
+	want, err := ParseYamlFile("../testdata/formats/greeting.yaml")
+	if err != nil {
+		t.Fatalf("unexpected error occurred in ParseYamlFile(): %s", err)
+	}
+	for _, path := range []string{"../testdata/formats/greeting.json", "../testdata/formats/greeting.toml"} {
+		t.Run(path, func(t *testing.T) {
+			got, err := ParseFilesOrUrls(path)
+			if err != nil {
+				t.Fatalf("unexpected error occurred in ParseFilesOrUrls(): %s", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("ParseFilesOrUrls() = %#v, want %#v", got, want)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents string
+		format   Format
+		wantType string
+		wantErr  bool
+	}{
+		{name: "yaml", contents: "Definitions: [{Type: Root, Templates: [hi]}]", format: FormatYAML, wantType: "Root"},
+		{name: "json", contents: `{"Definitions": [{"Type": "Root", "Templates": ["hi"]}]}`, format: FormatJSON, wantType: "Root"},
+		{name: "toml", contents: "[[Definitions]]\nType = 'Root'\nTemplates = ['hi']", format: FormatTOML, wantType: "Root"},
+		{name: "invalid json", contents: `{"Definitions": [`, format: FormatJSON, wantErr: true},
+		{name: "unknown format", contents: "", format: Format("xml"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse([]byte(tt.contents), tt.format)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if len(got.Definitions) != 1 || got.Definitions[0].Type != tt.wantType {
+				t.Errorf("Parse() = %#v, want %s definition", got.Definitions, tt.wantType)
+			}
+		})
+	}
+}
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		filePathOrUrl string
+		contentType   string
+		want          Format
+	}{
+		{filePathOrUrl: "defs/main.yml", want: FormatYAML},
+		{filePathOrUrl: "defs/main.JSON", want: FormatJSON},
+		{filePathOrUrl: "defs/main.toml", want: FormatTOML},
+		{filePathOrUrl: "defs/main", want: FormatYAML},
+		{filePathOrUrl: "https://example.com/main.json?rev=1", want: FormatJSON},
+		{filePathOrUrl: "https://example.com/main", contentType: "application/json; charset=utf-8", want: FormatJSON},
+		{filePathOrUrl: "https://example.com/main", contentType: "application/toml", want: FormatTOML},
+		{filePathOrUrl: "https://example.com/main.yaml", contentType: "application/json", want: FormatYAML},
+		{filePathOrUrl: "https://example.com/main", contentType: "text/plain", want: FormatYAML},
+	}
+	for _, tt := range tests {
+		t.Run(tt.filePathOrUrl+" "+tt.contentType, func(t *testing.T) {
+			if got := DetectFormat(tt.filePathOrUrl, tt.contentType); got != tt.want {
+				t.Errorf("DetectFormat() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFileOrUrl_FormatFromContentType(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/main":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"Imports": ["names"], "Definitions": [{"Type": "Root", "Templates": ["Hi {{.Name}}"]}]}`))
+		case "/names":
+			w.Header().Set("Content-Type", "application/toml")
+			_, _ = w.Write([]byte("[[Definitions]]\nType = 'Name'\nTemplates = ['Alice']"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	got, err := ParseFileOrUrl(server.URL + "/main")
+	if err != nil {
+		t.Fatalf("unexpected error occurred in ParseFileOrUrl(): %s", err)
+	}
+	if len(got.Definitions) != 2 || got.Definitions[0].Type != "Name" || got.Definitions[1].Type != "Root" {
+		t.Errorf("ParseFileOrUrl() = %#v, want Name and Root definitions", got.Definitions)
+	}
+}
+
+func TestMarshalYaml(t *testing.T) {
+	for _, path := range []string{"../testdata/formats/greeting.yaml", "../testdata/gurume.yaml", "../testdata/orderby.yaml"} {
+		t.Run(path, func(t *testing.T) {
+			want, err := ParseYamlFile(path)
+			if err != nil {
+				t.Fatalf("unexpected error occurred in ParseYamlFile(): %s", err)
+			}
+			contents, err := MarshalYaml(want)
+			if err != nil {
+				t.Fatalf("unexpected error occurred in MarshalYaml(): %s", err)
+			}
+			got, err := ParseYaml(contents)
+			if err != nil {
+				t.Fatalf("unexpected error occurred in ParseYaml(): %s\n%s", err, contents)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("ParseYaml(MarshalYaml()) = %#v, want %#v", got, want)
+			}
+		})
+	}
+}
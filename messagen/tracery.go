@@ -0,0 +1,347 @@
+package messagen
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/mpppk/messagen/messagen/internal"
+	"golang.org/x/xerrors"
+)
+
+// traceryModifiers maps Tracery modifiers to template functions.
+// Functions except title are provided by TraceryFuncMap.
+var traceryModifiers = map[string]string{
+	"capitalize":    "traceryCapitalize",
+	"capitalizeAll": "title",
+	"s":             "traceryPlural",
+	"a":             "traceryWithArticle",
+	"ed":            "traceryPast",
+}
+
+// TraceryFuncMap returns functions which templates converted by ParseTracery call for modifiers,
+// like traceryPlural for #animal.s#. Pass them as FuncMap of Option to generate messages from the converted definitions.
+func TraceryFuncMap() template.FuncMap {
+	return internal.TraceryFuncs()
+}
+
+const (
+	// traceryOrigin is the rule which Tracery expands first.
+	traceryOrigin = "origin"
+	// traceryRoot is the definition type which refers origin. It is the default root type of the CLI.
+	traceryRoot = "Root"
+)
+
+var traceryIdentRegExp = regexp.MustCompile(`^[\pL_][\pL\pN_]*$`)
+
+type traceryRule struct {
+	name  string
+	texts []string
+}
+
+// ParseTracery converts a Tracery JSON grammar like {"origin": ["#greeting#, #name#!"]} to the config.
+// Each rule is converted to a definition whose type is the rule name, and #sym# is converted to {{.sym}}.
+// Modifiers like #sym.capitalize# are converted to template functions of TraceryFuncMap like {{.sym | traceryCapitalize}}.
+// Actions like [hero:#name#] are converted to definitions which assign the expanded value to the state by Sets,
+// and they are referred like {{if .set_hero}}{{end}} so that they are resolved without output.
+// If the grammar has origin rule but no Root rule, Root definition which refers origin is added.
+//
+// Unlike Tracery, messagen resolves each definition type once in a message, so #sym# is expanded to the same value everywhere.
+// POP actions are ignored for the same reason.
+func ParseTracery(contents []byte) (*Config, error) {
+	rules, err := decodeTraceryGrammar(contents)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to parse tracery grammar: %w", err)
+	}
+
+	c := &traceryConverter{ruleNames: map[string]bool{}, actionTypes: map[string]string{}}
+	for _, rule := range rules {
+		if !traceryIdentRegExp.MatchString(rule.name) {
+			return nil, xerrors.Errorf("rule name %q can not be used as definition type", rule.name)
+		}
+		c.ruleNames[rule.name] = true
+	}
+
+	config := &Config{Definitions: []*Definition{}}
+	if c.ruleNames[traceryOrigin] && !c.ruleNames[traceryRoot] {
+		config.Definitions = append(config.Definitions, &Definition{
+			Type:      traceryRoot,
+			Templates: []string{fmt.Sprintf("{{.%s}}", traceryOrigin)},
+		})
+	}
+	for _, rule := range rules {
+		def := &Definition{Type: rule.name}
+		for _, text := range rule.texts {
+			template, err := c.convert(text, false)
+			if err != nil {
+				return nil, xerrors.Errorf("failed to convert %q of rule %s: %w", text, rule.name, err)
+			}
+			def.Templates = append(def.Templates, template)
+		}
+		config.Definitions = append(config.Definitions, def)
+	}
+	config.Definitions = append(config.Definitions, c.actionDefinitions...)
+	return config, nil
+}
+
+// decodeTraceryGrammar decodes rules of the grammar in the written order. Each rule is a string or a list of strings.
+func decodeTraceryGrammar(contents []byte) ([]*traceryRule, error) {
+	decoder := json.NewDecoder(bytes.NewReader(contents))
+	if token, err := decoder.Token(); err != nil {
+		return nil, err
+	} else if token != json.Delim('{') {
+		return nil, xerrors.New("grammar must be an object")
+	}
+
+	var rules []*traceryRule
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		rule := &traceryRule{name: token.(string)}
+		var value interface{}
+		if err := decoder.Decode(&value); err != nil {
+			return nil, xerrors.Errorf("failed to decode rule %s: %w", rule.name, err)
+		}
+		switch v := value.(type) {
+		case string:
+			rule.texts = []string{v}
+		case []interface{}:
+			for _, item := range v {
+				text, ok := item.(string)
+				if !ok {
+					return nil, xerrors.Errorf("rule %s must be a string or a list of strings", rule.name)
+				}
+				rule.texts = append(rule.texts, text)
+			}
+		default:
+			return nil, xerrors.Errorf("rule %s must be a string or a list of strings", rule.name)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+type traceryConverter struct {
+	ruleNames map[string]bool
+	// actionTypes are definition types of actions like "hero:#name#".
+	actionTypes       map[string]string
+	actionDefinitions []*Definition
+}
+
+type traceryNodeKind int
+
+const (
+	traceryTextNode traceryNodeKind = iota
+	traceryTagNode
+	traceryActionNode
+)
+
+type traceryNode struct {
+	kind traceryNodeKind
+	raw  string
+}
+
+// splitTraceryText splits the text into plain texts, tags like #sym# and actions like [key:value] in the same way as Tracery.
+// Actions in tags like #[hero:#name#]story# are kept in the raw of the tag.
+func splitTraceryText(text string) ([]*traceryNode, error) {
+	var nodes []*traceryNode
+	var buf strings.Builder
+	flush := func(kind traceryNodeKind) {
+		if kind != traceryTextNode || buf.Len() > 0 {
+			nodes = append(nodes, &traceryNode{kind: kind, raw: buf.String()})
+		}
+		buf.Reset()
+	}
+
+	depth, inTag, escaped := 0, false, false
+	for _, r := range text {
+		if escaped {
+			// escapes in tags and actions are kept because they are split again
+			if depth > 0 || inTag {
+				buf.WriteRune('\\')
+			}
+			buf.WriteRune(r)
+			escaped = false
+			continue
+		}
+		switch {
+		case r == '\\':
+			escaped = true
+		case r == '[':
+			if depth == 0 && !inTag {
+				flush(traceryTextNode)
+			} else {
+				buf.WriteRune(r)
+			}
+			depth++
+		case r == ']':
+			depth--
+			if depth < 0 {
+				return nil, xerrors.New("] does not have matching [")
+			}
+			if depth == 0 && !inTag {
+				flush(traceryActionNode)
+			} else {
+				buf.WriteRune(r)
+			}
+		case r == '#' && depth == 0:
+			if inTag {
+				flush(traceryTagNode)
+			} else {
+				flush(traceryTextNode)
+			}
+			inTag = !inTag
+		default:
+			buf.WriteRune(r)
+		}
+	}
+	if depth > 0 {
+		return nil, xerrors.New("[ is not closed")
+	}
+	if inTag {
+		return nil, xerrors.New("# is not closed")
+	}
+	flush(traceryTextNode)
+	return nodes, nil
+}
+
+// convert converts the Tracery text to the template.
+// If silent is true, only references are converted so that the template resolves them without output.
+func (c *traceryConverter) convert(text string, silent bool) (string, error) {
+	nodes, err := splitTraceryText(text)
+	if err != nil {
+		return "", err
+	}
+	var template strings.Builder
+	for _, node := range nodes {
+		var s string
+		switch node.kind {
+		case traceryTextNode:
+			if !silent {
+				s = strings.ReplaceAll(node.raw, "{{", `{{"{{"}}`)
+			}
+		case traceryTagNode:
+			s, err = c.convertTag(node.raw, silent)
+		case traceryActionNode:
+			s, err = c.convertAction(node.raw)
+		}
+		if err != nil {
+			return "", err
+		}
+		template.WriteString(s)
+	}
+	return template.String(), nil
+}
+
+// convertTag converts the tag like "[hero:#name#]sym.capitalize" to actions and the reference like {{.sym | traceryCapitalize}}.
+func (c *traceryConverter) convertTag(raw string, silent bool) (string, error) {
+	var template strings.Builder
+	for strings.HasPrefix(raw, "[") {
+		end := matchingBracket(raw)
+		if end < 0 {
+			return "", xerrors.Errorf("[ is not closed in #%s#", raw)
+		}
+		action, err := c.convertAction(raw[1:end])
+		if err != nil {
+			return "", err
+		}
+		template.WriteString(action)
+		raw = raw[end+1:]
+	}
+	if raw == "" {
+		return template.String(), nil
+	}
+
+	sections := strings.Split(raw, ".")
+	symbol := sections[0]
+	if !traceryIdentRegExp.MatchString(symbol) {
+		return "", xerrors.Errorf("symbol %q can not be used as definition type", symbol)
+	}
+	if silent {
+		template.WriteString(fmt.Sprintf("{{if .%s}}{{end}}", symbol))
+		return template.String(), nil
+	}
+
+	pipeline := []string{"." + symbol}
+	for _, modifier := range sections[1:] {
+		f, ok := traceryModifiers[modifier]
+		if !ok {
+			return "", xerrors.Errorf("modifier %s of #%s# is not supported", modifier, raw)
+		}
+		pipeline = append(pipeline, f)
+	}
+	template.WriteString("{{" + strings.Join(pipeline, " | ") + "}}")
+	return template.String(), nil
+}
+
+// matchingBracket returns the index of ] which closes [ at the beginning of s, or -1 if it is not closed.
+func matchingBracket(s string) int {
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// convertAction converts the action to the reference which resolves the action without output.
+// [key:value1,value2] is converted to the definition which has the values as templates and assigns its value to key.
+// [#sym#] is converted to the reference to sym. [key:POP] is ignored.
+func (c *traceryConverter) convertAction(raw string) (string, error) {
+	sections := strings.SplitN(raw, ":", 2)
+	if len(sections) == 1 {
+		return c.convert(raw, true)
+	}
+
+	key, value := sections[0], sections[1]
+	if !traceryIdentRegExp.MatchString(key) {
+		return "", xerrors.Errorf("key %q of action [%s] can not be used as definition type", key, raw)
+	}
+	if value == "POP" {
+		return "", nil
+	}
+
+	defType, ok := c.actionTypes[raw]
+	if !ok {
+		defType = c.newActionType(key)
+		c.actionTypes[raw] = defType
+		def := &Definition{
+			Type: defType,
+			Sets: map[string]string{key: fmt.Sprintf("{{.%s}}", defType)},
+		}
+		c.actionDefinitions = append(c.actionDefinitions, def)
+		for _, text := range strings.Split(value, ",") {
+			template, err := c.convert(text, false)
+			if err != nil {
+				return "", xerrors.Errorf("failed to convert action [%s]: %w", raw, err)
+			}
+			def.Templates = append(def.Templates, template)
+		}
+	}
+	return fmt.Sprintf("{{if .%s}}{{end}}", defType), nil
+}
+
+// newActionType returns the unused definition type for an action of the key like set_hero or set_hero_2.
+func (c *traceryConverter) newActionType(key string) string {
+	base := "set_" + key
+	defType := base
+	for i := 2; c.ruleNames[defType]; i++ {
+		defType = fmt.Sprintf("%s_%d", base, i)
+	}
+	c.ruleNames[defType] = true
+	return defType
+}
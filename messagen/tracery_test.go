@@ -0,0 +1,160 @@
+package messagen
+
+import (
+	"io/ioutil"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestParseTracery(t *testing.T) {
+	tests := []struct {
+		name         string
+		grammar      string
+		want         []*Definition
+		wantErrParts []string
+	}{
+		{
+			name:    "symbols are converted to references and Root refers origin",
+			grammar: `{"origin": "#greeting#, #name#!", "greeting": ["hi", "hello"], "name": ["alice"]}`,
+			want: []*Definition{
+				{Type: "Root", Templates: []string{"{{.origin}}"}},
+				{Type: "origin", Templates: []string{"{{.greeting}}, {{.name}}!"}},
+				{Type: "greeting", Templates: []string{"hi", "hello"}},
+				{Type: "name", Templates: []string{"alice"}},
+			},
+		},
+		{
+			name:    "Root is not added if the grammar has Root",
+			grammar: `{"Root": ["#origin#"], "origin": ["hi"]}`,
+			want: []*Definition{
+				{Type: "Root", Templates: []string{"{{.origin}}"}},
+				{Type: "origin", Templates: []string{"hi"}},
+			},
+		},
+		{
+			name:    "modifiers are converted to template functions",
+			grammar: `{"sentence": ["#animal.a.capitalize# #verb.ed# #animal.s#. #name.capitalizeAll#"]}`,
+			want: []*Definition{
+				{Type: "sentence", Templates: []string{"{{.animal | traceryWithArticle | traceryCapitalize}} {{.verb | traceryPast}} {{.animal | traceryPlural}}. {{.name | title}}"}},
+			},
+		},
+		{
+			name:    "actions are converted to definitions which assign values",
+			grammar: `{"story": ["[hero:#name#,nobody]#hero# [hero:POP]", "#[hero:#name#][pet:cat]hero# and #pet#"]}`,
+			want: []*Definition{
+				{Type: "story", Templates: []string{
+					"{{if .set_hero}}{{end}}{{.hero}} ",
+					"{{if .set_hero_2}}{{end}}{{if .set_pet}}{{end}}{{.hero}} and {{.pet}}",
+				}},
+				{Type: "set_hero", Templates: []string{"{{.name}}", "nobody"}, Sets: map[string]string{"hero": "{{.set_hero}}"}},
+				{Type: "set_hero_2", Templates: []string{"{{.name}}"}, Sets: map[string]string{"hero": "{{.set_hero_2}}"}},
+				{Type: "set_pet", Templates: []string{"cat"}, Sets: map[string]string{"pet": "{{.set_pet}}"}},
+			},
+		},
+		{
+			name:    "actions without key resolve the symbol without output",
+			grammar: `{"origin": ["[#setHero#]#hero#"], "setHero": ["[hero:alice]"]}`,
+			want: []*Definition{
+				{Type: "Root", Templates: []string{"{{.origin}}"}},
+				{Type: "origin", Templates: []string{"{{if .setHero}}{{end}}{{.hero}}"}},
+				{Type: "setHero", Templates: []string{"{{if .set_hero}}{{end}}"}},
+				{Type: "set_hero", Templates: []string{"alice"}, Sets: map[string]string{"hero": "{{.set_hero}}"}},
+			},
+		},
+		{
+			name:    "escaped characters and template delimiters are kept as text",
+			grammar: `{"price": ["\\#1 {{sale}}"]}`,
+			want: []*Definition{
+				{Type: "price", Templates: []string{`#1 {{"{{"}}sale}}`}},
+			},
+		},
+		{
+			name:         "unsupported modifier is error",
+			grammar:      `{"origin": ["#name.beeSpeak#"]}`,
+			wantErrParts: []string{"modifier beeSpeak of #name.beeSpeak# is not supported"},
+		},
+		{
+			name:         "unclosed tag is error",
+			grammar:      `{"origin": ["#name"]}`,
+			wantErrParts: []string{"rule origin", "# is not closed"},
+		},
+		{
+			name:         "rule name which is not identifier is error",
+			grammar:      `{"first-name": ["alice"]}`,
+			wantErrParts: []string{`rule name "first-name"`},
+		},
+		{
+			name:         "rule which is not string is error",
+			grammar:      `{"origin": [1]}`,
+			wantErrParts: []string{"rule origin must be a string or a list of strings"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseTracery([]byte(tt.grammar))
+			if (err != nil) != (len(tt.wantErrParts) > 0) {
+				t.Fatalf("ParseTracery() error = %v, wantErr %v", err, tt.wantErrParts)
+			}
+			for _, part := range tt.wantErrParts {
+				if !strings.Contains(err.Error(), part) {
+					t.Errorf("ParseTracery() error = %v, want to contain %q", err, part)
+				}
+			}
+			if err != nil {
+				return
+			}
+			if !reflect.DeepEqual(got.Definitions, tt.want) {
+				t.Errorf("ParseTracery() = %#v, want %#v", got.Definitions, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTracery_Generate(t *testing.T) {
+	contents, err := ioutil.ReadFile("../testdata/tracery/story.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, err := ParseTracery(contents)
+	if err != nil {
+		t.Fatalf("unexpected error occurred in ParseTracery(): %s", err)
+	}
+	if issues := LintDefinitionsWithFuncMap("Root", nil, TraceryFuncMap(), config.Definitions...); issues.HasError() {
+		t.Errorf("LintDefinitionsWithFuncMap() = %v, want no errors", issues)
+	}
+
+	generator, err := New(&Option{FuncMap: TraceryFuncMap()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := generator.AddDefinition(config.Definitions...); err != nil {
+		t.Fatalf("unexpected error occurred in AddDefinition(): %s", err)
+	}
+
+	// values which are assigned by the same action are consistent
+	wantRegExp := regexp.MustCompile(`^((Alice|Bob) met an? \w+\. She|(Alice|Bob) the (baker|wizard) met an? \w+\. They) liked \w+\.$`)
+	it, err := generator.Enumerate("Root", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	count := 0
+	for {
+		msg, ok, err := it.Next()
+		if err != nil {
+			t.Fatalf("unexpected error occurred in Next(): %s", err)
+		}
+		if !ok {
+			break
+		}
+		if !wantRegExp.MatchString(msg) {
+			t.Errorf("unexpected message is generated: %s", msg)
+		}
+		count++
+	}
+	// (2 names + 2 names * 2 occupations) * 3 animals
+	if count != 18 {
+		t.Errorf("%d messages are generated, want 18", count)
+	}
+}